@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"enex2paperless/internal/config"
+	"enex2paperless/pkg/paperless"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// newConfigCmd returns the config command with its init and test subcommands
+func newConfigCmd() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Create and check the configuration",
+	}
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:          "init",
+		Short:        "Interactively create a config file",
+		Long:         `Asks for the Paperless URL, the credentials and the file types to import, checks the connection and writes the config file. The file is written to the path of --config, or replaces the config file that would be used. Without one, it is written to the user config dir.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := initPath()
+			if err != nil {
+				return err
+			}
+			return configInit(cmd.InOrStdin(), cmd.OutOrStdout(), path)
+		},
+	})
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:          "test",
+		Short:        "Print the effective configuration and check the connection",
+		Long:         `Prints the configuration merged from the config file, the profile and E2P_ environment variables, with secrets masked, and checks the connection to Paperless and all mirrors.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return configTest(cmd.OutOrStdout())
+		},
+	})
+
+	return configCmd
+}
+
+// initPath returns the path config init writes to: the --config flag, the config
+// file found by the lookup, or the user config dir if there is none, so that the
+// new file isn't shadowed by an existing one
+func initPath() (string, error) {
+	if configFile != "" {
+		return configFile, nil
+	}
+	if path := config.FindConfigFile(); fileExists(path) {
+		return path, nil
+	}
+	return config.UserConfigFile()
+}
+
+// fileExists reports whether path is an existing file
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// configTest prints the effective configuration and runs the preflight checks
+func configTest(out io.Writer) error {
+	path := configFile
+	if path == "" {
+		path = config.FindConfigFile()
+	}
+
+	config.SetOptions(configFile, profile)
+	settings, err := config.GetConfig()
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	data, err := settings.Masked().YAML()
+	if err != nil {
+		return fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	fmt.Fprintf(out, "# config file: %s\n", path)
+	if profile != "" {
+		fmt.Fprintf(out, "# profile: %s\n", profile)
+	}
+	fmt.Fprintf(out, "%s\n", data)
+
+	if _, err := paperless.Connect(settings); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Connection check passed.")
+	return nil
+}
+
+// configInit asks for the basic settings until they pass validation and the
+// connection check, then writes them to path
+func configInit(in io.Reader, out io.Writer, path string) error {
+	reader := newInput(in)
+
+	if fileExists(path) {
+		overwrite, err := confirm(reader, out, fmt.Sprintf("%s already exists. Overwrite it?", path), false)
+		if err != nil {
+			return err
+		}
+		if !overwrite {
+			return errors.New("aborted, config file not changed")
+		}
+	}
+
+	settings := config.Config{
+		PaperlessAPI: "http://localhost:8000",
+		FileTypes:    []string{"pdf"},
+	}
+	for {
+		var err error
+		settings, err = askSettings(reader, out, settings)
+		if err != nil {
+			return err
+		}
+
+		err = settings.Validate()
+		if err == nil {
+			fmt.Fprintln(out, "Checking the connection...")
+			_, err = paperless.Connect(settings)
+		}
+		if err == nil {
+			break
+		}
+
+		fmt.Fprintf(out, "\n%v\n\n", err)
+		retry, confirmErr := confirm(reader, out, "Change the settings?", true)
+		if confirmErr != nil {
+			return confirmErr
+		}
+		if !retry {
+			return fmt.Errorf("config file not written: %w", err)
+		}
+	}
+
+	data, err := settings.YAML()
+	if err != nil {
+		return fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config dir: %w", err)
+	}
+	// the file holds credentials
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	fmt.Fprintf(out, "Config written to %s\n", path)
+	return nil
+}
+
+// askSettings asks for the Paperless URL, the credentials and the file types,
+// offering the given settings as defaults
+func askSettings(reader *input, out io.Writer, defaults config.Config) (config.Config, error) {
+	settings := defaults
+
+	api, err := ask(reader, out, "Paperless URL", defaults.PaperlessAPI, false)
+	if err != nil {
+		return settings, err
+	}
+	settings.PaperlessAPI = strings.TrimSuffix(api, "/")
+
+	method := "1"
+	if defaults.Username != "" {
+		method = "2"
+	}
+	fmt.Fprintln(out, "Authentication: 1) API token  2) username and password")
+	for {
+		method, err = ask(reader, out, "Choose", method, false)
+		if err != nil {
+			return settings, err
+		}
+		if method == "1" || method == "2" {
+			break
+		}
+	}
+
+	if method == "1" {
+		settings.Username, settings.Password = "", ""
+		if settings.Token, err = ask(reader, out, "API token", defaults.Token, true); err != nil {
+			return settings, err
+		}
+	} else {
+		settings.Token = ""
+		if settings.Username, err = ask(reader, out, "Username", defaults.Username, false); err != nil {
+			return settings, err
+		}
+		if settings.Password, err = ask(reader, out, "Password", defaults.Password, true); err != nil {
+			return settings, err
+		}
+	}
+
+	fileTypes, err := ask(reader, out, "File types to import, comma separated or 'any'", strings.Join(defaults.FileTypes, ","), false)
+	if err != nil {
+		return settings, err
+	}
+	settings.FileTypes = nil
+	for _, fileType := range strings.Split(fileTypes, ",") {
+		if fileType = strings.ToLower(strings.TrimSpace(fileType)); fileType != "" {
+			settings.FileTypes = append(settings.FileTypes, fileType)
+		}
+	}
+
+	return settings, nil
+}
+
+// input reads the answers to the questions
+type input struct {
+	*bufio.Reader
+
+	// terminal is the file descriptor of the input if it is a terminal, secrets
+	// are read from it without echoing them. It is -1 for other input.
+	terminal int
+}
+
+// newInput returns the input for the reader
+func newInput(in io.Reader) *input {
+	r := &input{Reader: bufio.NewReader(in), terminal: -1}
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		r.terminal = int(f.Fd())
+	}
+	return r
+}
+
+// ask prints a question and reads the answer, an empty answer returns the
+// default. Secret defaults aren't shown, and neither are secrets typed into a terminal.
+func ask(reader *input, out io.Writer, question, defaultValue string, secret bool) (string, error) {
+	switch {
+	case defaultValue == "":
+		fmt.Fprintf(out, "%s: ", question)
+	case secret:
+		fmt.Fprintf(out, "%s [keep current]: ", question)
+	default:
+		fmt.Fprintf(out, "%s [%s]: ", question, defaultValue)
+	}
+
+	var line string
+	var err error
+	if secret && reader.terminal >= 0 {
+		var answer []byte
+		answer, err = term.ReadPassword(reader.terminal)
+		// the newline isn't echoed either
+		fmt.Fprintln(out)
+		line = string(answer)
+	} else {
+		line, err = reader.ReadString('\n')
+	}
+	if err != nil && (err != io.EOF || line == "") {
+		return "", fmt.Errorf("failed to read answer: %w", err)
+	}
+
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer, nil
+	}
+	return defaultValue, nil
+}
+
+// confirm asks a yes/no question
+func confirm(reader *input, out io.Writer, question string, defaultYes bool) (bool, error) {
+	defaultValue := "y/N"
+	if defaultYes {
+		defaultValue = "Y/n"
+	}
+
+	answer, err := ask(reader, out, question, defaultValue, false)
+	if err != nil {
+		return false, err
+	}
+	if answer == defaultValue {
+		return defaultYes, nil
+	}
+	return strings.HasPrefix(strings.ToLower(answer), "y"), nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"enex2paperless/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/knadh/koanf/providers/file"
+)
+
+// TestConfigInit verifies that the wizard only writes settings that pass the connection check
+func TestConfigInit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token good-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("X-Version", "2.15.3")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		input         []string
+		expectedToken string
+		expectedError string
+	}{
+		{
+			name:          "token",
+			input:         []string{server.URL + "/", "1", "good-token", "pdf, PNG"},
+			expectedToken: "good-token",
+		},
+		{
+			name: "wrong token corrected",
+			input: []string{
+				server.URL, "", "bad-token", "",
+				"y", "", "", "good-token", "pdf,png",
+			},
+			expectedToken: "good-token",
+		},
+		{
+			name:          "wrong token not corrected",
+			input:         []string{server.URL, "1", "bad-token", "", "n"},
+			expectedError: "authentication failed",
+		},
+		{
+			name:          "missing answers",
+			input:         []string{server.URL},
+			expectedError: "failed to read answer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "enex2paperless", "config.yaml")
+			in := strings.NewReader(strings.Join(tt.input, "\n") + "\n")
+
+			err := configInit(in, &bytes.Buffer{}, path)
+
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectedError, err)
+				}
+				if _, err := os.Stat(path); err == nil {
+					t.Error("config file was written despite the error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			cfg, err := config.LoadConfig(file.Provider(path), "E2P_")
+			if err != nil {
+				t.Fatalf("failed to load written config: %v", err)
+			}
+			if cfg.PaperlessAPI != server.URL || cfg.Token != tt.expectedToken {
+				t.Errorf("unexpected config: %+v", cfg)
+			}
+			if strings.Join(cfg.FileTypes, ",") != "pdf,png" {
+				t.Errorf("FileTypes = %v, expected [pdf png]", cfg.FileTypes)
+			}
+		})
+	}
+}
@@ -2,15 +2,16 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
-	"path/filepath"
-	"strings"
+	"slices"
 
 	"enex2paperless/internal/config"
 	"enex2paperless/internal/logging"
 	"enex2paperless/pkg/enex"
+	"enex2paperless/pkg/paperless"
 
 	"github.com/spf13/cobra"
 )
@@ -23,15 +24,47 @@ var (
 	outputfolder     string
 	tags             []string
 	useFilenameAsTag bool
+	renderNotes      bool
+	combineImages    bool
+	mergeAttachments bool
+	consumeLayout    bool
+	documentImporter bool
+	configFile       string
+	profile          string
+
+	// filter holds the filter flags, set values override the configured filter
+	filter config.Filter
+
+	// inputFiles are the ENEX files found for the arguments
+	inputFiles []string
 )
 
 func main() {
 	// define root command
 	rootCmd := &cobra.Command{
-		Use:   "enex2paperless [file path]",
+		Use:   "enex2paperless [file path]...",
 		Short: "ENEX to Paperless-NGX parser",
 		Long:  `An ENEX file parser for Paperless-NGX. https://github.com/kevinzehnder/enex2paperless`,
-		Args:  cobra.ExactArgs(1),
+		Args:  cobra.MinimumNArgs(1),
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// set log level based on verbose flag
+			var logLevel slog.Level
+			if verbose {
+				logLevel = slog.LevelDebug
+			} else {
+				logLevel = slog.LevelInfo
+			}
+
+			opts := &slog.HandlerOptions{
+				Level: logLevel,
+			}
+
+			// use custom slog Handler
+			logger := slog.New(logging.NewHandler(opts, nocolor))
+			slog.SetDefault(logger)
+
+			return nil
+		},
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			// validate concurrent workers
 			if howMany < 1 {
@@ -52,30 +85,13 @@ func main() {
 				}
 			}
 
-			// validate input file exists
-			if _, err := os.Stat(args[0]); err != nil {
-				if os.IsNotExist(err) {
-					return fmt.Errorf("input file does not exist: %s", args[0])
-				}
-				return fmt.Errorf("cannot access input file: %w", err)
-			}
-
-			// set log level based on verbose flag
-			var logLevel slog.Level
-			if verbose {
-				logLevel = slog.LevelDebug
-			} else {
-				logLevel = slog.LevelInfo
-			}
-
-			opts := &slog.HandlerOptions{
-				Level: logLevel,
+			// expand globs and directories, validate input files exist
+			var err error
+			inputFiles, err = enex.FindFiles(args)
+			if err != nil {
+				return err
 			}
 
-			// use custom slog Handler
-			logger := slog.New(logging.NewHandler(opts, nocolor))
-			slog.SetDefault(logger)
-
 			return nil
 		},
 
@@ -84,12 +100,32 @@ func main() {
 	}
 
 	// add flags
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Path to the config file. Looked up in the working directory and the config dirs by default.")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "Profile from the config file to apply on top of the base settings.")
 	rootCmd.PersistentFlags().IntVarP(&howMany, "concurrent", "c", 1, "Number of concurrent consumers")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging")
 	rootCmd.PersistentFlags().BoolVarP(&nocolor, "nocolor", "n", false, "Disable colored output")
 	rootCmd.PersistentFlags().StringVarP(&outputfolder, "outputfolder", "o", "", "Output attachements to this folder, NOT paperless.")
 	rootCmd.PersistentFlags().StringSliceVarP(&tags, "tags", "t", nil, "Additional tags to add to all documents.")
 	rootCmd.PersistentFlags().BoolVarP(&useFilenameAsTag, "use-filename-tag", "T", false, "Add the ENEX filename as tag to all documents.")
+	rootCmd.PersistentFlags().BoolVar(&renderNotes, "render-notes", false, "Render notes without attachments to PDF and import them.")
+	rootCmd.PersistentFlags().BoolVar(&combineImages, "combine-images", false, "Combine the images of a note into one PDF document.")
+	rootCmd.PersistentFlags().BoolVar(&mergeAttachments, "merge-attachments", false, "Merge a cover page and all PDFs and images of a note into one document.")
+	rootCmd.PersistentFlags().BoolVar(&consumeLayout, "consume-layout", false, "Lay out the output folder for the Paperless consumption directory.")
+	rootCmd.PersistentFlags().BoolVar(&documentImporter, "document-importer", false, "Write a manifest to the output folder for the Paperless document_importer.")
+	rootCmd.PersistentFlags().StringSliceVar(&filter.IncludeTags, "include-tag", nil, "Only import notes with one of these tags.")
+	rootCmd.PersistentFlags().StringSliceVar(&filter.ExcludeTags, "exclude-tag", nil, "Skip notes with one of these tags.")
+	rootCmd.PersistentFlags().StringVar(&filter.TitleRegex, "title-regex", "", "Only import notes whose title matches this regular expression.")
+	rootCmd.PersistentFlags().StringVar(&filter.CreatedAfter, "created-after", "", "Only import notes created on or after this date (YYYY-MM-DD).")
+	rootCmd.PersistentFlags().StringVar(&filter.CreatedBefore, "created-before", "", "Only import notes created before this date (YYYY-MM-DD).")
+	rootCmd.PersistentFlags().StringVar(&filter.MinSize, "min-size", "", "Skip attachments smaller than this size, e.g. 10KB.")
+	rootCmd.PersistentFlags().StringVar(&filter.MaxSize, "max-size", "", "Skip attachments larger than this size, e.g. 20MB.")
+	rootCmd.PersistentFlags().BoolVar(&filter.HasSourceURL, "has-source-url", false, "Only import notes with a source URL.")
+
+	// add subcommands
+	rootCmd.AddCommand(newConfigCmd())
+	rootCmd.AddCommand(newWatchCmd())
+	rootCmd.AddCommand(newServeCmd())
 
 	// run root command
 	err := rootCmd.Execute()
@@ -101,45 +137,39 @@ func main() {
 
 func importENEX(cmd *cobra.Command, args []string) {
 	slog.Debug("starting importENEX")
-	settings, _ := config.GetConfig()
-
-	// Apply flag overrides to config
-	if outputfolder != "" {
-		settings.OutputFolder = outputfolder
-	}
-
-	if useFilenameAsTag {
-		baseName := filepath.Base(args[0])
-		tagName := strings.TrimSuffix(baseName, filepath.Ext(baseName))
-		tags = append(tags, tagName)
-	}
-	if len(tags) > 0 {
-		settings.AdditionalTags = tags
+	settings, err := loadSettings()
+	if err != nil {
+		slog.Error("invalid configuration", "error", err)
+		os.Exit(1)
 	}
 
 	if settings.OutputFolder != "" {
 		slog.Info(fmt.Sprintf("Output to local storage is enabled. Target is: %v", settings.OutputFolder))
+	} else {
+		// check the connection before reading the ENEX file
+		settings, err = paperless.Connect(settings)
+		if err != nil {
+			slog.Error("preflight check failed", "error", err)
+			os.Exit(1)
+		}
 	}
 
-	// Prepare input file with initialized channels
-	filePath := args[0]
-	inputFile := enex.NewEnexFile(filePath, settings)
+	// stdin carries the ENEX export and can't be prompted
+	retryPrompt := promptRetry
+	if slices.Contains(inputFiles, enex.StdinPath) {
+		retryPrompt = enex.RetryOnce()
+	}
 
-	// Process the ENEX file with retry prompts
-	result, err := inputFile.Process(enex.ProcessOptions{
+	// Process the ENEX files with retry prompts
+	result, err := enex.ProcessFiles(newEnexFiles(inputFiles, settings), enex.ProcessOptions{
 		ConcurrentWorkers: howMany,
 		OutputFolder:      settings.OutputFolder,
-		RetryPromptFunc: func(failedCount int) bool {
-			// Prompt user whether to retry failed notes
-			slog.Warn("there have been errors, starting retry cycle", "errors", failedCount)
-			PressKeyToContinue()
-			return true
-		},
+		RetryPromptFunc:   retryPrompt,
 	})
 
 	if err != nil {
 		slog.Error("processing completed with errors", "error", err)
-		if len(result.FailedNotes) > 0 {
+		if result != nil && len(result.FailedNotes) > 0 {
 			slog.Error("some notes could not be processed", "failedCount", len(result.FailedNotes))
 		}
 		os.Exit(1)
@@ -148,6 +178,110 @@ func importENEX(cmd *cobra.Command, args []string) {
 	slog.Info("all notes processed successfully")
 }
 
+// loadSettings reads the configuration and applies the flag overrides
+func loadSettings() (config.Config, error) {
+	config.SetOptions(configFile, profile)
+	settings, err := config.GetConfig()
+	if err != nil {
+		return settings, err
+	}
+
+	// Apply flag overrides to config
+	if outputfolder != "" {
+		settings.OutputFolder = outputfolder
+	}
+
+	if renderNotes {
+		settings.RenderNotes = true
+	}
+
+	if combineImages {
+		settings.CombineImages = true
+	}
+
+	if mergeAttachments {
+		settings.MergeAttachments = true
+	}
+
+	if consumeLayout {
+		settings.ConsumeLayout = true
+	}
+
+	if documentImporter {
+		settings.DocumentImporter = true
+	}
+
+	applyFilterFlags(&settings.Filter)
+	if err := settings.Filter.Validate(); err != nil {
+		return settings, fmt.Errorf("invalid filter: %w", err)
+	}
+
+	if settings.ConsumeLayout && settings.OutputFolder == "" {
+		return settings, errors.New("the consume layout requires an output folder")
+	}
+
+	if settings.DocumentImporter && settings.OutputFolder == "" {
+		return settings, errors.New("the document importer export requires an output folder")
+	}
+
+	if settings.DocumentImporter && settings.ConsumeLayout {
+		return settings, errors.New("the document importer export and the consume layout can't be combined")
+	}
+
+	return settings, nil
+}
+
+// newEnexFiles prepares the input files with initialized channels, each with its own notebook tag
+func newEnexFiles(filePaths []string, settings config.Config) []*enex.EnexFile {
+	// like -t, the notebook tag replaces the configured additional tags
+	if len(tags) > 0 || useFilenameAsTag {
+		settings.AdditionalTags = slices.Clone(tags)
+	}
+
+	var files []*enex.EnexFile
+	for _, filePath := range filePaths {
+		file := enex.NewEnexFile(filePath, settings)
+		file.NotebookTag = useFilenameAsTag
+		files = append(files, file)
+	}
+	return files
+}
+
+// applyFilterFlags overrides the configured filter with the filter flags that are set
+func applyFilterFlags(f *config.Filter) {
+	if filter.IncludeTags != nil {
+		f.IncludeTags = filter.IncludeTags
+	}
+	if filter.ExcludeTags != nil {
+		f.ExcludeTags = filter.ExcludeTags
+	}
+	if filter.TitleRegex != "" {
+		f.TitleRegex = filter.TitleRegex
+	}
+	if filter.CreatedAfter != "" {
+		f.CreatedAfter = filter.CreatedAfter
+	}
+	if filter.CreatedBefore != "" {
+		f.CreatedBefore = filter.CreatedBefore
+	}
+	if filter.MinSize != "" {
+		f.MinSize = filter.MinSize
+	}
+	if filter.MaxSize != "" {
+		f.MaxSize = filter.MaxSize
+	}
+	if filter.HasSourceURL {
+		f.HasSourceURL = true
+	}
+}
+
+// promptRetry asks the user whether to retry failed notes
+func promptRetry(failedCount int) bool {
+	slog.Warn("there have been errors, starting retry cycle", "errors", failedCount)
+	PressKeyToContinue()
+	return true
+}
+
 func PressKeyToContinue() {
 	fmt.Println("Press 'x' to exit or any other key to continue.")
 	for {
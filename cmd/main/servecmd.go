@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"enex2paperless/internal/config"
+	"enex2paperless/pkg/server"
+
+	"github.com/spf13/cobra"
+)
+
+// newServeCmd returns the serve command that runs the HTTP API for import jobs
+func newServeCmd() *cobra.Command {
+	var listen, apiToken, maxUploadSize string
+	var jobRetention time.Duration
+
+	serveCmd := &cobra.Command{
+		Use:          "serve",
+		Short:        "Run a web interface and HTTP API for import jobs",
+		Long:         `Starts an HTTP server with a web interface to select and edit the notes of an ENEX file before importing them, and an API to post ENEX files, follow the progress of the import jobs, get their reports and cancel them. Jobs are processed one after another with the configuration and the flags, the tags and the output can be set per job.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if howMany < 1 {
+				return fmt.Errorf("concurrent workers must be at least 1, got %d", howMany)
+			}
+			if apiToken == "" {
+				apiToken = os.Getenv("E2P_API_TOKEN")
+			}
+			maxSize, err := config.ParseSize(maxUploadSize)
+			if err != nil || maxSize == 0 {
+				return fmt.Errorf("invalid --max-upload-size %q", maxUploadSize)
+			}
+			if jobRetention <= 0 {
+				return fmt.Errorf("job retention must be positive, got %s", jobRetention)
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return serve(ctx, listen, server.Options{
+				Workers:       howMany,
+				Token:         apiToken,
+				MaxUploadSize: maxSize,
+				JobRetention:  jobRetention,
+			})
+		},
+	}
+
+	serveCmd.Flags().StringVar(&listen, "listen", "127.0.0.1:8080", "Address the HTTP server listens on.")
+	serveCmd.Flags().StringVar(&apiToken, "api-token", "", "Bearer token required by the API. Read from E2P_API_TOKEN if not set.")
+	serveCmd.Flags().StringVar(&maxUploadSize, "max-upload-size", "1GB", "Maximum size of an uploaded ENEX file, e.g. 500MB.")
+	serveCmd.Flags().DurationVar(&jobRetention, "job-retention", server.DefaultJobRetention, "How long finished jobs and drafts that aren't started are kept.")
+
+	return serveCmd
+}
+
+// serve runs the HTTP server until the context is canceled
+func serve(ctx context.Context, listen string, opts server.Options) error {
+	settings, err := loadSettings()
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if len(tags) > 0 {
+		settings.AdditionalTags = tags
+	}
+
+	srv, err := server.New(settings, opts)
+	if err != nil {
+		return err
+	}
+
+	// check the connection before accepting jobs that upload to Paperless
+	if settings.OutputFolder == "" {
+		if _, err := srv.Connect(); err != nil {
+			srv.Close()
+			return err
+		}
+	}
+
+	if opts.Token == "" {
+		slog.Warn("the API doesn't require a token, set --api-token if the server is reachable by others")
+	}
+
+	httpServer := &http.Server{
+		Addr:              listen,
+		Handler:           srv.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		slog.Info("serving HTTP API", "address", listen)
+		errs <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		srv.Close()
+		return fmt.Errorf("HTTP server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("failed to shut down HTTP server", "error", err)
+	}
+	return srv.Close()
+}
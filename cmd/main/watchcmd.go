@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"enex2paperless/pkg/enex"
+	"enex2paperless/pkg/paperless"
+	"enex2paperless/pkg/watch"
+
+	"github.com/spf13/cobra"
+)
+
+// newWatchCmd returns the watch command that imports ENEX files dropped into a folder
+func newWatchCmd() *cobra.Command {
+	var settleTime time.Duration
+
+	watchCmd := &cobra.Command{
+		Use:          "watch <dir>",
+		Short:        "Import ENEX files dropped into a folder",
+		Long:         `Watches a folder and imports every ENEX file that is copied into it, once the file has been fully written. Imported files are moved to the done subfolder, files with errors to the failed subfolder, each with a report next to it. Runs until interrupted.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if howMany < 1 {
+				return fmt.Errorf("concurrent workers must be at least 1, got %d", howMany)
+			}
+			if settleTime <= 0 {
+				return fmt.Errorf("settle time must be positive, got %s", settleTime)
+			}
+
+			info, err := os.Stat(args[0])
+			if err != nil {
+				return fmt.Errorf("cannot access watch folder: %w", err)
+			}
+			if !info.IsDir() {
+				return fmt.Errorf("watch folder is not a directory: %s", args[0])
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return watchFolder(ctx, args[0], settleTime)
+		},
+	}
+
+	watchCmd.Flags().DurationVar(&settleTime, "settle", watch.DefaultSettleTime, "How long a new file has to stay unchanged before it is imported.")
+
+	return watchCmd
+}
+
+// watchFolder checks the configuration and the connection once and imports the
+// files of the folder until the context is canceled
+func watchFolder(ctx context.Context, dir string, settleTime time.Duration) error {
+	settings, err := loadSettings()
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	if settings.OutputFolder != "" {
+		slog.Info(fmt.Sprintf("Output to local storage is enabled. Target is: %v", settings.OutputFolder))
+	} else {
+		settings, err = paperless.Connect(settings)
+		if err != nil {
+			return fmt.Errorf("preflight check failed: %w", err)
+		}
+	}
+
+	watcher := watch.NewWatcher(dir, func(ctx context.Context, filePath string) (*enex.ProcessResult, error) {
+		// nobody is at the terminal to answer a retry prompt
+		return enex.ProcessFiles(newEnexFiles([]string{filePath}, settings), enex.ProcessOptions{
+			ConcurrentWorkers: howMany,
+			OutputFolder:      settings.OutputFolder,
+			RetryPromptFunc:   enex.RetryOnce(),
+			Context:           ctx,
+		})
+	})
+	watcher.SettleTime = settleTime
+
+	return watcher.Run(ctx)
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// AuthModes besides the default token or username and password authentication
+const (
+	// AuthModeRemoteUser sends the username in a header, for Paperless behind a
+	// reverse proxy with header-based SSO like Authelia or Authentik
+	AuthModeRemoteUser = "remoteuser"
+	// AuthModeSession logs in with username and password and uses the session
+	// cookie and CSRF token like the Paperless web interface
+	AuthModeSession = "session"
+)
+
+// DefaultRemoteUserHeader is the header that holds the username in remoteuser mode
+const DefaultRemoteUserHeader = "Remote-User"
+
+// validateAuth checks that the credentials fit the authentication mode and that
+// the cookies are name=value pairs
+func validateAuth(mode, username, password, token string, cookies []string) error {
+	switch mode {
+	case AuthModeRemoteUser:
+		if username == "" {
+			return errors.New("bad auth config: remoteuser mode needs the username")
+		}
+	case AuthModeSession:
+		if username == "" || password == "" {
+			return errors.New("bad auth config: session mode needs username and password")
+		}
+	default:
+		if password != "" && username == "" {
+			return errors.New("if using password, username is required too")
+		}
+		if username != "" && password == "" {
+			return errors.New("if using username, password is required too")
+		}
+		if token == "" && password == "" {
+			return errors.New("bad auth config: need either token or username/password")
+		}
+	}
+
+	for _, cookie := range cookies {
+		if name, _, ok := strings.Cut(cookie, "="); !ok || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("invalid cookie %q, expected name=value", cookie)
+		}
+	}
+	return nil
+}
@@ -1,9 +1,15 @@
 package config
 
 import (
+	"enex2paperless/internal/logging"
+	"enex2paperless/pkg/templates"
 	"errors"
 	"fmt"
 	"log/slog"
+	"os"
+	"path"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
 
@@ -19,16 +25,223 @@ var (
 	once         sync.Once
 	globalConfig Config
 	initErr      error
+
+	// set by SetOptions
+	configFile    string
+	configProfile string
 )
 
 type Config struct {
 	PaperlessAPI   string   `koanf:"paperlessapi" validate:"required,http_url"`
-	Username       string   `koanf:"username" validate:"required_with=Password"`
-	Password       string   `koanf:"password" validate:"required_with=Username"`
-	Token          string   `koanf:"token" validate:"required_without=Password"`
+	Username       string   `koanf:"username"`
+	Password       string   `koanf:"password"`
+	Token          string   `koanf:"token"`
 	FileTypes      []string `koanf:"filetypes" validate:"required"`
 	OutputFolder   string   `koanf:"outputfolder"`
 	AdditionalTags []string `koanf:"additionaltags"`
+	Rules          []Rule   `koanf:"rules"`
+
+	// Filter selects the notes that are imported
+	Filter Filter `koanf:"filter"`
+
+	// TokenFile and PasswordFile read the secrets from files, e.g. Docker secrets
+	TokenFile    string `koanf:"tokenfile"`
+	PasswordFile string `koanf:"passwordfile"`
+
+	// AuthMode selects how requests are authenticated, see AuthModes. By default, the
+	// token or username and password are used.
+	AuthMode string `koanf:"authmode" validate:"omitempty,oneof=remoteuser session"`
+	// RemoteUserHeader is the header that holds the username in remoteuser mode
+	RemoteUserHeader string `koanf:"remoteuserheader"`
+	// Headers are added to every request, e.g. for a reverse proxy
+	Headers map[string]string `koanf:"headers"`
+	// Cookies are name=value pairs added to every request
+	Cookies []string `koanf:"cookies"`
+
+	CustomFields []CustomField `koanf:"customfields"`
+	// CustomFieldsOnUpload sends custom field values with the upload (Paperless-ngx 2.15+)
+	// instead of applying them after the document has been consumed. Older versions
+	// detected by the connection check fall back to applying them after consumption.
+	CustomFieldsOnUpload bool `koanf:"customfieldsonupload"`
+	// ConsumptionTimeout is the number of seconds to wait for Paperless to consume
+	// an uploaded document when its ID is needed
+	ConsumptionTimeout int `koanf:"consumptiontimeout"`
+
+	// NoteBody attaches the note text as Paperless note to the uploaded documents
+	NoteBody bool `koanf:"notebody"`
+	// NoteBodyMetadata adds the Evernote timestamps and source URL to the note text
+	NoteBodyMetadata bool `koanf:"notebodymetadata"`
+
+	// RenderNotes renders notes without attachments of a wanted file type to PDF
+	RenderNotes bool `koanf:"rendernotes"`
+
+	// CombineImages bundles the images of a note into one multi-page PDF
+	CombineImages bool `koanf:"combineimages"`
+	// CombineImagesCover adds a cover page with the note text to combined images
+	CombineImagesCover bool `koanf:"combineimagescover"`
+
+	// MergeAttachments bundles a cover page with the note details and text and
+	// all PDF and image attachments of a note into one PDF
+	MergeAttachments bool `koanf:"mergeattachments"`
+
+	// TitleTemplate and FilenameTemplate are text/template templates for the
+	// titles and file names of imported documents, see templates.Data
+	TitleTemplate    string `koanf:"titletemplate"`
+	FilenameTemplate string `koanf:"filenametemplate"`
+	// PathTemplate is a text/template template for the path of files saved to the
+	// output folder, relative to it. Slashes separate directories.
+	PathTemplate string `koanf:"pathtemplate"`
+
+	// Sidecar writes a metadata file in the given format (json or yaml) next to
+	// every file saved to the output folder
+	Sidecar string `koanf:"sidecar" validate:"omitempty,oneof=json yaml"`
+
+	// ConsumeLayout lays out the output folder for the Paperless consumption directory:
+	// tags become subdirectories and the created date prefixes the file name
+	ConsumeLayout bool `koanf:"consumelayout"`
+
+	// DocumentImporter writes manifest.json to the output folder, so that it can
+	// be imported with the Paperless document_importer
+	DocumentImporter bool `koanf:"documentimporter"`
+
+	// Mirrors are additional Paperless instances that receive a copy of every upload
+	Mirrors []Mirror `koanf:"mirrors"`
+}
+
+// Rule assigns a Paperless document type and/or storage path to documents
+// whose note and attachment match all of the given conditions.
+// Empty conditions always match.
+type Rule struct {
+	// Tags lists note tags that must all be present (case-insensitive)
+	Tags []string `koanf:"tags"`
+	// Title is a regular expression matched against the note title
+	Title string `koanf:"title"`
+	// MimeType is a glob matched against the attachment MIME type, e.g. image/*
+	MimeType string `koanf:"mimetype"`
+	// EnexFile is a glob matched against the base name of the source ENEX file
+	EnexFile string `koanf:"enexfile"`
+
+	DocumentType string `koanf:"documenttype"`
+	StoragePath  string `koanf:"storagepath"`
+}
+
+// Mirror is an additional Paperless instance with its own credentials
+type Mirror struct {
+	// Name identifies the mirror in logs and the summary, defaults to the API URL
+	Name         string `koanf:"name"`
+	PaperlessAPI string `koanf:"paperlessapi"`
+	Username     string `koanf:"username"`
+	Password     string `koanf:"password"`
+	Token        string `koanf:"token"`
+	PasswordFile string `koanf:"passwordfile"`
+	TokenFile    string `koanf:"tokenfile"`
+
+	AuthMode         string            `koanf:"authmode"`
+	RemoteUserHeader string            `koanf:"remoteuserheader"`
+	Headers          map[string]string `koanf:"headers"`
+	Cookies          []string          `koanf:"cookies"`
+}
+
+// validate checks the API URL, the auth mode and that either a token or username
+// and password are set
+func (m Mirror) validate() error {
+	if err := validator.New().Var(m.PaperlessAPI, "required,http_url"); err != nil {
+		return fmt.Errorf("invalid paperlessapi %q", m.PaperlessAPI)
+	}
+	if err := validator.New().Var(m.AuthMode, "omitempty,oneof=remoteuser session"); err != nil {
+		return fmt.Errorf("invalid authmode %q", m.AuthMode)
+	}
+	return validateAuth(m.AuthMode, m.Username, m.Password, m.Token, m.Cookies)
+}
+
+// MirrorConfig returns the configuration for uploads to a mirror. All settings
+// but the API URL and the credentials are shared with the main instance.
+func (c Config) MirrorConfig(m Mirror) Config {
+	c.PaperlessAPI = m.PaperlessAPI
+	c.Username = m.Username
+	c.Password = m.Password
+	c.Token = m.Token
+	c.AuthMode = m.AuthMode
+	c.RemoteUserHeader = m.RemoteUserHeader
+	c.Headers = m.Headers
+	c.Cookies = m.Cookies
+	c.Mirrors = nil
+	return c
+}
+
+// CustomField maps an Evernote attribute to a Paperless custom field
+type CustomField struct {
+	// Name is the name of the custom field in Paperless
+	Name string `koanf:"name"`
+	// Source is the Evernote attribute, see CustomFieldSources
+	Source string `koanf:"source"`
+	// DataType is the Paperless data type used when the field has to be created.
+	// Defaults to the data type of the source.
+	DataType string `koanf:"datatype"`
+}
+
+// CustomFieldSources lists the Evernote attributes that can be mapped to
+// custom fields, together with their default Paperless data type
+var CustomFieldSources = map[string]string{
+	"sourceurl":         "url",
+	"source":            "string",
+	"sourceapplication": "string",
+	"author":            "string",
+	"location":          "string",
+	"placename":         "string",
+	"gps":               "string",
+	"latitude":          "float",
+	"longitude":         "float",
+	"altitude":          "float",
+	"cameramake":        "string",
+	"cameramodel":       "string",
+	"created":           "date",
+	"updated":           "date",
+	"subjectdate":       "date",
+	"filename":          "string",
+	"enexfile":          "string",
+}
+
+// customFieldDataTypes lists the Paperless data types values can be mapped to
+var customFieldDataTypes = []string{"string", "url", "date", "boolean", "integer", "float", "monetary"}
+
+// Type returns the configured data type or the default data type of the source
+func (f CustomField) Type() string {
+	if f.DataType != "" {
+		return strings.ToLower(f.DataType)
+	}
+	return CustomFieldSources[strings.ToLower(f.Source)]
+}
+
+// validate checks that the custom field has a name, a known source and data type
+func (f CustomField) validate() error {
+	if f.Name == "" {
+		return fmt.Errorf("custom field needs a name")
+	}
+	if _, ok := CustomFieldSources[strings.ToLower(f.Source)]; !ok {
+		return fmt.Errorf("unknown source %q", f.Source)
+	}
+	if !slices.Contains(customFieldDataTypes, f.Type()) {
+		return fmt.Errorf("unsupported data type %q", f.DataType)
+	}
+	return nil
+}
+
+// validate checks that the rule assigns something and that its patterns compile
+func (r Rule) validate() error {
+	if r.DocumentType == "" && r.StoragePath == "" {
+		return fmt.Errorf("rule must set documenttype or storagepath")
+	}
+	if _, err := regexp.Compile(r.Title); err != nil {
+		return fmt.Errorf("invalid title regex: %w", err)
+	}
+	if _, err := path.Match(r.MimeType, ""); err != nil {
+		return fmt.Errorf("invalid mimetype pattern: %w", err)
+	}
+	if _, err := path.Match(r.EnexFile, ""); err != nil {
+		return fmt.Errorf("invalid enexfile pattern: %w", err)
+	}
+	return nil
 }
 
 // Validate validates the configuration using struct tags
@@ -40,20 +253,53 @@ func (c Config) Validate() error {
 		var validateErrs validator.ValidationErrors
 		if errors.As(err, &validateErrs) {
 			for _, e := range validateErrs {
-				switch e.StructField() {
-				case "Token":
-					return fmt.Errorf("bad auth config: need either token or username/password")
-				case "Username":
-					return fmt.Errorf("if using password, username is required too")
-				case "Password":
-					return fmt.Errorf("if using username, password is required too")
-				default:
-					return fmt.Errorf("field %s: %s validation failed", e.Field(), e.Tag())
-				}
+				return fmt.Errorf("field %s: %s validation failed", e.Field(), e.Tag())
 			}
 		}
 		return fmt.Errorf("configuration error: %w", err)
 	}
+
+	if err := validateAuth(c.AuthMode, c.Username, c.Password, c.Token, c.Cookies); err != nil {
+		return err
+	}
+
+	for i, rule := range c.Rules {
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+
+	if err := c.Filter.Validate(); err != nil {
+		return fmt.Errorf("filter: %w", err)
+	}
+
+	for _, field := range c.CustomFields {
+		if err := field.validate(); err != nil {
+			return fmt.Errorf("custom field %q: %w", field.Name, err)
+		}
+	}
+
+	for i, mirror := range c.Mirrors {
+		if err := mirror.validate(); err != nil {
+			return fmt.Errorf("mirror %d: %w", i+1, err)
+		}
+	}
+
+	if c.TitleTemplate != "" {
+		if _, err := templates.Parse("title", c.TitleTemplate); err != nil {
+			return err
+		}
+	}
+	if c.FilenameTemplate != "" {
+		if _, err := templates.Parse("filename", c.FilenameTemplate); err != nil {
+			return err
+		}
+	}
+	if c.PathTemplate != "" {
+		if _, err := templates.Parse("path", c.PathTemplate); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -62,6 +308,12 @@ func (c Config) Validate() error {
 // overrides with environment variables (using the provided prefix), and returns a validated Config.
 // This function is stateless and can be called multiple times (though typically called once at startup).
 func LoadConfig(fileProvider koanf.Provider, envPrefix string) (Config, error) {
+	return LoadProfile(fileProvider, "", envPrefix)
+}
+
+// LoadProfile works like LoadConfig, but applies the settings of a profile from the
+// profiles section on top of the base settings. Environment variables override both.
+func LoadProfile(fileProvider koanf.Provider, profile string, envPrefix string) (Config, error) {
 	var cfg Config
 	k := koanf.New(".")
 
@@ -71,6 +323,27 @@ func LoadConfig(fileProvider koanf.Provider, envPrefix string) (Config, error) {
 		slog.Debug("couldn't read config file", "error", err)
 	}
 
+	// Keys are case-insensitive, so that profiles and environment variables
+	// override the same keys as the base settings
+	base := lowerKeys(k.Raw())
+	profiles, _ := base["profiles"].(map[string]any)
+	delete(base, "profiles")
+
+	k = koanf.New(".")
+	if err := k.Load(mapProvider(base), nil); err != nil {
+		return Config{}, fmt.Errorf("configuration error: %w", err)
+	}
+
+	if profile != "" {
+		settings, ok := profiles[strings.ToLower(profile)].(map[string]any)
+		if !ok {
+			return Config{}, fmt.Errorf("profile %q not found in config file", profile)
+		}
+		if err := k.Load(mapProvider(settings), nil); err != nil {
+			return Config{}, fmt.Errorf("error loading profile %q: %w", profile, err)
+		}
+	}
+
 	// Load Environment Variables and override YAML settings
 	err = k.Load(env.Provider(".", env.Opt{
 		Prefix: envPrefix,
@@ -97,6 +370,12 @@ func LoadConfig(fileProvider koanf.Provider, envPrefix string) (Config, error) {
 		return Config{}, fmt.Errorf("configuration error: %w", err)
 	}
 
+	// Read secrets from files and mask them in logs
+	err = cfg.readSecrets()
+	if err != nil {
+		return Config{}, err
+	}
+
 	// Validate Config
 	err = cfg.Validate()
 	if err != nil {
@@ -106,13 +385,93 @@ func LoadConfig(fileProvider koanf.Provider, envPrefix string) (Config, error) {
 	return cfg, nil
 }
 
+// readSecrets sets the token and passwords of the main instance and the mirrors
+// from their files and registers all secrets for redaction in logs
+func (c *Config) readSecrets() error {
+	if err := readSecretFile(&c.Token, c.TokenFile, "token"); err != nil {
+		return err
+	}
+	if err := readSecretFile(&c.Password, c.PasswordFile, "password"); err != nil {
+		return err
+	}
+	logging.RegisterSecret(c.Token)
+	logging.RegisterSecret(c.Password)
+	registerHeaderSecrets(c.Headers, c.Cookies)
+
+	for i := range c.Mirrors {
+		m := &c.Mirrors[i]
+		if err := readSecretFile(&m.Token, m.TokenFile, "token"); err != nil {
+			return fmt.Errorf("mirror %d: %w", i+1, err)
+		}
+		if err := readSecretFile(&m.Password, m.PasswordFile, "password"); err != nil {
+			return fmt.Errorf("mirror %d: %w", i+1, err)
+		}
+		logging.RegisterSecret(m.Token)
+		logging.RegisterSecret(m.Password)
+		registerHeaderSecrets(m.Headers, m.Cookies)
+	}
+	return nil
+}
+
+// registerHeaderSecrets registers the values of sensitive headers, e.g. API keys
+// of a proxy, and of all cookies for redaction in logs
+func registerHeaderSecrets(headers map[string]string, cookies []string) {
+	for name, value := range headers {
+		if logging.IsSensitive(name) {
+			logging.RegisterSecret(value)
+		}
+	}
+	for _, cookie := range cookies {
+		_, value, _ := strings.Cut(cookie, "=")
+		logging.RegisterSecret(value)
+	}
+}
+
+// readSecretFile sets value to the content of file, without surrounding whitespace
+func readSecretFile(value *string, file, name string) error {
+	if file == "" {
+		return nil
+	}
+	if *value != "" {
+		return fmt.Errorf("%s and %sfile can't be used together", name, name)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("error reading %sfile: %w", name, err)
+	}
+
+	*value = strings.TrimSpace(string(data))
+	if *value == "" {
+		return fmt.Errorf("%sfile %s is empty", name, file)
+	}
+	return nil
+}
+
+// SetOptions sets the config file and the profile used by GetConfig. An empty
+// path looks up the config file with FindConfigFile. It has no effect after the
+// configuration has been loaded.
+func SetOptions(path, profile string) {
+	configFile = path
+	configProfile = profile
+}
+
 // GetConfig loads configuration using the singleton pattern with sync.Once.
-// It uses the default config.yaml file and E2P_ environment variable prefix.
+// It uses the config file and profile set with SetOptions and the E2P_ environment variable prefix.
 // The configuration is loaded only once and cached for the lifetime of the application.
 // For better testability and dependency injection, prefer using LoadConfig directly.
 func GetConfig() (Config, error) {
 	once.Do(func() {
-		globalConfig, initErr = LoadConfig(file.Provider("config.yaml"), "E2P_")
+		path := configFile
+		if path == "" {
+			path = FindConfigFile()
+		} else if _, err := os.Stat(path); err != nil {
+			initErr = fmt.Errorf("cannot access config file: %w", err)
+			return
+		}
+
+		slog.Debug("loading config file", "file", path, "profile", configProfile)
+		globalConfig, initErr = LoadProfile(file.Provider(path), configProfile, "E2P_")
 	})
 	return globalConfig, initErr
 }
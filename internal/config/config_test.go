@@ -2,7 +2,12 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/providers/fs"
@@ -119,6 +124,147 @@ filetypes:
 			name: "validation error - missing required fields",
 			yamlContent: `
 paperlessapi: https://example.com/api
+`,
+			envVars:     map[string]string{},
+			envPrefix:   "E2P_",
+			expectError: true,
+		},
+		{
+			name: "loads assignment rules",
+			yamlContent: `
+paperlessapi: https://example.com/api
+token: test-token
+filetypes:
+  - pdf
+rules:
+  - tags: [tax]
+    title: "(?i)invoice"
+    documenttype: Invoice
+    storagepath: Finance
+`,
+			envVars:   map[string]string{},
+			envPrefix: "E2P_",
+			expectedConfig: Config{
+				PaperlessAPI: "https://example.com/api",
+				Token:        "test-token",
+				FileTypes:    []string{"pdf"},
+				Rules: []Rule{
+					{Tags: []string{"tax"}, Title: "(?i)invoice", DocumentType: "Invoice", StoragePath: "Finance"},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "loads filter",
+			yamlContent: `
+paperlessapi: https://example.com/api
+token: test-token
+filetypes:
+  - pdf
+filter:
+  includetags: [tax]
+  excludetags: [draft]
+  createdafter: 2018-01-01
+  createdbefore: "2023-01-01"
+  maxsize: 20MB
+  hassourceurl: true
+`,
+			envVars:   map[string]string{},
+			envPrefix: "E2P_",
+			expectedConfig: Config{
+				PaperlessAPI: "https://example.com/api",
+				Token:        "test-token",
+				FileTypes:    []string{"pdf"},
+				Filter: Filter{
+					IncludeTags:   []string{"tax"},
+					ExcludeTags:   []string{"draft"},
+					CreatedAfter:  "2018-01-01",
+					CreatedBefore: "2023-01-01",
+					MaxSize:       "20MB",
+					HasSourceURL:  true,
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "validation error - filter with invalid date",
+			yamlContent: `
+paperlessapi: https://example.com/api
+token: test-token
+filetypes:
+  - pdf
+filter:
+  createdafter: 01.01.2018
+`,
+			envVars:     map[string]string{},
+			envPrefix:   "E2P_",
+			expectError: true,
+		},
+		{
+			name: "validation error - rule with invalid regex",
+			yamlContent: `
+paperlessapi: https://example.com/api
+token: test-token
+filetypes:
+  - pdf
+rules:
+  - title: "(unclosed"
+    documenttype: Invoice
+`,
+			envVars:     map[string]string{},
+			envPrefix:   "E2P_",
+			expectError: true,
+		},
+		{
+			name: "validation error - rule without assignment",
+			yamlContent: `
+paperlessapi: https://example.com/api
+token: test-token
+filetypes:
+  - pdf
+rules:
+  - tags: [tax]
+`,
+			envVars:     map[string]string{},
+			envPrefix:   "E2P_",
+			expectError: true,
+		},
+		{
+			name: "loads custom field mappings",
+			yamlContent: `
+paperlessapi: https://example.com/api
+token: test-token
+filetypes:
+  - pdf
+customfields:
+  - name: Source
+    source: sourceurl
+  - name: Camera
+    source: cameramodel
+`,
+			envVars:   map[string]string{},
+			envPrefix: "E2P_",
+			expectedConfig: Config{
+				PaperlessAPI: "https://example.com/api",
+				Token:        "test-token",
+				FileTypes:    []string{"pdf"},
+				CustomFields: []CustomField{
+					{Name: "Source", Source: "sourceurl"},
+					{Name: "Camera", Source: "cameramodel"},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "validation error - custom field with unknown source",
+			yamlContent: `
+paperlessapi: https://example.com/api
+token: test-token
+filetypes:
+  - pdf
+customfields:
+  - name: Mood
+    source: weather
 `,
 			envVars:     map[string]string{},
 			envPrefix:   "E2P_",
@@ -131,6 +277,174 @@ paperlessapi: not-a-valid-url
 token: test-token
 filetypes:
   - pdf
+`,
+			envVars:     map[string]string{},
+			envPrefix:   "E2P_",
+			expectError: true,
+		},
+		{
+			name: "loads title and filename templates",
+			yamlContent: `
+paperlessapi: https://example.com/api
+token: test-token
+filetypes:
+  - pdf
+titletemplate: "{{.Note.Title}} ({{.Index}}/{{.Count}})"
+filenametemplate: "{{.Year}}-{{.Month}}-{{.Day}} {{.BaseName}}"
+`,
+			envVars:   map[string]string{},
+			envPrefix: "E2P_",
+			expectedConfig: Config{
+				PaperlessAPI:     "https://example.com/api",
+				Token:            "test-token",
+				FileTypes:        []string{"pdf"},
+				TitleTemplate:    "{{.Note.Title}} ({{.Index}}/{{.Count}})",
+				FilenameTemplate: "{{.Year}}-{{.Month}}-{{.Day}} {{.BaseName}}",
+			},
+			expectError: false,
+		},
+		{
+			name: "validation error - template with unknown field",
+			yamlContent: `
+paperlessapi: https://example.com/api
+token: test-token
+filetypes:
+  - pdf
+titletemplate: "{{.Note.Name}}"
+`,
+			envVars:     map[string]string{},
+			envPrefix:   "E2P_",
+			expectError: true,
+		},
+		{
+			name: "validation error - unknown sidecar format",
+			yamlContent: `
+paperlessapi: https://example.com/api
+token: test-token
+filetypes:
+  - pdf
+sidecar: xml
+`,
+			envVars:     map[string]string{},
+			envPrefix:   "E2P_",
+			expectError: true,
+		},
+		{
+			name: "loads mirrors",
+			yamlContent: `
+paperlessapi: https://example.com/api
+token: test-token
+filetypes:
+  - pdf
+mirrors:
+  - name: backup
+    paperlessapi: https://backup.example.com/api
+    username: backup
+    password: secret
+`,
+			envVars:   map[string]string{},
+			envPrefix: "E2P_",
+			expectedConfig: Config{
+				PaperlessAPI: "https://example.com/api",
+				Token:        "test-token",
+				FileTypes:    []string{"pdf"},
+				Mirrors: []Mirror{
+					{Name: "backup", PaperlessAPI: "https://backup.example.com/api", Username: "backup", Password: "secret"},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "validation error - mirror without credentials",
+			yamlContent: `
+paperlessapi: https://example.com/api
+token: test-token
+filetypes:
+  - pdf
+mirrors:
+  - paperlessapi: https://backup.example.com/api
+`,
+			envVars:     map[string]string{},
+			envPrefix:   "E2P_",
+			expectError: true,
+		},
+		{
+			name: "validation error - mirror with unknown auth mode",
+			yamlContent: `
+paperlessapi: https://example.com/api
+token: test-token
+filetypes:
+  - pdf
+mirrors:
+  - paperlessapi: https://backup.example.com/api
+    authmode: sesion
+    username: user
+    password: secret
+`,
+			envVars:     map[string]string{},
+			envPrefix:   "E2P_",
+			expectError: true,
+		},
+		{
+			name: "remote user auth with proxy headers",
+			yamlContent: `
+PaperlessAPI: https://example.com/api
+AuthMode: remoteuser
+Username: jane
+FileTypes:
+  - pdf
+Headers:
+  X-Proxy-Key: key
+Cookies:
+  - authelia_session=xyz
+`,
+			envVars:   map[string]string{},
+			envPrefix: "E2P_",
+			expectedConfig: Config{
+				PaperlessAPI: "https://example.com/api",
+				AuthMode:     AuthModeRemoteUser,
+				Username:     "jane",
+				FileTypes:    []string{"pdf"},
+				Headers:      map[string]string{"x-proxy-key": "key"},
+				Cookies:      []string{"authelia_session=xyz"},
+			},
+			expectError: false,
+		},
+		{
+			name: "validation error - session auth without password",
+			yamlContent: `
+paperlessapi: https://example.com/api
+authmode: session
+username: jane
+filetypes:
+  - pdf
+`,
+			envVars:     map[string]string{},
+			envPrefix:   "E2P_",
+			expectError: true,
+		},
+		{
+			name: "validation error - unknown auth mode",
+			yamlContent: `
+paperlessapi: https://example.com/api
+authmode: kerberos
+token: test-token
+filetypes:
+  - pdf
+`,
+			envVars:     map[string]string{},
+			envPrefix:   "E2P_",
+			expectError: true,
+		},
+		{
+			name: "validation error - cookie without value",
+			yamlContent: `
+paperlessapi: https://example.com/api
+token: test-token
+filetypes:
+  - pdf
+cookies:
+  - authelia_session
 `,
 			envVars:     map[string]string{},
 			envPrefix:   "E2P_",
@@ -210,6 +524,58 @@ filetypes:
 				}
 			}
 
+			if len(cfg.Rules) != len(tt.expectedConfig.Rules) {
+				t.Errorf("Rules length = %d, want %d", len(cfg.Rules), len(tt.expectedConfig.Rules))
+			} else {
+				for i, rule := range cfg.Rules {
+					want := tt.expectedConfig.Rules[i]
+					if rule.Title != want.Title || rule.DocumentType != want.DocumentType || rule.StoragePath != want.StoragePath || len(rule.Tags) != len(want.Tags) {
+						t.Errorf("Rules[%d] = %+v, want %+v", i, rule, want)
+					}
+				}
+			}
+
+			if len(cfg.CustomFields) != len(tt.expectedConfig.CustomFields) {
+				t.Errorf("CustomFields length = %d, want %d", len(cfg.CustomFields), len(tt.expectedConfig.CustomFields))
+			} else {
+				for i, field := range cfg.CustomFields {
+					if field != tt.expectedConfig.CustomFields[i] {
+						t.Errorf("CustomFields[%d] = %+v, want %+v", i, field, tt.expectedConfig.CustomFields[i])
+					}
+				}
+			}
+
+			if cfg.TitleTemplate != tt.expectedConfig.TitleTemplate {
+				t.Errorf("TitleTemplate = %q, want %q", cfg.TitleTemplate, tt.expectedConfig.TitleTemplate)
+			}
+			if cfg.FilenameTemplate != tt.expectedConfig.FilenameTemplate {
+				t.Errorf("FilenameTemplate = %q, want %q", cfg.FilenameTemplate, tt.expectedConfig.FilenameTemplate)
+			}
+
+			if len(cfg.Mirrors) != len(tt.expectedConfig.Mirrors) {
+				t.Errorf("Mirrors length = %d, want %d", len(cfg.Mirrors), len(tt.expectedConfig.Mirrors))
+			} else {
+				for i, mirror := range cfg.Mirrors {
+					if !reflect.DeepEqual(mirror, tt.expectedConfig.Mirrors[i]) {
+						t.Errorf("Mirrors[%d] = %+v, want %+v", i, mirror, tt.expectedConfig.Mirrors[i])
+					}
+				}
+			}
+
+			if !reflect.DeepEqual(cfg.Filter, tt.expectedConfig.Filter) {
+				t.Errorf("Filter = %+v, want %+v", cfg.Filter, tt.expectedConfig.Filter)
+			}
+
+			if cfg.AuthMode != tt.expectedConfig.AuthMode {
+				t.Errorf("AuthMode = %q, want %q", cfg.AuthMode, tt.expectedConfig.AuthMode)
+			}
+			if !reflect.DeepEqual(cfg.Headers, tt.expectedConfig.Headers) {
+				t.Errorf("Headers = %v, want %v", cfg.Headers, tt.expectedConfig.Headers)
+			}
+			if !reflect.DeepEqual(cfg.Cookies, tt.expectedConfig.Cookies) {
+				t.Errorf("Cookies = %v, want %v", cfg.Cookies, tt.expectedConfig.Cookies)
+			}
+
 			if len(cfg.AdditionalTags) != len(tt.expectedConfig.AdditionalTags) {
 				t.Errorf("AdditionalTags length = %d, want %d", len(cfg.AdditionalTags), len(tt.expectedConfig.AdditionalTags))
 			} else {
@@ -222,3 +588,352 @@ filetypes:
 		})
 	}
 }
+
+func TestLoadProfile(t *testing.T) {
+	yamlContent := `
+PaperlessAPI: https://example.com/api
+Token: base-token
+FileTypes:
+  - pdf
+AdditionalTags:
+  - evernote
+
+profiles:
+  office:
+    paperlessapi: https://office.example.com/api
+    additionaltags:
+      - office
+  Home:
+    Token: home-token
+`
+
+	tests := []struct {
+		name           string
+		profile        string
+		envVars        map[string]string
+		expectedAPI    string
+		expectedToken  string
+		expectedTags   []string
+		expectedErrMsg string
+	}{
+		{
+			name:          "base settings without profile",
+			expectedAPI:   "https://example.com/api",
+			expectedToken: "base-token",
+			expectedTags:  []string{"evernote"},
+		},
+		{
+			name:          "profile overrides base settings",
+			profile:       "office",
+			expectedAPI:   "https://office.example.com/api",
+			expectedToken: "base-token",
+			expectedTags:  []string{"office"},
+		},
+		{
+			name:          "profile names are case-insensitive",
+			profile:       "home",
+			expectedAPI:   "https://example.com/api",
+			expectedToken: "home-token",
+			expectedTags:  []string{"evernote"},
+		},
+		{
+			name:          "environment variables override profile",
+			profile:       "office",
+			envVars:       map[string]string{"E2P_PAPERLESSAPI": "https://env.example.com/api"},
+			expectedAPI:   "https://env.example.com/api",
+			expectedToken: "base-token",
+			expectedTags:  []string{"office"},
+		},
+		{
+			name:           "unknown profile",
+			profile:        "garage",
+			expectedErrMsg: `profile "garage" not found in config file`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memFS := afero.NewMemMapFs()
+			if err := afero.WriteFile(memFS, "config.yaml", []byte(yamlContent), 0644); err != nil {
+				t.Fatalf("failed to write in-memory config file: %v", err)
+			}
+			for key, value := range tt.envVars {
+				t.Setenv(key, value)
+			}
+
+			cfg, err := LoadProfile(fs.Provider(afero.NewIOFS(memFS), "config.yaml"), tt.profile, "E2P_")
+			if tt.expectedErrMsg != "" {
+				if err == nil || err.Error() != tt.expectedErrMsg {
+					t.Errorf("error = %v, want %q", err, tt.expectedErrMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if cfg.PaperlessAPI != tt.expectedAPI {
+				t.Errorf("PaperlessAPI = %q, want %q", cfg.PaperlessAPI, tt.expectedAPI)
+			}
+			if cfg.Token != tt.expectedToken {
+				t.Errorf("Token = %q, want %q", cfg.Token, tt.expectedToken)
+			}
+			if len(cfg.AdditionalTags) != len(tt.expectedTags) || cfg.AdditionalTags[0] != tt.expectedTags[0] {
+				t.Errorf("AdditionalTags = %v, want %v", cfg.AdditionalTags, tt.expectedTags)
+			}
+		})
+	}
+}
+
+func TestFindConfigFile(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("XDG config dirs are only used on Linux")
+	}
+
+	userDir := t.TempDir()
+	systemDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", userDir)
+	t.Setenv("XDG_CONFIG_DIRS", systemDir)
+
+	// no config file in the working directory of the tests
+	if path := FindConfigFile(); path != "config.yaml" {
+		t.Errorf("FindConfigFile() = %q, want config.yaml", path)
+	}
+
+	systemConfig := filepath.Join(systemDir, "enex2paperless", "config.yaml")
+	writeFile(t, systemConfig)
+	if path := FindConfigFile(); path != systemConfig {
+		t.Errorf("FindConfigFile() = %q, want %q", path, systemConfig)
+	}
+
+	userConfig := filepath.Join(userDir, "enex2paperless", "config.yaml")
+	writeFile(t, userConfig)
+	if path := FindConfigFile(); path != userConfig {
+		t.Errorf("FindConfigFile() = %q, want %q", path, userConfig)
+	}
+}
+
+func writeFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("paperlessapi: https://example.com/api\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	passwordFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(passwordFile, []byte("mirror-password\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		yamlContent string
+		expectError bool
+	}{
+		{
+			name: "secrets read from files",
+			yamlContent: `
+paperlessapi: https://example.com/api
+tokenfile: ` + tokenFile + `
+filetypes: [pdf]
+mirrors:
+  - paperlessapi: https://backup.example.com/api
+    username: backup
+    passwordfile: ` + passwordFile + `
+`,
+		},
+		{
+			name: "token and token file together",
+			yamlContent: `
+paperlessapi: https://example.com/api
+token: test-token
+tokenfile: ` + tokenFile + `
+filetypes: [pdf]
+`,
+			expectError: true,
+		},
+		{
+			name: "missing token file",
+			yamlContent: `
+paperlessapi: https://example.com/api
+tokenfile: ` + filepath.Join(dir, "missing") + `
+filetypes: [pdf]
+`,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memFS := afero.NewMemMapFs()
+			if err := afero.WriteFile(memFS, "config.yaml", []byte(tt.yamlContent), 0644); err != nil {
+				t.Fatalf("failed to write in-memory config file: %v", err)
+			}
+
+			cfg, err := LoadConfig(fs.Provider(afero.NewIOFS(memFS), "config.yaml"), "E2P_")
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if cfg.Token != "file-token" {
+				t.Errorf("Token = %q, want file-token", cfg.Token)
+			}
+			if cfg.Mirrors[0].Password != "mirror-password" {
+				t.Errorf("mirror Password = %q, want mirror-password", cfg.Mirrors[0].Password)
+			}
+		})
+	}
+}
+
+// TestYAML verifies that printed configurations can be loaded again and that
+// masked configurations don't contain secrets
+func TestYAML(t *testing.T) {
+	cfg := Config{
+		PaperlessAPI:   "https://example.com",
+		Username:       "admin",
+		Password:       "admin-password",
+		FileTypes:      []string{"pdf", "png"},
+		AdditionalTags: []string{"evernote"},
+		NoteBody:       true,
+		Rules: []Rule{
+			{Tags: []string{"tax"}, DocumentType: "Tax"},
+		},
+		Mirrors: []Mirror{
+			{Name: "backup", PaperlessAPI: "https://backup.example.com", Token: "mirror-token"},
+		},
+	}
+
+	data, err := cfg.YAML()
+	if err != nil {
+		t.Fatalf("YAML() error: %v", err)
+	}
+
+	memFS := afero.NewMemMapFs()
+	if err := afero.WriteFile(memFS, "config.yaml", data, 0644); err != nil {
+		t.Fatalf("failed to write in-memory config file: %v", err)
+	}
+	loaded, err := LoadConfig(fs.Provider(afero.NewIOFS(memFS), "config.yaml"), "E2P_")
+	if err != nil {
+		t.Fatalf("failed to load printed config: %v\n%s", err, data)
+	}
+	if !reflect.DeepEqual(loaded, cfg) {
+		t.Errorf("loaded config differs:\ngot  %+v\nwant %+v", loaded, cfg)
+	}
+
+	masked, err := cfg.Masked().YAML()
+	if err != nil {
+		t.Fatalf("YAML() error: %v", err)
+	}
+	for _, secret := range []string{"admin-password", "mirror-token"} {
+		if strings.Contains(string(masked), secret) {
+			t.Errorf("masked config contains %q:\n%s", secret, masked)
+		}
+	}
+	if cfg.Mirrors[0].Token != "mirror-token" {
+		t.Error("Masked() changed the mirrors of the original config")
+	}
+}
+
+// TestParseSize tests parsing of attachment sizes with units
+// TestLowerKeys verifies that only the filter dates are turned back into text
+func TestLowerKeys(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	raw := map[string]any{
+		"Filter":  map[string]any{"CreatedAfter": date, "Other": date},
+		"Updated": date,
+		"Profiles": map[string]any{
+			"Office": map[string]any{"filter": map[string]any{"createdBefore": date}},
+		},
+	}
+
+	expected := map[string]any{
+		"filter":  map[string]any{"createdafter": "2018-01-01", "other": date},
+		"updated": date,
+		"profiles": map[string]any{
+			"office": map[string]any{"filter": map[string]any{"createdbefore": "2018-01-01"}},
+		},
+	}
+	if result := lowerKeys(raw); !reflect.DeepEqual(result, expected) {
+		t.Errorf("lowerKeys() = %v, expected %v", result, expected)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size        string
+		expected    int64
+		expectError bool
+	}{
+		{size: "512", expected: 512},
+		{size: "512B", expected: 512},
+		{size: "100kb", expected: 100 * 1024},
+		{size: "1.5 MB", expected: 3 * 512 * 1024},
+		{size: "2GB", expected: 2 << 30},
+		{size: "10TB", expectError: true},
+		{size: "MB", expectError: true},
+		{size: "-1KB", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.size, func(t *testing.T) {
+			size, err := ParseSize(tt.size)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected error but got %d", size)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if size != tt.expected {
+				t.Errorf("ParseSize(%q) = %d, want %d", tt.size, size, tt.expected)
+			}
+		})
+	}
+}
+
+// TestFilterValidate tests the validation of the note filter
+func TestFilterValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		filter      Filter
+		expectError bool
+	}{
+		{name: "empty filter", filter: Filter{}},
+		{name: "date range", filter: Filter{CreatedAfter: "2018-01-01", CreatedBefore: "2023-01-01"}},
+		{name: "size range", filter: Filter{MinSize: "10KB", MaxSize: "20MB"}},
+		{name: "invalid regex", filter: Filter{TitleRegex: "(unclosed"}, expectError: true},
+		{name: "invalid date", filter: Filter{CreatedBefore: "2023"}, expectError: true},
+		{name: "empty date range", filter: Filter{CreatedAfter: "2023-01-01", CreatedBefore: "2018-01-01"}, expectError: true},
+		{name: "invalid size", filter: Filter{MaxSize: "big"}, expectError: true},
+		{name: "empty size range", filter: Filter{MinSize: "20MB", MaxSize: "10KB"}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.filter.Validate()
+			if tt.expectError && err == nil {
+				t.Error("expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FilterDateFormat is the format of the creation dates of a Filter
+const FilterDateFormat = "2006-01-02"
+
+// Filter selects the notes that are imported. Notes must match all of the set
+// conditions, notes that don't match are skipped before their attachments are decoded.
+type Filter struct {
+	// IncludeTags imports only notes with at least one of the tags (case-insensitive)
+	IncludeTags []string `koanf:"includetags"`
+	// ExcludeTags skips notes with any of the tags (case-insensitive)
+	ExcludeTags []string `koanf:"excludetags"`
+	// TitleRegex is a regular expression matched against the note title
+	TitleRegex string `koanf:"titleregex"`
+
+	// CreatedAfter and CreatedBefore limit the creation date of the notes, see
+	// FilterDateFormat. CreatedAfter is inclusive, CreatedBefore is exclusive.
+	CreatedAfter  string `koanf:"createdafter"`
+	CreatedBefore string `koanf:"createdbefore"`
+
+	// MinSize and MaxSize limit the size of the attachments, e.g. 100KB or 20MB.
+	// Attachments outside of the range are skipped, and so are notes whose
+	// attachments are all skipped.
+	MinSize string `koanf:"minsize"`
+	MaxSize string `koanf:"maxsize"`
+
+	// HasSourceURL imports only notes with a source URL
+	HasSourceURL bool `koanf:"hassourceurl"`
+}
+
+// sizeUnits are the units of sizes, in bytes
+var sizeUnits = map[string]float64{
+	"":   1,
+	"B":  1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+}
+
+// ParseSize parses a size like 512, 100KB or 1.5MB into bytes. Units are
+// case-insensitive and multiples of 1024.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	number := strings.TrimRightFunc(s, func(r rune) bool {
+		return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+	})
+	unit, ok := sizeUnits[strings.ToUpper(s[len(number):])]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q: unknown unit", s)
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(value * unit), nil
+}
+
+// Validate checks that the regex compiles and that the dates and sizes can be parsed
+func (f Filter) Validate() error {
+	if _, err := regexp.Compile(f.TitleRegex); err != nil {
+		return fmt.Errorf("invalid title regex: %w", err)
+	}
+
+	var after, before time.Time
+	var err error
+	if f.CreatedAfter != "" {
+		if after, err = time.Parse(FilterDateFormat, f.CreatedAfter); err != nil {
+			return fmt.Errorf("invalid createdafter date %q, expected YYYY-MM-DD", f.CreatedAfter)
+		}
+	}
+	if f.CreatedBefore != "" {
+		if before, err = time.Parse(FilterDateFormat, f.CreatedBefore); err != nil {
+			return fmt.Errorf("invalid createdbefore date %q, expected YYYY-MM-DD", f.CreatedBefore)
+		}
+	}
+	if !after.IsZero() && !before.IsZero() && !after.Before(before) {
+		return errors.New("createdafter must be before createdbefore")
+	}
+
+	var minSize, maxSize int64
+	if f.MinSize != "" {
+		if minSize, err = ParseSize(f.MinSize); err != nil {
+			return fmt.Errorf("minsize: %w", err)
+		}
+	}
+	if f.MaxSize != "" {
+		if maxSize, err = ParseSize(f.MaxSize); err != nil {
+			return fmt.Errorf("maxsize: %w", err)
+		}
+		if maxSize < minSize {
+			return errors.New("minsize must not be larger than maxsize")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// appName is the directory of the config file in the user's and the system's config dirs
+const appName = "enex2paperless"
+
+// configFileName is the name of the config file
+const configFileName = "config.yaml"
+
+// FindConfigFile returns the first existing config file of:
+//   - config.yaml in the working directory
+//   - enex2paperless/config.yaml in the user's config dir ($XDG_CONFIG_HOME, ~/.config on Linux)
+//   - enex2paperless/config.yaml in the system config dirs ($XDG_CONFIG_DIRS, /etc/xdg)
+//
+// If none exists, config.yaml in the working directory is returned.
+func FindConfigFile() string {
+	for _, path := range configFileCandidates() {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return configFileName
+}
+
+// UserConfigFile returns the path of the config file in the user's config dir,
+// where `config init` writes new configurations
+func UserConfigFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine user config dir: %w", err)
+	}
+	return filepath.Join(dir, appName, configFileName), nil
+}
+
+// configFileCandidates lists the paths of possible config files in lookup order
+func configFileCandidates() []string {
+	candidates := []string{configFileName}
+
+	if path, err := UserConfigFile(); err == nil {
+		candidates = append(candidates, path)
+	}
+
+	dirs := os.Getenv("XDG_CONFIG_DIRS")
+	if dirs == "" && filepath.Separator == '/' {
+		dirs = "/etc/xdg"
+	}
+	for _, dir := range filepath.SplitList(dirs) {
+		if dir != "" {
+			candidates = append(candidates, filepath.Join(dir, appName, configFileName))
+		}
+	}
+
+	return candidates
+}
+
+// filterDateKeys are the date settings of the filter
+var filterDateKeys = map[string]bool{"createdafter": true, "createdbefore": true}
+
+// lowerKeys returns a copy of a config map with lowercase keys, including nested maps.
+// YAML parses unquoted dates like 2018-01-01 as timestamps, they are turned back
+// into text for the date settings of the filter, in the base settings and in profiles.
+func lowerKeys(m map[string]any) map[string]any {
+	return lowerNestedKeys("", m)
+}
+
+// lowerNestedKeys lowers the keys of the map stored under the parent key
+func lowerNestedKeys(parent string, m map[string]any) map[string]any {
+	lowered := make(map[string]any, len(m))
+	for key, value := range m {
+		key = strings.ToLower(key)
+		switch v := value.(type) {
+		case map[string]any:
+			value = lowerNestedKeys(key, v)
+		case time.Time:
+			if parent == "filter" && filterDateKeys[key] {
+				value = v.Format(FilterDateFormat)
+			}
+		}
+		lowered[key] = value
+	}
+	return lowered
+}
+
+var errUnsupported = errors.New("not supported by mapProvider")
+
+// mapProvider is a koanf.Provider for an already parsed config map
+type mapProvider map[string]any
+
+// ReadBytes isn't supported, the map is already parsed
+func (m mapProvider) ReadBytes() ([]byte, error) {
+	return nil, errUnsupported
+}
+
+// Read returns the config map
+func (m mapProvider) Read() (map[string]any, error) {
+	return m, nil
+}
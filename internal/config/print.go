@@ -0,0 +1,122 @@
+package config
+
+import (
+	"bytes"
+	"enex2paperless/internal/logging"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Masked returns a copy of the configuration with the token and passwords of
+// the main instance and the mirrors replaced, for printing
+func (c Config) Masked() Config {
+	mask := func(secret *string) {
+		if *secret != "" {
+			*secret = logging.Redacted
+		}
+	}
+
+	mask(&c.Token)
+	mask(&c.Password)
+	c.Headers, c.Cookies = maskHeaders(c.Headers, c.Cookies)
+
+	mirrors := make([]Mirror, len(c.Mirrors))
+	copy(mirrors, c.Mirrors)
+	for i := range mirrors {
+		mask(&mirrors[i].Token)
+		mask(&mirrors[i].Password)
+		mirrors[i].Headers, mirrors[i].Cookies = maskHeaders(mirrors[i].Headers, mirrors[i].Cookies)
+	}
+	if c.Mirrors != nil {
+		c.Mirrors = mirrors
+	}
+
+	return c
+}
+
+// maskHeaders returns copies of the headers and cookies with the values of
+// sensitive headers and all cookies replaced
+func maskHeaders(headers map[string]string, cookies []string) (map[string]string, []string) {
+	var maskedHeaders map[string]string
+	if headers != nil {
+		maskedHeaders = make(map[string]string, len(headers))
+		for name, value := range headers {
+			if logging.IsSensitive(name) {
+				value = logging.Redacted
+			}
+			maskedHeaders[name] = value
+		}
+	}
+
+	var maskedCookies []string
+	for _, cookie := range cookies {
+		name, _, _ := strings.Cut(cookie, "=")
+		maskedCookies = append(maskedCookies, name+"="+logging.Redacted)
+	}
+
+	return maskedHeaders, maskedCookies
+}
+
+// YAML encodes the configuration in the format of config.yaml. Settings are
+// written with the names used in the documentation and unset settings are left out.
+func (c Config) YAML() ([]byte, error) {
+	node, err := yamlNode(reflect.ValueOf(c))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// yamlNode converts a value to a YAML node. Struct fields keep their order and
+// Go name, zero values are omitted.
+func yamlNode(v reflect.Value) (*yaml.Node, error) {
+	switch v.Kind() {
+	case reflect.Struct:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() || v.Field(i).IsZero() {
+				continue
+			}
+			value, err := yamlNode(v.Field(i))
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: field.Name}, value)
+		}
+		return node, nil
+
+	case reflect.Slice:
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		if v.Type().Elem().Kind() != reflect.Struct {
+			node.Style = yaml.FlowStyle
+		}
+		for i := 0; i < v.Len(); i++ {
+			value, err := yamlNode(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, value)
+		}
+		return node, nil
+
+	default:
+		node := &yaml.Node{}
+		if err := node.Encode(v.Interface()); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+}
@@ -78,7 +78,7 @@ func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
 	fmt.Printf("%s [%s] %s %s\n",
 		timeStr,
 		level,
-		r.Message,
+		RedactString(r.Message),
 		attrStr,
 	)
 
@@ -107,7 +107,7 @@ func NewHandler(opts *slog.HandlerOptions, nocolor bool) *Handler {
 		h: slog.NewJSONHandler(b, &slog.HandlerOptions{
 			Level:       opts.Level,
 			AddSource:   opts.AddSource,
-			ReplaceAttr: suppressDefaults(opts.ReplaceAttr),
+			ReplaceAttr: suppressDefaults(redact(opts.ReplaceAttr)),
 		}),
 		m:       &sync.Mutex{},
 		output:  output,
@@ -115,6 +115,19 @@ func NewHandler(opts *slog.HandlerOptions, nocolor bool) *Handler {
 	}
 }
 
+// redact masks secrets in all attributes before passing them on to next
+func redact(
+	next func([]string, slog.Attr) slog.Attr,
+) func([]string, slog.Attr) slog.Attr {
+	return func(groups []string, a slog.Attr) slog.Attr {
+		a = Redact(groups, a)
+		if next == nil {
+			return a
+		}
+		return next(groups, a)
+	}
+}
+
 func suppressDefaults(
 	next func([]string, slog.Attr) slog.Attr,
 ) func([]string, slog.Attr) slog.Attr {
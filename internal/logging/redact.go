@@ -0,0 +1,142 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Redacted replaces secrets in log output and printed configurations
+const Redacted = "[REDACTED]"
+
+// minSecretLength is the length below which registered secrets aren't masked,
+// short values like "1" or "admin" would mask unrelated parts of the messages
+const minSecretLength = 6
+
+var (
+	secrets      []string
+	secretsMutex sync.RWMutex
+
+	// sensitiveKeys are attribute keys and HTTP headers whose values are always masked
+	sensitiveKeys = []string{"authorization", "cookie", "set-cookie", "token", "password", "secret", "csrf", "api-key", "apikey"}
+
+	// authSchemes matches credentials in Authorization header values, e.g. in error messages
+	authSchemes = regexp.MustCompile(`(?i)\b(token|bearer|basic)\s+[A-Za-z0-9+/=._~-]{8,}`)
+)
+
+// RegisterSecret adds a value, e.g. the configured token, that is masked wherever
+// it appears as a whole word in log messages and attributes. Secrets shorter than
+// minSecretLength are ignored.
+func RegisterSecret(secret string) {
+	if len(secret) < minSecretLength {
+		return
+	}
+
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+	for _, existing := range secrets {
+		if existing == secret {
+			return
+		}
+	}
+	secrets = append(secrets, secret)
+}
+
+// Redact masks Authorization headers, tokens and passwords in a log attribute.
+// It has the signature of slog.HandlerOptions.ReplaceAttr.
+func Redact(groups []string, a slog.Attr) slog.Attr {
+	if IsSensitive(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, RedactString(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case http.Header:
+			return slog.Any(a.Key, redactHeader(v))
+		case error:
+			return slog.String(a.Key, RedactString(v.Error()))
+		case string:
+			return slog.String(a.Key, RedactString(v))
+		}
+	}
+
+	return a
+}
+
+// RedactString masks registered secrets and credentials of Authorization header values
+func RedactString(s string) string {
+	s = authSchemes.ReplaceAllString(s, "$1 "+Redacted)
+
+	secretsMutex.RLock()
+	defer secretsMutex.RUnlock()
+	for _, secret := range secrets {
+		s = replaceWord(s, secret)
+	}
+	return s
+}
+
+// replaceWord masks the occurrences of the secret that aren't part of a longer
+// word, i.e. aren't preceded or followed by a letter or digit
+func replaceWord(s, secret string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, secret)
+		if i < 0 {
+			break
+		}
+
+		end := i + len(secret)
+		before, _ := utf8.DecodeLastRuneInString(s[:i])
+		after, _ := utf8.DecodeRuneInString(s[end:])
+		b.WriteString(s[:i])
+		if isWordRune(before) || isWordRune(after) {
+			b.WriteString(secret)
+		} else {
+			b.WriteString(Redacted)
+		}
+		s = s[end:]
+	}
+	if b.Len() == 0 {
+		return s
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// isWordRune reports whether r is a letter or a digit
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// redactHeader returns a copy of the header with sensitive values masked
+func redactHeader(header http.Header) http.Header {
+	masked := make(http.Header, len(header))
+	for key, values := range header {
+		if IsSensitive(key) {
+			masked[key] = []string{Redacted}
+			continue
+		}
+		for _, value := range values {
+			masked[key] = append(masked[key], RedactString(value))
+		}
+	}
+	return masked
+}
+
+// IsSensitive reports whether an attribute key or header name holds a secret
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
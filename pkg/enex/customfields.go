@@ -0,0 +1,125 @@
+package enex
+
+import (
+	"enex2paperless/pkg/paperless"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// customFieldValues maps the configured Evernote attributes of a note and one of
+// its resources to Paperless custom fields. Attributes without a value are skipped.
+func (e *EnexFile) customFieldValues(note Note, resource Resource) []paperless.CustomField {
+	var fields []paperless.CustomField
+
+	for _, field := range e.config.CustomFields {
+		value := e.customFieldSourceValue(strings.ToLower(field.Source), note, resource)
+		if value == "" {
+			continue
+		}
+
+		fields = append(fields, paperless.CustomField{
+			Name:     field.Name,
+			DataType: field.Type(),
+			Value:    value,
+		})
+	}
+
+	return fields
+}
+
+// customFieldSourceValue returns the string value of an Evernote attribute
+func (e *EnexFile) customFieldSourceValue(source string, note Note, resource Resource) string {
+	attrs := note.NoteAttributes
+	resAttrs := resource.ResourceAttributes
+
+	switch source {
+	case "sourceurl":
+		if attrs.SourceURL != "" {
+			return attrs.SourceURL
+		}
+		// resources often carry internal en-cache:// URLs, only use web URLs
+		if strings.HasPrefix(resAttrs.SourceURL, "http://") || strings.HasPrefix(resAttrs.SourceURL, "https://") {
+			return resAttrs.SourceURL
+		}
+	case "source":
+		return attrs.Source
+	case "sourceapplication":
+		return attrs.SourceApplication
+	case "author":
+		return attrs.Author
+	case "location":
+		return attrs.Location
+	case "placename":
+		return attrs.PlaceName
+	case "gps":
+		lat, long := coordinates(note, resource)
+		if lat != 0 || long != 0 {
+			return fmt.Sprintf("%s,%s", formatFloat(lat), formatFloat(long))
+		}
+	case "latitude":
+		lat, _ := coordinates(note, resource)
+		if lat != 0 {
+			return formatFloat(lat)
+		}
+	case "longitude":
+		_, long := coordinates(note, resource)
+		if long != 0 {
+			return formatFloat(long)
+		}
+	case "altitude":
+		if attrs.Altitude != 0 {
+			return formatFloat(attrs.Altitude)
+		}
+		if resAttrs.Altitude != 0 {
+			return formatFloat(resAttrs.Altitude)
+		}
+	case "cameramake":
+		return resAttrs.CameraMake
+	case "cameramodel":
+		return resAttrs.CameraModel
+	case "created":
+		return formatFieldDate(note.Created)
+	case "updated":
+		return formatFieldDate(note.Updated)
+	case "subjectdate":
+		return formatFieldDate(attrs.SubjectDate)
+	case "filename":
+		return resAttrs.FileName
+	case "enexfile":
+		if e.FilePath != "" && e.FilePath != StdinPath {
+			return filepath.Base(e.FilePath)
+		}
+	}
+
+	return ""
+}
+
+// coordinates returns the note's GPS coordinates, falling back to the resource's
+func coordinates(note Note, resource Resource) (float64, float64) {
+	if note.NoteAttributes.Latitude != 0 || note.NoteAttributes.Longitude != 0 {
+		return note.NoteAttributes.Latitude, note.NoteAttributes.Longitude
+	}
+	return resource.ResourceAttributes.Latitude, resource.ResourceAttributes.Longitude
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// formatFieldDate converts an Evernote timestamp to the date format of Paperless custom fields
+func formatFieldDate(dateStr string) string {
+	if dateStr == "" {
+		return ""
+	}
+
+	parsedTime, err := time.Parse("20060102T150405Z", dateStr)
+	if err != nil {
+		slog.Debug("couldn't parse date for custom field", "date", dateStr, "error", err)
+		return ""
+	}
+	return parsedTime.Format("2006-01-02")
+}
@@ -0,0 +1,73 @@
+package enex
+
+import (
+	"enex2paperless/internal/config"
+	"enex2paperless/pkg/paperless"
+	"testing"
+)
+
+// TestCustomFieldValues tests the mapping of Evernote attributes to custom fields
+func TestCustomFieldValues(t *testing.T) {
+	e := &EnexFile{
+		FilePath: "/exports/Receipts.enex",
+		config: config.Config{
+			CustomFields: []config.CustomField{
+				{Name: "Source", Source: "sourceurl"},
+				{Name: "Updated", Source: "updated"},
+				{Name: "Camera", Source: "CameraModel"},
+				{Name: "GPS", Source: "gps"},
+				{Name: "Author", Source: "author"},
+				{Name: "Notebook", Source: "enexfile"},
+				{Name: "Amount", Source: "location", DataType: "monetary"},
+			},
+		},
+	}
+
+	note := Note{
+		Updated: "20220315T101500Z",
+		NoteAttributes: NoteAttr{
+			Latitude:  47.5,
+			Longitude: 8.25,
+		},
+	}
+	resource := Resource{
+		ResourceAttributes: ResourceAttributes{
+			SourceURL:   "https://example.com/receipt",
+			CameraModel: "Pixel 7",
+		},
+	}
+
+	expected := []paperless.CustomField{
+		{Name: "Source", DataType: "url", Value: "https://example.com/receipt"},
+		{Name: "Updated", DataType: "date", Value: "2022-03-15"},
+		{Name: "Camera", DataType: "string", Value: "Pixel 7"},
+		{Name: "GPS", DataType: "string", Value: "47.5,8.25"},
+		{Name: "Notebook", DataType: "string", Value: "Receipts.enex"},
+	}
+
+	fields := e.customFieldValues(note, resource)
+	if len(fields) != len(expected) {
+		t.Fatalf("got %d custom fields, expected %d: %+v", len(fields), len(expected), fields)
+	}
+
+	for i, field := range fields {
+		if field != expected[i] {
+			t.Errorf("field[%d] = %+v, expected %+v", i, field, expected[i])
+		}
+	}
+}
+
+// TestCustomFieldSourceURLIgnoresInternalURLs verifies en-cache URLs aren't used as source URL
+func TestCustomFieldSourceURLIgnoresInternalURLs(t *testing.T) {
+	e := &EnexFile{}
+	resource := Resource{
+		ResourceAttributes: ResourceAttributes{
+			SourceURL: "en-cache://tokenKey%3D%22AuthToken",
+		},
+	}
+
+	value := e.customFieldSourceValue("sourceurl", Note{}, resource)
+	if value != "" {
+		t.Errorf("expected empty source URL, got %q", value)
+	}
+}
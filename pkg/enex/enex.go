@@ -2,7 +2,9 @@ package enex
 
 import (
 	"enex2paperless/internal/config"
+	"enex2paperless/pkg/paperless"
 	"net/http"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -19,10 +21,42 @@ type EnexFile struct {
 	FailedNoteChannel chan Note
 	FailedNoteSignal  chan bool
 	FilePath          string
+
+	// NotebookTag adds the notebook of each note as tag, the name of the ENEX
+	// file or of the ENEX file in a zip archive it was read from
+	NotebookTag bool
+
+	// Select is called for every note read from the file with its index in the
+	// file. Notes it returns false for are skipped, it may change the note.
+	Select func(index int, note *Note) bool
+
+	// notesRead counts the notes read from the file
+	notesRead int
+
+	// filter skips the notes that don't match the configured filter
+	filter        *noteFilter
+	notesFiltered int
+
+	// manifest collects the documents for the Paperless document_importer
+	manifest *paperless.Manifest
+
+	// mirrors receive a copy of every upload
+	mirrors []*mirror
+
+	// postConsume updates the uploaded documents after their consumption
+	postConsume *paperless.PostConsumeQueue
+
+	// templates are parsed once, see parsedTemplates
+	templates     *documentTemplates
+	templatesOnce sync.Once
+
+	// rules are compiled once, see compiledRules
+	rules     []rule
+	rulesOnce sync.Once
 }
 
 func NewEnexFile(filePath string, cfg config.Config) *EnexFile {
-	return &EnexFile{
+	e := &EnexFile{
 		Fs: afero.NewOsFs(),
 		client: &http.Client{
 			Timeout: time.Second * 10,
@@ -32,7 +66,15 @@ func NewEnexFile(filePath string, cfg config.Config) *EnexFile {
 		FailedNoteChannel: make(chan Note),
 		FailedNoteSignal:  make(chan bool),
 		FilePath:          filePath,
+		mirrors:           newMirrors(cfg),
+	}
+
+	if cfg.DocumentImporter {
+		e.manifest = paperless.NewManifest()
 	}
+	e.parsedTemplates()
+	e.compiledRules()
+	return e
 }
 
 type EnExport struct {
@@ -50,11 +92,27 @@ type Note struct {
 	Tags           []string   `xml:"tag"`
 	NoteAttributes NoteAttr   `xml:"note-attributes"`
 	Resources      []Resource `xml:"resource"`
+
+	// notebook is the name of the ENEX file the note was read from, for zip
+	// archives the name of the file in the archive
+	notebook string
+
+	// index is the position of the note in the file
+	index int
 }
 
 type NoteAttr struct {
-	Location string `xml:"location"`
-	// Add other attributes here
+	Location          string  `xml:"location"`
+	SubjectDate       string  `xml:"subject-date,omitempty"`
+	Latitude          float64 `xml:"latitude,omitempty"`
+	Longitude         float64 `xml:"longitude,omitempty"`
+	Altitude          float64 `xml:"altitude,omitempty"`
+	Author            string  `xml:"author,omitempty"`
+	Source            string  `xml:"source,omitempty"`
+	SourceURL         string  `xml:"source-url,omitempty"`
+	SourceApplication string  `xml:"source-application,omitempty"`
+	PlaceName         string  `xml:"place-name,omitempty"`
+	ContentClass      string  `xml:"content-class,omitempty"`
 }
 
 type Resource struct {
@@ -77,3 +135,20 @@ type ResourceAttributes struct {
 	Attachment      bool    `xml:"attachment,omitempty"`
 	ApplicationData string  `xml:"application-data,omitempty"`
 }
+
+// Size returns the size of the resource data, computed from the length of the
+// base64 encoded data without decoding it
+func (r Resource) Size() int {
+	var n, padding int
+	for _, c := range r.Data {
+		switch c {
+		case ' ', '\n', '\r', '\t':
+		case '=':
+			padding++
+			n++
+		default:
+			n++
+		}
+	}
+	return n*3/4 - padding
+}
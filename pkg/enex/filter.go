@@ -0,0 +1,103 @@
+package enex
+
+import (
+	"enex2paperless/internal/config"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// noteFilter is the compiled config.Filter. Unset conditions match all notes.
+type noteFilter struct {
+	includeTags   []string
+	excludeTags   []string
+	title         *regexp.Regexp
+	createdAfter  time.Time
+	createdBefore time.Time
+	minSize       int64
+	maxSize       int64
+	hasSourceURL  bool
+}
+
+// newNoteFilter compiles the filter of the configuration
+func newNoteFilter(f config.Filter) (*noteFilter, error) {
+	if err := f.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+
+	// the values have been checked by Validate
+	filter := &noteFilter{
+		includeTags:  f.IncludeTags,
+		excludeTags:  f.ExcludeTags,
+		hasSourceURL: f.HasSourceURL,
+	}
+	if f.TitleRegex != "" {
+		filter.title = regexp.MustCompile(f.TitleRegex)
+	}
+	if f.CreatedAfter != "" {
+		filter.createdAfter, _ = time.Parse(config.FilterDateFormat, f.CreatedAfter)
+	}
+	if f.CreatedBefore != "" {
+		filter.createdBefore, _ = time.Parse(config.FilterDateFormat, f.CreatedBefore)
+	}
+	if f.MinSize != "" {
+		filter.minSize, _ = config.ParseSize(f.MinSize)
+	}
+	if f.MaxSize != "" {
+		filter.maxSize, _ = config.ParseSize(f.MaxSize)
+	}
+	return filter, nil
+}
+
+// match reports whether the note passes the filter. Attachments outside of the
+// size range are removed from the note, notes that lose all of their attachments
+// don't match. Only the encoded data is looked at, nothing is decoded.
+func (f *noteFilter) match(note *Note) bool {
+	hasTag := func(tags []string) bool {
+		return slices.ContainsFunc(note.Tags, func(t string) bool {
+			return slices.ContainsFunc(tags, func(tag string) bool {
+				return strings.EqualFold(t, tag)
+			})
+		})
+	}
+
+	if len(f.includeTags) > 0 && !hasTag(f.includeTags) {
+		return false
+	}
+	if hasTag(f.excludeTags) {
+		return false
+	}
+	if f.hasSourceURL && note.NoteAttributes.SourceURL == "" {
+		return false
+	}
+	if f.title != nil && !f.title.MatchString(note.Title) {
+		return false
+	}
+
+	if !f.createdAfter.IsZero() || !f.createdBefore.IsZero() {
+		created, err := time.Parse("20060102T150405Z", note.Created)
+		if err != nil {
+			return false
+		}
+		if created.Before(f.createdAfter) {
+			return false
+		}
+		if !f.createdBefore.IsZero() && !created.Before(f.createdBefore) {
+			return false
+		}
+	}
+
+	if (f.minSize > 0 || f.maxSize > 0) && len(note.Resources) > 0 {
+		note.Resources = slices.DeleteFunc(note.Resources, func(resource Resource) bool {
+			size := int64(resource.Size())
+			return size < f.minSize || f.maxSize > 0 && size > f.maxSize
+		})
+		if len(note.Resources) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,205 @@
+package enex
+
+import (
+	"enex2paperless/internal/config"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// TestNoteFilter tests the conditions of the note filter
+func TestNoteFilter(t *testing.T) {
+	small := Resource{Data: strings.Repeat("AAAA", 100)}  // 300 bytes
+	large := Resource{Data: strings.Repeat("AAAA", 4000)} // 12000 bytes
+
+	testCases := []struct {
+		name      string
+		filter    config.Filter
+		note      Note
+		expected  bool
+		resources int
+	}{
+		{
+			name:     "empty filter matches",
+			note:     Note{Title: "Receipt"},
+			expected: true,
+		},
+		{
+			name:     "include tag",
+			filter:   config.Filter{IncludeTags: []string{"tax", "finance"}},
+			note:     Note{Tags: []string{"Tax"}},
+			expected: true,
+		},
+		{
+			name:     "include tag missing",
+			filter:   config.Filter{IncludeTags: []string{"tax"}},
+			note:     Note{Tags: []string{"travel"}},
+			expected: false,
+		},
+		{
+			name:     "exclude tag",
+			filter:   config.Filter{IncludeTags: []string{"tax"}, ExcludeTags: []string{"draft"}},
+			note:     Note{Tags: []string{"tax", "DRAFT"}},
+			expected: false,
+		},
+		{
+			name:     "title regex",
+			filter:   config.Filter{TitleRegex: "(?i)^invoice"},
+			note:     Note{Title: "Invoice 2020"},
+			expected: true,
+		},
+		{
+			name:     "title regex mismatch",
+			filter:   config.Filter{TitleRegex: "(?i)^invoice"},
+			note:     Note{Title: "Receipt"},
+			expected: false,
+		},
+		{
+			name:     "created after is inclusive",
+			filter:   config.Filter{CreatedAfter: "2018-01-01", CreatedBefore: "2023-01-01"},
+			note:     Note{Created: "20180101T000000Z"},
+			expected: true,
+		},
+		{
+			name:     "created before is exclusive",
+			filter:   config.Filter{CreatedAfter: "2018-01-01", CreatedBefore: "2023-01-01"},
+			note:     Note{Created: "20230101T000000Z"},
+			expected: false,
+		},
+		{
+			name:     "created too early",
+			filter:   config.Filter{CreatedAfter: "2018-01-01"},
+			note:     Note{Created: "20171231T235959Z"},
+			expected: false,
+		},
+		{
+			name:     "invalid created date with date filter",
+			filter:   config.Filter{CreatedBefore: "2023-01-01"},
+			note:     Note{Created: "yesterday"},
+			expected: false,
+		},
+		{
+			name:     "source url",
+			filter:   config.Filter{HasSourceURL: true},
+			note:     Note{NoteAttributes: NoteAttr{SourceURL: "https://example.com"}},
+			expected: true,
+		},
+		{
+			name:     "source url missing",
+			filter:   config.Filter{HasSourceURL: true},
+			note:     Note{},
+			expected: false,
+		},
+		{
+			name:      "min size drops small attachments",
+			filter:    config.Filter{MinSize: "1KB"},
+			note:      Note{Resources: []Resource{small, large}},
+			expected:  true,
+			resources: 1,
+		},
+		{
+			name:      "max size drops large attachments",
+			filter:    config.Filter{MaxSize: "1KB"},
+			note:      Note{Resources: []Resource{small, large}},
+			expected:  true,
+			resources: 1,
+		},
+		{
+			name:     "no attachment in size range",
+			filter:   config.Filter{MinSize: "20KB"},
+			note:     Note{Resources: []Resource{small, large}},
+			expected: false,
+		},
+		{
+			name:     "size filter keeps notes without attachments",
+			filter:   config.Filter{MinSize: "20KB"},
+			note:     Note{Title: "Text only"},
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			filter, err := newNoteFilter(tc.filter)
+			if err != nil {
+				t.Fatalf("newNoteFilter() error: %v", err)
+			}
+
+			note := tc.note
+			if got := filter.match(&note); got != tc.expected {
+				t.Errorf("match() = %v, expected %v", got, tc.expected)
+			}
+			if tc.expected && len(note.Resources) != tc.resources {
+				t.Errorf("got %d attachments, expected %d", len(note.Resources), tc.resources)
+			}
+		})
+	}
+}
+
+// TestReadFromFileFilter tests that filtered notes are skipped while reading and
+// keep their index for Select
+func TestReadFromFileFilter(t *testing.T) {
+	export := `<?xml version="1.0" encoding="UTF-8"?>
+<en-export>
+<note><title>Tax 2017</title><created>20170405T100000Z</created><tag>tax</tag></note>
+<note><title>Tax 2020</title><created>20200405T100000Z</created><tag>tax</tag></note>
+<note><title>Holiday 2020</title><created>20200801T100000Z</created><tag>travel</tag></note>
+<note><title>Tax 2022</title><created>20221231T235959Z</created><tag>Tax</tag></note>
+</en-export>`
+
+	mockFs := afero.NewMemMapFs()
+	afero.WriteFile(mockFs, "/export/Archive.enex", []byte(export), 0644)
+
+	cfg := config.Config{Filter: config.Filter{
+		IncludeTags:   []string{"tax"},
+		CreatedAfter:  "2018-01-01",
+		CreatedBefore: "2023-01-01",
+	}}
+	enexFile := NewEnexFile("/export/Archive.enex", cfg)
+	enexFile.Fs = mockFs
+
+	var indexes []int
+	enexFile.Select = func(index int, note *Note) bool {
+		indexes = append(indexes, index)
+		return true
+	}
+
+	titles := make(chan []string)
+	go func() {
+		var result []string
+		for note := range enexFile.NoteChannel {
+			result = append(result, note.Title)
+		}
+		titles <- result
+	}()
+
+	if err := enexFile.ReadFromFile(); err != nil {
+		t.Fatalf("ReadFromFile() error: %v", err)
+	}
+	if result := <-titles; strings.Join(result, ",") != "Tax 2020,Tax 2022" {
+		t.Errorf("got notes %v, expected [Tax 2020 Tax 2022]", result)
+	}
+	if len(indexes) != 2 || indexes[0] != 1 || indexes[1] != 3 {
+		t.Errorf("got indexes %v, expected [1 3]", indexes)
+	}
+	if enexFile.notesFiltered != 2 {
+		t.Errorf("got %d filtered notes, expected 2", enexFile.notesFiltered)
+	}
+}
+
+// TestReadFromFileInvalidFilter tests that an invalid filter fails the read
+func TestReadFromFileInvalidFilter(t *testing.T) {
+	mockFs := afero.NewMemMapFs()
+	afero.WriteFile(mockFs, "/export/Work.enex", []byte(testExport), 0644)
+
+	enexFile := NewEnexFile("/export/Work.enex", config.Config{Filter: config.Filter{TitleRegex: "(unclosed"}})
+	enexFile.Fs = mockFs
+
+	if err := enexFile.ReadFromFile(); err == nil {
+		t.Error("expected an error for the invalid title regex")
+	}
+	if _, open := <-enexFile.NoteChannel; open {
+		t.Error("expected the note channel to be closed")
+	}
+}
@@ -1,13 +1,23 @@
 package enex
 
 import (
+	"encoding/base64"
+	"enex2paperless/pkg/enml"
+	"errors"
 	"fmt"
+	"log/slog"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"strings"
 	"time"
 )
 
+var (
+	validBase64      = regexp.MustCompile(`^[A-Za-z0-9+/]*={0,2}$`)
+	errInvalidBase64 = errors.New("data is not valid base64")
+)
+
 func (e *EnexFile) checkFileType(mimeType string) (bool, error) {
 	// if filetypes contains "any" then allow all file types
 	if slices.Contains(e.config.FileTypes, "any") {
@@ -100,3 +110,34 @@ func sanitizeFilename(filename string) string {
 
 	return sanitized
 }
+
+// enmlToText renders ENML note content as plain text
+func enmlToText(content string) string {
+	doc, err := enml.Parse(content)
+	if err != nil {
+		// the parser is lenient, render whatever could be parsed
+		slog.Debug("couldn't parse note content", "error", err)
+	}
+	return enml.Text(doc, enml.Options{})
+}
+
+// decodeResourceData decodes the base64 data of a resource
+func decodeResourceData(data string) ([]byte, error) {
+	// Remove newlines and spaces from Resource.Data
+	data = strings.ReplaceAll(data, "\n", "")
+	data = strings.ReplaceAll(data, " ", "")
+
+	// add padding if necessary
+	padding := len(data) % 4
+	if padding > 0 {
+		slog.Debug("adding padding", "padding", padding)
+		data += strings.Repeat("=", 4-padding)
+	}
+
+	// Validate that Resource.Data is valid base64
+	if !validBase64.MatchString(data) {
+		return nil, errInvalidBase64
+	}
+
+	return base64.StdEncoding.DecodeString(data)
+}
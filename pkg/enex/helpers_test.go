@@ -394,3 +394,39 @@ func TestCheckFileTypeHelper(t *testing.T) {
 		})
 	}
 }
+
+// TestEnmlToTextHelper tests the enmlToText function
+func TestEnmlToTextHelper(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name: "paragraphs and line breaks",
+			content: `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE en-note SYSTEM "http://xml.evernote.com/pub/enml2.dtd">
+<en-note><div>paid on 3.4.</div><div>ref 12345<br/>thanks</div></en-note>`,
+			expected: "paid on 3.4.\nref 12345\nthanks",
+		},
+		{
+			name:     "checkboxes and entities",
+			content:  `<en-note><div><en-todo checked="true"/>done &amp; dusted</div><div><en-todo/>open&nbsp;item</div></en-note>`,
+			expected: "[x] done & dusted\n[ ] open item",
+		},
+		{
+			name:     "empty content",
+			content:  "",
+			expected: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := enmlToText(tc.content)
+			if result != tc.expected {
+				t.Errorf("enmlToText() = %q, expected %q", result, tc.expected)
+			}
+		})
+	}
+}
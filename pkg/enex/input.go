@@ -0,0 +1,190 @@
+package enex
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/afero"
+)
+
+// StdinPath is the file path that reads the ENEX export from standard input
+const StdinPath = "-"
+
+// stdin is read for StdinPath, tests replace it
+var stdin io.Reader = os.Stdin
+
+// magic numbers of the supported compression and archive formats
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	zipMagic  = []byte("PK\x03\x04")
+)
+
+// ReadFromFile decodes the notes of the file and sends them to the NoteChannel.
+// Gzip and Zstandard compressed exports are decompressed, the ENEX files in a zip
+// archive are read one after another. The format is detected from the content,
+// so that compressed exports can be piped to stdin as well. Notes that don't
+// match the configured filter are skipped. The channel is closed when the file has been read or couldn't be opened.
+func (e *EnexFile) ReadFromFile() error {
+	defer close(e.NoteChannel)
+
+	filter, err := newNoteFilter(e.config.Filter)
+	if err != nil {
+		return err
+	}
+	e.filter = filter
+	defer func() {
+		if e.notesFiltered > 0 {
+			slog.Info("skipped notes that don't match the filter", "file", e.FilePath, "notesFiltered", e.notesFiltered)
+		}
+	}()
+
+	var file io.Reader = stdin
+	if e.FilePath != StdinPath {
+		slog.Debug(fmt.Sprintf("opening file: %v", e.FilePath))
+		f, err := e.Fs.Open(e.FilePath)
+		if err != nil {
+			return fmt.Errorf("error opening file: %w", err)
+		}
+		defer f.Close()
+		file = f
+	}
+
+	reader := bufio.NewReader(file)
+	if magic, _ := reader.Peek(len(zipMagic)); bytes.Equal(magic, zipMagic) {
+		// zip archives have their index at the end and need random access
+		f, ok := file.(afero.File)
+		if !ok {
+			return errors.New("zip archives can't be read from stdin, pass the path of the archive instead")
+		}
+		return e.readZip(f)
+	}
+
+	return e.decompressNotes(reader, NotebookName(e.FilePath))
+}
+
+// decompressNotes decodes the notes of a plain, gzip or Zstandard compressed ENEX
+// export of the notebook
+func (e *EnexFile) decompressNotes(r io.Reader, notebook string) error {
+	reader := bufio.NewReader(r)
+	magic, _ := reader.Peek(len(zstdMagic))
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		slog.Debug("decompressing gzip")
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return fmt.Errorf("error decompressing file: %w", err)
+		}
+		defer gz.Close()
+		return e.decodeNotes(gz, notebook)
+
+	case bytes.HasPrefix(magic, zstdMagic):
+		slog.Debug("decompressing zstd")
+		zr, err := zstd.NewReader(reader)
+		if err != nil {
+			return fmt.Errorf("error decompressing file: %w", err)
+		}
+		defer zr.Close()
+		return e.decodeNotes(zr, notebook)
+
+	default:
+		return e.decodeNotes(reader, notebook)
+	}
+}
+
+// readZip decodes the notes of all ENEX files in a zip archive. Each ENEX file is
+// a notebook of its own, named after the file.
+func (e *EnexFile) readZip(f afero.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("error reading file info: %w", err)
+	}
+
+	archive, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return fmt.Errorf("error opening zip archive: %w", err)
+	}
+
+	var found int
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() || isSystemFile(entry.Name) || !IsEnexFile(entry.Name) {
+			continue
+		}
+		found++
+
+		slog.Debug(fmt.Sprintf("reading %v from zip archive", entry.Name))
+		r, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("error opening %s in zip archive: %w", entry.Name, err)
+		}
+		err = e.decompressNotes(r, NotebookName(entry.Name))
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("error reading %s in zip archive: %w", entry.Name, err)
+		}
+	}
+
+	if found == 0 {
+		return fmt.Errorf("no ENEX files found in %s", e.FilePath)
+	}
+	return nil
+}
+
+// IsEnexFile reports whether a file found in a directory or zip archive is an
+// ENEX file, plain or compressed with gzip or Zstandard
+func IsEnexFile(path string) bool {
+	name := strings.ToLower(filepath.Base(path))
+	for _, ext := range []string{".enex", ".enex.gz", ".enex.zst"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// notebook returns the name of the notebook the note was exported to, the name of
+// the ENEX file it was read from
+func (e *EnexFile) notebook(note Note) string {
+	if note.notebook != "" {
+		return note.notebook
+	}
+	return NotebookName(e.FilePath)
+}
+
+// additionalTags returns the configured additional tags, and the notebook if
+// NotebookTag is set
+func (e *EnexFile) additionalTags(notebook string) []string {
+	if !e.NotebookTag || notebook == "" || slices.Contains(e.config.AdditionalTags, notebook) {
+		return e.config.AdditionalTags
+	}
+	return append(slices.Clone(e.config.AdditionalTags), notebook)
+}
+
+// NotebookName returns the name of the notebook exported to a file, the file name
+// without the extension and the compression extension. It is empty for stdin.
+func NotebookName(filePath string) string {
+	if filePath == StdinPath || filePath == "" {
+		return ""
+	}
+
+	base := filepath.Base(filePath)
+	switch strings.ToLower(filepath.Ext(base)) {
+	case ".zip":
+		return strings.TrimSuffix(base, filepath.Ext(base))
+	case ".gz", ".zst":
+		base = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
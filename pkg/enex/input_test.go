@@ -0,0 +1,278 @@
+package enex
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"enex2paperless/internal/config"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/afero"
+)
+
+const testExport = `<?xml version="1.0" encoding="UTF-8"?>
+<en-export><note><title>First</title></note><note><title>Second</title></note></en-export>`
+
+func gzipData(t *testing.T, data string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(data))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zstdData(t *testing.T, data string) []byte {
+	w, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	return w.EncodeAll([]byte(data), nil)
+}
+
+func zipData(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range []string{"Work.enex", "__MACOSX/._Work.enex", "readme.txt", "Travel.enex.gz"} {
+		data, exists := files[name]
+		if !exists {
+			continue
+		}
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(data))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadFromFileFormats(t *testing.T) {
+	tests := []struct {
+		name           string
+		filePath       string
+		data           []byte
+		stdin          bool
+		expectedTitles []string
+		expectError    bool
+	}{
+		{
+			name:           "plain",
+			filePath:       "/export/Work.enex",
+			data:           []byte(testExport),
+			expectedTitles: []string{"First", "Second"},
+		},
+		{
+			name:           "gzip",
+			filePath:       "/export/Work.enex.gz",
+			data:           gzipData(t, testExport),
+			expectedTitles: []string{"First", "Second"},
+		},
+		{
+			name:           "zstd",
+			filePath:       "/export/Work.enex.zst",
+			data:           zstdData(t, testExport),
+			expectedTitles: []string{"First", "Second"},
+		},
+		{
+			name:     "zip",
+			filePath: "/export/Evernote.zip",
+			data: zipData(t, map[string]string{
+				"Work.enex":            testExport,
+				"__MACOSX/._Work.enex": "garbage",
+				"readme.txt":           "<note><title>Readme</title></note>",
+				"Travel.enex.gz":       string(gzipData(t, "<en-export><note><title>Third</title></note></en-export>")),
+			}),
+			expectedTitles: []string{"First", "Second", "Third"},
+		},
+		{
+			name:        "truncated gzip",
+			filePath:    "/export/Work.enex.gz",
+			data:        gzipData(t, testExport)[:40],
+			expectError: true,
+		},
+		{
+			name:     "truncated zstd",
+			filePath: "/export/Work.enex.zst",
+			data: func() []byte {
+				data := zstdData(t, testExport)
+				return data[:len(data)-8]
+			}(),
+			expectError: true,
+		},
+		{
+			name:        "zip without ENEX files",
+			filePath:    "/export/Evernote.zip",
+			data:        zipData(t, map[string]string{"readme.txt": "text"}),
+			expectError: true,
+		},
+		{
+			name:           "stdin gzip",
+			filePath:       StdinPath,
+			data:           gzipData(t, testExport),
+			stdin:          true,
+			expectedTitles: []string{"First", "Second"},
+		},
+		{
+			name:        "stdin zip",
+			filePath:    StdinPath,
+			data:        zipData(t, map[string]string{"Work.enex": testExport}),
+			stdin:       true,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockFs := afero.NewMemMapFs()
+			if tt.stdin {
+				original := stdin
+				stdin = bytes.NewReader(tt.data)
+				defer func() { stdin = original }()
+			} else {
+				afero.WriteFile(mockFs, tt.filePath, tt.data, 0644)
+			}
+
+			enexFile := NewEnexFile(tt.filePath, config.Config{})
+			enexFile.Fs = mockFs
+
+			titles := make(chan []string)
+			go func() {
+				var result []string
+				for note := range enexFile.NoteChannel {
+					result = append(result, note.Title)
+				}
+				titles <- result
+			}()
+
+			err := enexFile.ReadFromFile()
+			result := <-titles
+
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadFromFile() error: %v", err)
+			}
+			if strings.Join(result, ",") != strings.Join(tt.expectedTitles, ",") {
+				t.Errorf("got notes %v, expected %v", result, tt.expectedTitles)
+			}
+		})
+	}
+}
+
+// TestReadFromFileZipNotebooks verifies that the notes of each ENEX file in a zip
+// archive get the name of that file as notebook tag and template value
+func TestReadFromFileZipNotebooks(t *testing.T) {
+	mockFs := afero.NewMemMapFs()
+	afero.WriteFile(mockFs, "/export/Evernote.zip", zipData(t, map[string]string{
+		"Work.enex":      "<en-export><note><title>Report</title></note></en-export>",
+		"Travel.enex.gz": string(gzipData(t, "<en-export><note><title>Tickets</title></note></en-export>")),
+	}), 0644)
+
+	enexFile := NewEnexFile("/export/Evernote.zip", config.Config{AdditionalTags: []string{"Evernote"}})
+	enexFile.Fs = mockFs
+	enexFile.NotebookTag = true
+
+	notes := make(chan []Note)
+	go func() {
+		var result []Note
+		for note := range enexFile.NoteChannel {
+			result = append(result, note)
+		}
+		notes <- result
+	}()
+
+	if err := enexFile.ReadFromFile(); err != nil {
+		t.Fatalf("ReadFromFile() error: %v", err)
+	}
+
+	expected := map[string]string{"Report": "Work", "Tickets": "Travel"}
+	result := <-notes
+	if len(result) != len(expected) {
+		t.Fatalf("got %d notes, expected %d", len(result), len(expected))
+	}
+	for _, note := range result {
+		notebook := expected[note.Title]
+		if data := enexFile.templateData(note, "file.pdf", 1, 1); data.EnexFile != notebook {
+			t.Errorf("note %q: EnexFile = %q, expected %q", note.Title, data.EnexFile, notebook)
+		}
+		if tags := enexFile.additionalTags(enexFile.notebook(note)); strings.Join(tags, ",") != "Evernote,"+notebook {
+			t.Errorf("note %q: got tags %v, expected [Evernote %s]", note.Title, tags, notebook)
+		}
+	}
+}
+
+func TestNotebookName(t *testing.T) {
+	tests := map[string]string{
+		"/export/Work.enex":         "Work",
+		"/export/Travel.ENEX.gz":    "Travel",
+		"/export/My Notes.enex.zst": "My Notes",
+		"/export/Evernote 2024.zip": "Evernote 2024",
+		"/export/v1.2 Notebook.zip": "v1.2 Notebook",
+		"/export/Export.xml":        "Export",
+		StdinPath:                   "",
+	}
+
+	for filePath, expected := range tests {
+		if got := NotebookName(filePath); got != expected {
+			t.Errorf("NotebookName(%q) = %q, expected %q", filePath, got, expected)
+		}
+	}
+}
+
+func TestResourceSize(t *testing.T) {
+	tests := map[string]int{
+		"":                   0,
+		"dGVzdCBkYXRh":       9,
+		"dGVzdA==":           4,
+		"dGVzdA":             4,
+		"dGVz\n  dCBk\nYXRh": 9,
+	}
+
+	for data, expected := range tests {
+		if got := (Resource{Data: data}).Size(); got != expected {
+			t.Errorf("Size() of %q = %d, expected %d", data, got, expected)
+		}
+	}
+}
+
+// TestReadFromFileSelect verifies that notes can be skipped and changed while reading
+func TestReadFromFileSelect(t *testing.T) {
+	mockFs := afero.NewMemMapFs()
+	afero.WriteFile(mockFs, "/export/Work.enex", []byte(testExport), 0644)
+
+	enexFile := NewEnexFile("/export/Work.enex", config.Config{})
+	enexFile.Fs = mockFs
+	enexFile.Select = func(index int, note *Note) bool {
+		note.Title = fmt.Sprintf("%d %s", index, note.Title)
+		return index == 1
+	}
+
+	titles := make(chan []string)
+	go func() {
+		var result []string
+		for note := range enexFile.NoteChannel {
+			result = append(result, note.Title)
+		}
+		titles <- result
+	}()
+
+	if err := enexFile.ReadFromFile(); err != nil {
+		t.Fatalf("ReadFromFile() error: %v", err)
+	}
+	if result := <-titles; strings.Join(result, ",") != "1 Second" {
+		t.Errorf("got notes %v, expected [1 Second]", result)
+	}
+}
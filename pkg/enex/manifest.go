@@ -0,0 +1,49 @@
+package enex
+
+import (
+	"enex2paperless/pkg/templates"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+// addToManifest adds a saved document to the manifest for the Paperless
+// document_importer
+func (e *EnexFile) addToManifest(note Note, data templates.Data, title string, content []byte, mimeType, fileName, outputFolder string) error {
+	exportedFileName, err := filepath.Rel(outputFolder, fileName)
+	if err != nil {
+		return fmt.Errorf("error resolving exported file name: %w", err)
+	}
+
+	createdDate, err := convertDateFormat(note.Created)
+	if err != nil {
+		return err
+	}
+
+	tags := append([]string{}, note.Tags...)
+	tags = append(tags, e.additionalTags(e.notebook(note))...)
+
+	// the original file name instead of the path in the output folder
+	resource := Resource{Mime: mimeType, ResourceAttributes: ResourceAttributes{FileName: data.FileName}}
+	paperlessFile := e.newPaperlessFile(note, resource, title, content, createdDate, tags)
+
+	return e.manifest.AddDocument(paperlessFile, filepath.ToSlash(exportedFileName), note.NoteAttributes.Author)
+}
+
+// writeManifest writes manifest.json for the Paperless document_importer to the output folder
+func (e *EnexFile) writeManifest(outputFolder string) error {
+	data, err := e.manifest.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("error encoding manifest: %w", err)
+	}
+
+	fileName := filepath.Join(outputFolder, "manifest.json")
+	if err := afero.WriteFile(e.Fs, fileName, data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	slog.Info("manifest for the Paperless document_importer saved", "file", fileName, "documents", e.manifest.Len())
+	return nil
+}
@@ -0,0 +1,125 @@
+package enex
+
+import (
+	"encoding/json"
+	"enex2paperless/internal/config"
+	"enex2paperless/pkg/paperless"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// TestProcessingDocumentImporter verifies saved files are listed in the manifest
+// and duplicates are left out
+func TestProcessingDocumentImporter(t *testing.T) {
+	mockFs := afero.NewMemMapFs()
+	enexFile := &EnexFile{
+		Fs: mockFs,
+		config: config.Config{
+			FileTypes:      []string{"pdf"},
+			AdditionalTags: []string{"Evernote"},
+			PathTemplate:   "{{.Year}}/",
+		},
+		NoteChannel:       make(chan Note, 10),
+		FailedNoteChannel: make(chan Note, 10),
+		manifest:          paperless.NewManifest(),
+	}
+
+	outputFolder := "/tmp/export"
+	resource := Resource{
+		// "test data"
+		Data:               "dGVzdCBkYXRh",
+		Mime:               "application/pdf",
+		ResourceAttributes: ResourceAttributes{FileName: "policy.pdf"},
+	}
+
+	done := make(chan bool)
+	go func() {
+		if err := enexFile.UploadFromNoteChannel(outputFolder); err != nil {
+			t.Errorf("UploadFromNoteChannel error: %v", err)
+		}
+		done <- true
+	}()
+
+	enexFile.NoteChannel <- Note{Title: "Insurance", Created: "20230403T123000Z", Tags: []string{"Finance"}, Resources: []Resource{resource}}
+	enexFile.NoteChannel <- Note{Title: "Copy", Created: "20230404T123000Z", Resources: []Resource{resource}}
+	close(enexFile.NoteChannel)
+	<-done
+
+	if err := enexFile.writeManifest(outputFolder); err != nil {
+		t.Fatalf("writeManifest() error: %v", err)
+	}
+
+	data, err := afero.ReadFile(mockFs, "/tmp/export/manifest.json")
+	if err != nil {
+		t.Fatalf("manifest not written: %v", err)
+	}
+
+	var records []struct {
+		Model            string         `json:"model"`
+		Fields           map[string]any `json:"fields"`
+		ExportedFileName string         `json:"__exported_file_name__"`
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		t.Fatalf("invalid manifest: %v", err)
+	}
+
+	var documents, tags int
+	for _, record := range records {
+		switch record.Model {
+		case "documents.document":
+			documents++
+			if record.ExportedFileName != "2023/policy.pdf" {
+				t.Errorf("exported file name = %q, expected 2023/policy.pdf", record.ExportedFileName)
+			}
+			if record.Fields["title"] != "Insurance" || record.Fields["original_filename"] != "policy.pdf" {
+				t.Errorf("unexpected document fields: %v", record.Fields)
+			}
+		case "documents.tag":
+			tags++
+		}
+	}
+	if documents != 1 || tags != 2 {
+		t.Errorf("got %d documents and %d tags, expected 1 and 2", documents, tags)
+	}
+
+	if exists, _ := afero.Exists(mockFs, "/tmp/export/2023/policy-1.pdf"); exists {
+		t.Error("duplicate file should have been removed")
+	}
+}
+
+// failingFs fails to open the files with the given name
+type failingFs struct {
+	afero.Fs
+	fileName string
+}
+
+func (f failingFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if filepath.Base(name) == f.fileName {
+		return nil, errors.New("disk full")
+	}
+	return f.Fs.OpenFile(name, flag, perm)
+}
+
+// TestProcessingManifestError verifies the result is returned together with the
+// error when the manifest can't be written
+func TestProcessingManifestError(t *testing.T) {
+	mockFs := afero.NewMemMapFs()
+	afero.WriteFile(mockFs, "/export/Work.enex", []byte(`<en-export><note><title>Contract</title><created>20230403T123000Z</created>
+		<resource><data>dGVzdCBkYXRh</data><mime>application/pdf</mime>
+		<resource-attributes><file-name>contract.pdf</file-name></resource-attributes></resource></note></en-export>`), 0644)
+
+	enexFile := NewEnexFile("/export/Work.enex", config.Config{FileTypes: []string{"pdf"}, DocumentImporter: true})
+	enexFile.Fs = failingFs{Fs: mockFs, fileName: "manifest.json"}
+
+	result, err := ProcessFiles([]*EnexFile{enexFile}, ProcessOptions{OutputFolder: "/tmp/export", RetryPromptFunc: RetryOnce()})
+	if err == nil {
+		t.Fatal("expected an error for the manifest")
+	}
+	if result == nil || result.FilesUploaded != 1 {
+		t.Errorf("expected the result with 1 saved file, got %+v", result)
+	}
+}
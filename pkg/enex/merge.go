@@ -0,0 +1,112 @@
+package enex
+
+import (
+	"enex2paperless/pkg/enml"
+	"enex2paperless/pkg/pdf"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// isMergedResource reports whether a resource is bundled into one PDF with other
+// resources of its note, either as combined image or as merged attachment
+func (e *EnexFile) isMergedResource(resource Resource) bool {
+	isImage := strings.HasPrefix(resource.Mime, "image/")
+
+	switch {
+	case e.config.MergeAttachments && (isImage || resource.Mime == "application/pdf"):
+	case e.config.CombineImages && isImage:
+	default:
+		return false
+	}
+
+	isWantedFileType, err := e.checkFileType(resource.Mime)
+	return err == nil && isWantedFileType
+}
+
+// mergedResources returns the decoded resources of a note that are bundled into
+// one PDF, in the order their en-media elements appear in the note content.
+// Resources that aren't referenced in the content follow in their original order.
+func (e *EnexFile) mergedResources(note Note) []decodedResource {
+	var candidates []Resource
+	for _, resource := range note.Resources {
+		if e.isMergedResource(resource) {
+			candidates = append(candidates, resource)
+		}
+	}
+
+	resources := decodeResources(candidates)
+	if len(resources) == 0 {
+		return nil
+	}
+
+	doc, err := enml.Parse(note.Content)
+	if err != nil {
+		slog.Debug("couldn't parse note content", "note", note.Title, "error", err)
+	}
+
+	var ordered []decodedResource
+	used := make([]bool, len(resources))
+	for _, media := range doc.Media() {
+		hash := strings.ToLower(media.Attr("hash"))
+		for i, resource := range resources {
+			if !used[i] && resource.hash == hash {
+				ordered = append(ordered, resource)
+				used[i] = true
+				break
+			}
+		}
+	}
+
+	for i, resource := range resources {
+		if !used[i] {
+			ordered = append(ordered, resource)
+		}
+	}
+
+	return ordered
+}
+
+// importMergedResources bundles resources of a note into one PDF and saves it to
+// disk or uploads it to Paperless as a single document. It returns the hashes of
+// the resources included in the document, the others have to be imported on their own.
+func (e *EnexFile) importMergedResources(note Note, resources []decodedResource, count int, outputFolder string, createdDate string, tags []string) (map[string]bool, error) {
+	media := make([]enml.Media, len(resources))
+	for i, resource := range resources {
+		media[i] = resource.media
+	}
+
+	pdfNote, err := newPDFNote(note)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	var skipped []int
+	if e.config.MergeAttachments {
+		slog.Info("merging attachments into one document", slog.String("note", note.Title), slog.Int("attachments", len(media)))
+		data, skipped, err = pdf.RenderDocument(pdfNote, media)
+	} else {
+		slog.Info("combining images into PDF", slog.String("note", note.Title), slog.Int("images", len(media)))
+		data, skipped, err = pdf.RenderImages(pdfNote, media, e.config.CombineImagesCover)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error combining attachments of note %q: %w", note.Title, err)
+	}
+
+	err = e.importPDF(note, data, count, outputFolder, createdDate, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	included := make(map[string]bool)
+	for i, resource := range resources {
+		if slices.Contains(skipped, i) {
+			slog.Warn("attachment couldn't be combined, importing it on its own", "file", resource.media.FileName)
+			continue
+		}
+		included[resource.hash] = true
+	}
+	return included, nil
+}
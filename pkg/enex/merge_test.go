@@ -0,0 +1,216 @@
+package enex
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"enex2paperless/internal/config"
+	"enex2paperless/pkg/pdf"
+	"fmt"
+	"image"
+	"image/png"
+	"slices"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// testImageResource returns a PNG resource and the hash en-media elements use to refer to it
+func testImageResource(t *testing.T, fileName string, width int) (Resource, string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, 10))); err != nil {
+		t.Fatalf("error encoding test image: %v", err)
+	}
+
+	hash := md5.Sum(buf.Bytes())
+	resource := Resource{
+		Data: base64.StdEncoding.EncodeToString(buf.Bytes()),
+		Mime: "image/png",
+		ResourceAttributes: ResourceAttributes{
+			FileName: fileName,
+		},
+	}
+	return resource, hex.EncodeToString(hash[:])
+}
+
+// TestMergedResources verifies which resources are combined and that they are ordered like their en-media elements
+func TestMergedResources(t *testing.T) {
+	page1, hash1 := testImageResource(t, "page1.png", 10)
+	page2, hash2 := testImageResource(t, "page2.png", 20)
+	page3, _ := testImageResource(t, "page3.png", 30)
+	gif := Resource{Data: base64.StdEncoding.EncodeToString([]byte("GIF89a")), Mime: "image/gif"}
+	document := Resource{
+		Data:               base64.StdEncoding.EncodeToString([]byte("%PDF-1.4")),
+		Mime:               "application/pdf",
+		ResourceAttributes: ResourceAttributes{FileName: "letter.pdf"},
+	}
+
+	note := Note{
+		Title:     "Scan",
+		Content:   fmt.Sprintf(`<en-note><en-media hash="%s" type="image/png"/><en-media hash="%s" type="image/png"/></en-note>`, hash2, hash1),
+		Resources: []Resource{page1, gif, page3, document, page2},
+	}
+
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		expected []string
+	}{
+		{
+			name:     "combine images",
+			cfg:      config.Config{FileTypes: []string{"pdf", "png"}, CombineImages: true},
+			expected: []string{"page2.png", "page1.png", "page3.png"},
+		},
+		{
+			name:     "merge attachments",
+			cfg:      config.Config{FileTypes: []string{"pdf", "png"}, MergeAttachments: true},
+			expected: []string{"page2.png", "page1.png", "page3.png", "letter.pdf"},
+		},
+		{
+			name:     "disabled",
+			cfg:      config.Config{FileTypes: []string{"pdf", "png"}},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enexFile := &EnexFile{config: tt.cfg}
+
+			var fileNames []string
+			for _, resource := range enexFile.mergedResources(note) {
+				fileNames = append(fileNames, resource.media.FileName)
+			}
+
+			// unreferenced resources follow, unwanted file types are left out
+			if !slices.Equal(fileNames, tt.expected) {
+				t.Errorf("mergedResources() = %v, expected %v", fileNames, tt.expected)
+			}
+		})
+	}
+}
+
+// TestProcessingCombinedImages verifies the images of a note are saved as one PDF
+func TestProcessingCombinedImages(t *testing.T) {
+	page1, _ := testImageResource(t, "page1.png", 10)
+	page2, _ := testImageResource(t, "page2.png", 20)
+
+	mockFs := afero.NewMemMapFs()
+	enexFile := &EnexFile{
+		Fs: mockFs,
+		config: config.Config{
+			FileTypes:     []string{"png"},
+			CombineImages: true,
+		},
+		NoteChannel:       make(chan Note, 10),
+		FailedNoteChannel: make(chan Note, 10),
+	}
+
+	done := make(chan bool)
+	go func() {
+		err := enexFile.UploadFromNoteChannel("/tmp/output")
+		if err != nil {
+			t.Errorf("UploadFromNoteChannel error: %v", err)
+		}
+		done <- true
+	}()
+
+	enexFile.NoteChannel <- Note{
+		Title:     "Insurance letter",
+		Created:   "20220101T120000Z",
+		Resources: []Resource{page1, page2},
+	}
+
+	close(enexFile.NoteChannel)
+	<-done
+
+	if enexFile.Uploads.Load() != 1 {
+		t.Errorf("Expected 1 upload, got %d", enexFile.Uploads.Load())
+	}
+
+	files, err := afero.ReadDir(mockFs, "/tmp/output")
+	if err != nil {
+		t.Fatalf("Error reading output folder: %v", err)
+	}
+	if len(files) != 1 || files[0].Name() != "Insurance letter.pdf" {
+		var names []string
+		for _, f := range files {
+			names = append(names, f.Name())
+		}
+		t.Errorf("Expected only the combined PDF, got %v", names)
+	}
+}
+
+// TestProcessingMergedAttachments verifies attachments are merged into one document
+// and attachments that can't be merged are imported on their own
+func TestProcessingMergedAttachments(t *testing.T) {
+	page, _ := testImageResource(t, "page.png", 10)
+
+	letter, err := pdf.RenderNote(pdf.Note{Title: "Letter"})
+	if err != nil {
+		t.Fatalf("error rendering test PDF: %v", err)
+	}
+
+	mockFs := afero.NewMemMapFs()
+	enexFile := &EnexFile{
+		Fs: mockFs,
+		config: config.Config{
+			FileTypes:        []string{"pdf", "png"},
+			MergeAttachments: true,
+		},
+		NoteChannel:       make(chan Note, 10),
+		FailedNoteChannel: make(chan Note, 10),
+	}
+
+	done := make(chan bool)
+	go func() {
+		err := enexFile.UploadFromNoteChannel("/tmp/output")
+		if err != nil {
+			t.Errorf("UploadFromNoteChannel error: %v", err)
+		}
+		done <- true
+	}()
+
+	enexFile.NoteChannel <- Note{
+		Title:   "Insurance",
+		Created: "20220101T120000Z",
+		Content: "<en-note><div>Policy documents</div></en-note>",
+		Tags:    []string{"insurance"},
+		Resources: []Resource{
+			{
+				Data:               base64.StdEncoding.EncodeToString(letter),
+				Mime:               "application/pdf",
+				ResourceAttributes: ResourceAttributes{FileName: "letter.pdf"},
+			},
+			page,
+			{
+				Data:               base64.StdEncoding.EncodeToString([]byte("damaged")),
+				Mime:               "application/pdf",
+				ResourceAttributes: ResourceAttributes{FileName: "damaged.pdf"},
+			},
+		},
+	}
+
+	close(enexFile.NoteChannel)
+	<-done
+
+	if enexFile.Uploads.Load() != 2 {
+		t.Errorf("Expected 2 uploads, got %d", enexFile.Uploads.Load())
+	}
+
+	files, err := afero.ReadDir(mockFs, "/tmp/output")
+	if err != nil {
+		t.Fatalf("Error reading output folder: %v", err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	expected := []string{"Insurance.pdf", "damaged.pdf"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("Expected files %v, got %v", expected, names)
+	}
+}
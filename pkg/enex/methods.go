@@ -1,14 +1,14 @@
 package enex
 
 import (
-	"encoding/base64"
 	"encoding/xml"
 	"enex2paperless/pkg/paperless"
+	"enex2paperless/pkg/templates"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"path/filepath"
-	"regexp"
 	"strings"
 
 	"github.com/spf13/afero"
@@ -29,15 +29,11 @@ func (e *EnexFile) RetryFeeder(failedNotes *[]Note) {
 	close(e.NoteChannel)
 }
 
-func (e *EnexFile) ReadFromFile() error {
-	slog.Debug(fmt.Sprintf("opening file: %v", e.FilePath))
-	file, err := e.Fs.Open(e.FilePath)
-	if err != nil {
-		return fmt.Errorf("error opening file: %w", err)
-	}
-	defer file.Close()
-
-	decoder := xml.NewDecoder(file)
+// decodeNotes decodes the notes of an ENEX export and sends them to the NoteChannel.
+// notebook is the name of the ENEX file the notes are read from. Notes that can't
+// be decoded are skipped, errors of the XML or of the reader stop the decoding.
+func (e *EnexFile) decodeNotes(r io.Reader, notebook string) error {
+	decoder := xml.NewDecoder(r)
 	decoder.Strict = false
 
 	slog.Debug("decoding XML")
@@ -47,9 +43,7 @@ func (e *EnexFile) ReadFromFile() error {
 			break
 		}
 		if err != nil {
-			// Log this error but continue parsing
-			slog.Error("XML parsing error", "error", err)
-			break
+			return fmt.Errorf("error parsing XML after %d notes: %w", e.notesRead, err)
 		}
 		switch se := t.(type) {
 		case xml.StartElement:
@@ -63,12 +57,25 @@ func (e *EnexFile) ReadFromFile() error {
 					slog.Error("XML decoding error", "error", err)
 					continue
 				}
+				note.notebook = notebook
+
+				index := e.notesRead
+				note.index = index
+				e.notesRead++
+				if e.filter != nil && !e.filter.match(&note) {
+					slog.Debug("note filtered out", "title", note.Title)
+					e.notesFiltered++
+					continue
+				}
+				if e.Select != nil && !e.Select(index, &note) {
+					slog.Debug("skipping note", "title", note.Title)
+					continue
+				}
 				e.NoteChannel <- note
 			}
 		}
 	}
-	slog.Debug("completed XML decoding: closing noteChannel")
-	close(e.NoteChannel)
+	slog.Debug("completed XML decoding")
 	return nil
 }
 
@@ -103,30 +110,38 @@ func (e *EnexFile) PrintNoteInfo() {
 }
 
 func (e *EnexFile) SaveResourceToDisk(decodedData []byte, resource Resource, outputFolder string) error {
-	// Create the output folder if it doesn't exist
-	err := e.Fs.MkdirAll(outputFolder, 0755)
-	if err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
-	}
+	_, err := e.saveResource(decodedData, resource, outputFolder)
+	return err
+}
 
+// saveResource writes a resource to the output folder and returns the path of the
+// written file. A counter is added to the file name if the file already exists.
+func (e *EnexFile) saveResource(decodedData []byte, resource Resource, outputFolder string) (string, error) {
+	// the file name may contain subdirectories of the output folder
 	fileName := filepath.Join(outputFolder, resource.ResourceAttributes.FileName)
 	counter := 1
 
+	// Create the output folder if it doesn't exist
+	err := e.Fs.MkdirAll(filepath.Dir(fileName), 0755)
+	if err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
 	for {
 		// check if the file already exists
 		exists, err := afero.Exists(e.Fs, fileName)
 		if err != nil {
-			return fmt.Errorf("failed to check if file exists: %w", err)
+			return "", fmt.Errorf("failed to check if file exists: %w", err)
 		}
 
 		if !exists {
 			// if the file doesn't exist, write the file
 			if err := afero.WriteFile(e.Fs, fileName, decodedData, 0644); err != nil {
-				return fmt.Errorf("failed to write file: %w", err)
+				return "", fmt.Errorf("failed to write file: %w", err)
 			}
 
 			slog.Info(fmt.Sprintf("file saved: %s", fileName))
-			return nil
+			return fileName, nil
 		}
 
 		// if file exists, construct a new file name with a counter
@@ -137,125 +152,192 @@ func (e *EnexFile) SaveResourceToDisk(decodedData []byte, resource Resource, out
 	}
 }
 
+// saveDocument writes a document of a note to the output folder, followed by its
+// sidecar file and its manifest record if enabled
+func (e *EnexFile) saveDocument(note Note, data templates.Data, title string, content []byte, resource Resource, outputFolder string) error {
+	fileName, err := e.saveResource(content, resource, outputFolder)
+	if err != nil {
+		return err
+	}
+
+	if e.manifest != nil {
+		err = e.addToManifest(note, data, title, content, resource.Mime, fileName, outputFolder)
+		if errors.Is(err, paperless.ErrDuplicateDocument) {
+			// Paperless requires unique documents
+			slog.Warn("skipping duplicate document", "file", fileName, "note", note.Title)
+			return e.Fs.Remove(fileName)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if e.config.Sidecar == "" {
+		return nil
+	}
+	return e.saveSidecar(fileName, newSidecar(note, data, resource.Mime, content))
+}
+
 func (e *EnexFile) UploadFromNoteChannel(outputFolder string) error {
 	slog.Debug("starting UploadFromNoteChannel")
 
 	for note := range e.NoteChannel {
-		if len(note.Resources) < 1 {
-			slog.Debug(fmt.Sprintf("ignoring note without attachement: %s", note.Title))
-			continue
-		}
+		e.processNote(note, outputFolder)
+	}
+
+	return nil
+}
+
+// processNote imports the attachments of a note, or the rendered note, to Paperless
+// or the output folder. Notes that fail are sent to the FailedNoteChannel.
+func (e *EnexFile) processNote(note Note, outputFolder string) {
+	renderNote := e.config.RenderNotes && !e.hasWantedResources(note)
+	if len(note.Resources) < 1 && !renderNote {
+		slog.Debug(fmt.Sprintf("ignoring note without attachement: %s", note.Title))
+		return
+	}
 
-		e.NumNotes.Add(1)
+	e.NumNotes.Add(1)
 
-		// Convert date format early to fail fast if there's an issue
-		formattedCreatedDate, err := convertDateFormat(note.Created)
+	// Convert date format early to fail fast if there's an issue
+	formattedCreatedDate, err := convertDateFormat(note.Created)
+	if err != nil {
+		e.FailedNoteChannel <- note
+		slog.Error("error converting date format", "error", err)
+		return
+	}
+
+	// Combine note.Tags and additional tags into one slice to process
+	allTags := append([]string{}, note.Tags...)
+	allTags = append(allTags, e.additionalTags(e.notebook(note))...)
+
+	if renderNote {
+		err = e.importRenderedNote(note, outputFolder, formattedCreatedDate, allTags)
 		if err != nil {
 			e.FailedNoteChannel <- note
-			slog.Error("error converting date format", "error", err)
-			continue
+			slog.Error("failed to import rendered note", "error", err)
+			return
 		}
+		e.Uploads.Add(1)
+		return
+	}
 
-		// Combine note.Tags and additional tags into one slice to process
-		allTags := append([]string{}, note.Tags...)
-		if len(e.config.AdditionalTags) > 0 {
-			allTags = append(allTags, e.config.AdditionalTags...)
+	// index and count of the documents imported from the note, used in templates
+	index, count := 0, e.documentCount(note)
+
+	// hashes of the resources that have been combined into one document
+	var merged map[string]bool
+	if resources := e.mergedResources(note); len(resources) > 0 {
+		merged, err = e.importMergedResources(note, resources, count, outputFolder, formattedCreatedDate, allTags)
+		if err != nil {
+			e.FailedNoteChannel <- note
+			slog.Error("failed to import combined attachments", "error", err)
+			return
 		}
+		e.Uploads.Add(1)
+		index++
+	}
 
-		for _, resource := range note.Resources {
-			slog.Info("processing file",
-				slog.String("file", resource.ResourceAttributes.FileName),
-			)
+	for _, resource := range note.Resources {
+		slog.Info("processing file",
+			slog.String("file", resource.ResourceAttributes.FileName),
+		)
 
-			// only process wanted file types
-			isWantedFileType, err := e.checkFileType(resource.Mime)
-			if err != nil {
-				slog.Error("error when handling MIME type", "error", err)
-				continue
-			}
+		// only process wanted file types
+		isWantedFileType, err := e.checkFileType(resource.Mime)
+		if err != nil {
+			slog.Error("error when handling MIME type", "error", err)
+			continue
+		}
 
-			if !isWantedFileType {
-				slog.Debug("skipping unwanted file type", "filename", resource.ResourceAttributes.FileName, "filetype", resource.Mime)
-				continue
-			}
+		if !isWantedFileType {
+			slog.Debug("skipping unwanted file type", "filename", resource.ResourceAttributes.FileName, "filetype", resource.Mime)
+			continue
+		}
 
-			// add padding if necessary
-			data := resource.Data
-			padding := len(data) % 4
-			if padding > 0 {
-				slog.Debug("adding padding", "padding", padding)
-				data += strings.Repeat("=", 4-padding)
-			}
+		// Decode the base64 Resource.Data
+		decodedData, err := decodeResourceData(resource.Data)
+		if errors.Is(err, errInvalidBase64) {
+			slog.Error("data is not valid base64")
+			continue
+		}
+		if err != nil {
+			e.FailedNoteChannel <- note
+			slog.Error("error decoding resource data", "error", err)
+			break
+		}
 
-			// Remove newlines and spaces from Resource.Data
-			data = strings.ReplaceAll(resource.Data, "\n", "")
-			data = strings.ReplaceAll(data, " ", "")
+		if merged[dataHash(decodedData)] {
+			continue
+		}
+		index++
 
-			// Validate that Resource.Data is valid base64
-			validBase64 := regexp.MustCompile(`^[A-Za-z0-9+/]*={0,2}$`)
-			if !validBase64.MatchString(data) {
-				slog.Error("data is not valid base64")
-				continue
-			}
+		// if resource.ResourceAttributes.FileName is empty, use the note title
+		if resource.ResourceAttributes.FileName == "" {
+			resource.ResourceAttributes.FileName = note.Title
+		}
 
-			// Decode the base64 Resource.Data
-			decodedData, err := base64.StdEncoding.DecodeString(data)
+		// Handle ZIP files if the resource is a ZIP file
+		fileName := strings.ToLower(resource.ResourceAttributes.FileName)
+		if strings.HasSuffix(fileName, ".zip") {
+			err = e.processZipFile(decodedData, resource, note, outputFolder, formattedCreatedDate, allTags, index, count)
 			if err != nil {
-				e.FailedNoteChannel <- note
-				slog.Error("error decoding resource data", "error", err)
-				break
-			}
-
-			// if resource.ResourceAttributes.FileName is empty, use the note title
-			if resource.ResourceAttributes.FileName == "" {
-				resource.ResourceAttributes.FileName = note.Title
+				slog.Error("error processing zip file", "error", err)
 			}
+			continue // Skip to next resource after processing the ZIP file
+		}
 
-			// Handle ZIP files if the resource is a ZIP file
-			fileName := strings.ToLower(resource.ResourceAttributes.FileName)
-			if strings.HasSuffix(fileName, ".zip") {
-				err = e.processZipFile(decodedData, resource, note, outputFolder, formattedCreatedDate, allTags)
-				if err != nil {
-					slog.Error("error processing zip file", "error", err)
-				}
-				continue // Skip to next resource after processing the ZIP file
-			}
+		tmplData := e.templateData(note, resource.ResourceAttributes.FileName, index, count)
 
-			// if outputFolder is set, output to disk and continue
-			if outputFolder != "" {
-				// Sanitize filename for disk storage
-				resource.ResourceAttributes.FileName = sanitizeFilename(resource.ResourceAttributes.FileName)
-				err = e.SaveResourceToDisk(decodedData, resource, outputFolder)
-				if err != nil {
-					e.FailedNoteChannel <- note
-					slog.Error("failed to save resource to disk", "error", err)
-					break
-				}
-				e.Uploads.Add(1)
-				break
-			}
-
-			// Upload to Paperless
-			paperlessFile := paperless.NewPaperlessFile(
-				note.Title,
-				resource.ResourceAttributes.FileName,
-				resource.Mime,
-				formattedCreatedDate,
-				decodedData,
-				allTags,
-				e.config,
-			)
-
-			err = paperlessFile.Upload()
+		// if outputFolder is set, output to disk and continue
+		if outputFolder != "" {
+			// Sanitize filename for disk storage
+			fileName := e.documentFileName(tmplData, sanitizeFilename(resource.ResourceAttributes.FileName))
+			resource.ResourceAttributes.FileName = e.documentPath(tmplData, fileName)
+			err = e.saveDocument(note, tmplData, e.documentTitle(tmplData, note.Title), decodedData, resource, outputFolder)
 			if err != nil {
 				e.FailedNoteChannel <- note
-				slog.Error("failed to upload file", "error", err)
+				slog.Error("failed to save resource to disk", "error", err)
 				break
 			}
-
 			e.Uploads.Add(1)
+			continue
 		}
+
+		// Upload to Paperless
+		paperlessFile := e.newPaperlessFile(note, resource, e.documentTitle(tmplData, note.Title), decodedData, formattedCreatedDate, allTags)
+		paperlessFile.FileName = e.documentFileName(tmplData, resource.ResourceAttributes.FileName)
+		err = e.upload(paperlessFile, e.uploadKey(note, fmt.Sprintf("resource %d", index)))
+		if err != nil {
+			e.FailedNoteChannel <- note
+			slog.Error("failed to upload file", "error", err)
+			break
+		}
+
+		e.Uploads.Add(1)
 	}
+}
 
-	return nil
+// newPaperlessFile prepares a resource of a note for the upload to Paperless
+// with the assignment rules, custom fields and note text applied
+func (e *EnexFile) newPaperlessFile(note Note, resource Resource, title string, data []byte, createdDate string, tags []string) *paperless.PaperlessFile {
+	paperlessFile := paperless.NewPaperlessFile(
+		title,
+		resource.ResourceAttributes.FileName,
+		resource.Mime,
+		createdDate,
+		data,
+		tags,
+		e.config,
+	)
+
+	assigned := e.matchRules(note, resource.Mime)
+	paperlessFile.DocumentType = assigned.DocumentType
+	paperlessFile.StoragePath = assigned.StoragePath
+	paperlessFile.CustomFields = e.customFieldValues(note, resource)
+
+	paperlessFile.Note = e.noteBody(note)
+	paperlessFile.PostConsume = e.postConsume
+
+	return paperlessFile
 }
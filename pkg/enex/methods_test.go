@@ -89,6 +89,21 @@ func TestSaveResourceToDisk(t *testing.T) {
 			expectedData: []byte("new readme"),
 			expectError:  false,
 		},
+		{
+			name: "subdirectory - created and counter added",
+			setupFiles: map[string][]byte{
+				"/test/output/2023/Finance/scan.pdf": []byte("existing"),
+			},
+			resource: Resource{
+				ResourceAttributes: ResourceAttributes{
+					FileName: "2023/Finance/scan.pdf",
+				},
+			},
+			data:         []byte("new scan"),
+			expectedFile: "/test/output/2023/Finance/scan-1.pdf",
+			expectedData: []byte("new scan"),
+			expectError:  false,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,161 @@
+package enex
+
+import (
+	"enex2paperless/internal/config"
+	"enex2paperless/pkg/paperless"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// mirrorRetries limits the retry cycles of a mirror when there is no retry prompt
+const mirrorRetries = 3
+
+// mirror tracks the uploads to an additional Paperless instance. Tags and other
+// objects are cached per instance, failures are collected and retried at the end of a run.
+type mirror struct {
+	name   string
+	config config.Config
+
+	mu sync.Mutex
+	// uploaded holds the keys of uploaded documents, so that the documents of
+	// retried notes aren't uploaded twice, see uploadKey
+	uploaded map[string]bool
+	failed   []mirrorUpload
+}
+
+// mirrorUpload is a failed upload to a mirror
+type mirrorUpload struct {
+	key  string
+	file *paperless.PaperlessFile
+}
+
+// MirrorResult summarizes the uploads to a mirror
+type MirrorResult struct {
+	Name     string
+	Uploaded int
+	Failed   int
+}
+
+func newMirrors(cfg config.Config) []*mirror {
+	var mirrors []*mirror
+	for _, m := range cfg.Mirrors {
+		name := m.Name
+		if name == "" {
+			name = m.PaperlessAPI
+		}
+		mirrors = append(mirrors, &mirror{
+			name:     name,
+			config:   cfg.MirrorConfig(m),
+			uploaded: make(map[string]bool),
+		})
+	}
+	return mirrors
+}
+
+// uploadKey identifies a document of a note, document names it within the note.
+// Equal files of different notes get different keys.
+func (e *EnexFile) uploadKey(note Note, document string) string {
+	return fmt.Sprintf("%s#%d/%s", e.FilePath, note.index, document)
+}
+
+// upload uploads a file to Paperless and to all mirrors, key is the uploadKey of
+// the document. Only the error of the main instance is returned, failed mirror
+// uploads are retried by retryMirrors.
+func (e *EnexFile) upload(paperlessFile *paperless.PaperlessFile, key string) error {
+	err := paperlessFile.Upload()
+
+	for _, m := range e.mirrors {
+		m.upload(paperlessFile, key)
+	}
+
+	return err
+}
+
+// upload uploads a copy of the file to the mirror, unless the document has been
+// uploaded before
+func (m *mirror) upload(paperlessFile *paperless.PaperlessFile, key string) {
+	m.mu.Lock()
+	done := m.uploaded[key]
+	m.mu.Unlock()
+	if done {
+		slog.Debug("file already uploaded to mirror", "mirror", m.name, "file", paperlessFile.FileName)
+		return
+	}
+
+	err := paperlessFile.WithConfig(m.config).Upload()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		slog.Error("failed to upload file to mirror", "mirror", m.name, "file", paperlessFile.FileName, "error", err)
+		m.failed = append(m.failed, mirrorUpload{key: key, file: paperlessFile})
+		return
+	}
+	m.uploaded[key] = true
+}
+
+// retry uploads the failed files again
+func (m *mirror) retry() {
+	m.mu.Lock()
+	failed := m.failed
+	m.failed = nil
+	m.mu.Unlock()
+
+	for _, failedUpload := range failed {
+		m.upload(failedUpload.file, failedUpload.key)
+	}
+}
+
+// failedCount returns the number of files that couldn't be uploaded to the mirror
+func (m *mirror) failedCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// documents of retried notes may have failed more than once
+	failed := make(map[string]bool)
+	for _, failedUpload := range m.failed {
+		if !m.uploaded[failedUpload.key] {
+			failed[failedUpload.key] = true
+		}
+	}
+	return len(failed)
+}
+
+// retryMirrors retries the failed uploads of every mirror. retryPrompt decides
+// whether to retry, without prompt a mirror is retried up to mirrorRetries times.
+func (e *EnexFile) retryMirrors(retryPrompt func(failedCount int) bool) []MirrorResult {
+	var results []MirrorResult
+
+	for _, m := range e.mirrors {
+		for attempt := 1; ; attempt++ {
+			failedCount := m.failedCount()
+			if failedCount == 0 {
+				break
+			}
+
+			slog.Warn("uploads to mirror failed", "mirror", m.name, "failedCount", failedCount)
+			if retryPrompt != nil && !retryPrompt(failedCount) {
+				break
+			}
+			if retryPrompt == nil && attempt > mirrorRetries {
+				break
+			}
+
+			slog.Info("retrying failed uploads to mirror", "mirror", m.name, "retryCount", failedCount)
+			m.retry()
+		}
+
+		m.mu.Lock()
+		uploaded := len(m.uploaded)
+		m.mu.Unlock()
+
+		results = append(results, MirrorResult{
+			Name:     m.name,
+			Uploaded: uploaded,
+			Failed:   m.failedCount(),
+		})
+	}
+
+	return results
+}
@@ -0,0 +1,122 @@
+package enex
+
+import (
+	"encoding/json"
+	"enex2paperless/internal/config"
+	"enex2paperless/pkg/paperless"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// newMirrorTestServer simulates a Paperless instance with one tag. The first
+// failUploads uploads fail, the tag IDs of successful uploads are recorded.
+func newMirrorTestServer(t *testing.T, tagID int, failUploads int32) (*httptest.Server, *[]string, *sync.Mutex) {
+	var mu sync.Mutex
+	var uploadedTags []string
+	var attempts atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags/":
+			json.NewEncoder(w).Encode(map[string]any{"count": 1, "results": []map[string]int{{"id": tagID}}})
+		case "/api/documents/post_document/":
+			if attempts.Add(1) <= failUploads {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Errorf("invalid upload: %v", err)
+			}
+			mu.Lock()
+			uploadedTags = append(uploadedTags, r.MultipartForm.Value["tags"]...)
+			mu.Unlock()
+			json.NewEncoder(w).Encode("task-1")
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server, &uploadedTags, &mu
+}
+
+// TestMirrorUploads verifies uploads are mirrored with independent tag caches
+// and failed mirror uploads are retried
+func TestMirrorUploads(t *testing.T) {
+	paperless.ClearTagCache()
+
+	primary, primaryTags, primaryMu := newMirrorTestServer(t, 1, 0)
+	defer primary.Close()
+	backup, backupTags, backupMu := newMirrorTestServer(t, 2, 1)
+	defer backup.Close()
+
+	cfg := config.Config{
+		PaperlessAPI: primary.URL,
+		Token:        "primary-token",
+		Mirrors: []config.Mirror{
+			{Name: "backup", PaperlessAPI: backup.URL, Token: "backup-token"},
+		},
+	}
+	enexFile := NewEnexFile("test.enex", cfg)
+
+	// equal files of different notes are both uploaded
+	notes := []Note{{Title: "first", index: 0}, {Title: "second", index: 1}}
+	for _, note := range notes {
+		paperlessFile := paperless.NewPaperlessFile(note.Title, "scan.pdf", "application/pdf", "2023-04-03 12:30:00+00:00", []byte("scan"), []string{"Finance"}, cfg)
+		if err := enexFile.upload(paperlessFile, enexFile.uploadKey(note, "resource 1")); err != nil {
+			t.Fatalf("upload() error: %v", err)
+		}
+	}
+
+	// a retried note doesn't upload to the mirror twice
+	retried := paperless.NewPaperlessFile("second", "scan.pdf", "application/pdf", "2023-04-03 12:30:00+00:00", []byte("scan"), []string{"Finance"}, cfg)
+	if err := enexFile.upload(retried, enexFile.uploadKey(notes[1], "resource 1")); err != nil {
+		t.Fatalf("upload() error: %v", err)
+	}
+
+	results := enexFile.retryMirrors(nil)
+	expected := MirrorResult{Name: "backup", Uploaded: 2, Failed: 0}
+	if len(results) != 1 || results[0] != expected {
+		t.Errorf("retryMirrors() = %+v, expected %+v", results, expected)
+	}
+
+	primaryMu.Lock()
+	defer primaryMu.Unlock()
+	backupMu.Lock()
+	defer backupMu.Unlock()
+
+	if len(*primaryTags) != 3 || (*primaryTags)[0] != "1" {
+		t.Errorf("primary tags = %v, expected tag 1 for 3 uploads", *primaryTags)
+	}
+	if len(*backupTags) != 2 || (*backupTags)[0] != "2" || (*backupTags)[1] != "2" {
+		t.Errorf("backup tags = %v, expected tag 2 for 2 uploads", *backupTags)
+	}
+}
+
+// TestMirrorRetriesAreLimited verifies a failing mirror is given up without retry prompt
+func TestMirrorRetriesAreLimited(t *testing.T) {
+	primary, _, _ := newMirrorTestServer(t, 1, 0)
+	defer primary.Close()
+	backup, _, _ := newMirrorTestServer(t, 2, 100)
+	defer backup.Close()
+
+	cfg := config.Config{
+		PaperlessAPI: primary.URL,
+		Token:        "primary-token",
+		Mirrors:      []config.Mirror{{PaperlessAPI: backup.URL, Token: "backup-token"}},
+	}
+	enexFile := NewEnexFile("test.enex", cfg)
+
+	paperlessFile := paperless.NewPaperlessFile("title", "file.pdf", "application/pdf", "2023-04-03 12:30:00+00:00", []byte("data"), nil, cfg)
+	if err := enexFile.upload(paperlessFile, enexFile.uploadKey(Note{}, "resource 1")); err != nil {
+		t.Fatalf("upload() error: %v", err)
+	}
+
+	results := enexFile.retryMirrors(nil)
+	expected := MirrorResult{Name: backup.URL, Uploaded: 0, Failed: 1}
+	if len(results) != 1 || results[0] != expected {
+		t.Errorf("retryMirrors() = %+v, expected %+v", results, expected)
+	}
+}
@@ -0,0 +1,37 @@
+package enex
+
+import (
+	"strings"
+)
+
+// noteBody returns the text that gets attached as Paperless note to the
+// documents of a note. It returns an empty string if there's nothing to attach.
+func (e *EnexFile) noteBody(note Note) string {
+	if !e.config.NoteBody {
+		return ""
+	}
+
+	text := enmlToText(note.Content)
+	if !e.config.NoteBodyMetadata {
+		return text
+	}
+
+	var metadata []string
+	if created, err := convertDateFormat(note.Created); err == nil {
+		metadata = append(metadata, "Evernote created: "+created)
+	}
+	if updated, err := convertDateFormat(note.Updated); err == nil {
+		metadata = append(metadata, "Evernote updated: "+updated)
+	}
+	if note.NoteAttributes.SourceURL != "" {
+		metadata = append(metadata, "Source: "+note.NoteAttributes.SourceURL)
+	}
+
+	if len(metadata) == 0 {
+		return text
+	}
+	if text == "" {
+		return strings.Join(metadata, "\n")
+	}
+	return text + "\n\n" + strings.Join(metadata, "\n")
+}
@@ -0,0 +1,53 @@
+package enex
+
+import (
+	"enex2paperless/internal/config"
+	"testing"
+)
+
+// TestNoteBody tests the text attached as Paperless note
+func TestNoteBody(t *testing.T) {
+	note := Note{
+		Content: `<en-note><div>paid on 3.4., ref 12345</div></en-note>`,
+		Created: "20220101T120000Z",
+		Updated: "20220102T130000Z",
+		NoteAttributes: NoteAttr{
+			SourceURL: "https://example.com/invoice",
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		config   config.Config
+		expected string
+	}{
+		{
+			name:     "disabled",
+			config:   config.Config{},
+			expected: "",
+		},
+		{
+			name:     "text only",
+			config:   config.Config{NoteBody: true},
+			expected: "paid on 3.4., ref 12345",
+		},
+		{
+			name:   "with metadata",
+			config: config.Config{NoteBody: true, NoteBodyMetadata: true},
+			expected: "paid on 3.4., ref 12345\n\n" +
+				"Evernote created: 2022-01-01 12:00:00+00:00\n" +
+				"Evernote updated: 2022-01-02 13:00:00+00:00\n" +
+				"Source: https://example.com/invoice",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &EnexFile{config: tc.config}
+			result := e.noteBody(note)
+			if result != tc.expected {
+				t.Errorf("noteBody() = %q, expected %q", result, tc.expected)
+			}
+		})
+	}
+}
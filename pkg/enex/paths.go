@@ -0,0 +1,92 @@
+package enex
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// FindFiles expands paths, globs and directories to the list of ENEX files to import.
+// Directories are searched recursively for .enex, .enex.gz and .enex.zst files, files
+// given explicitly are used regardless of their extension, "-" reads from stdin.
+// Every argument has to match at least one file, duplicates are removed.
+func FindFiles(args []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[filepath.Clean(path)] {
+			seen[filepath.Clean(path)] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, arg := range args {
+		if arg == StdinPath {
+			add(arg)
+			continue
+		}
+
+		matches := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			var err error
+			matches, err = filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %s: %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %s", arg)
+			}
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil, fmt.Errorf("input file does not exist: %s", match)
+				}
+				return nil, fmt.Errorf("cannot access input file: %w", err)
+			}
+
+			if !info.IsDir() {
+				add(match)
+				continue
+			}
+
+			found, err := findInDir(match)
+			if err != nil {
+				return nil, err
+			}
+			if len(found) == 0 {
+				return nil, fmt.Errorf("no ENEX files found in %s", match)
+			}
+			for _, path := range found {
+				add(path)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// findInDir returns the plain and compressed ENEX files in a directory and its subdirectories, sorted by path
+func findInDir(dir string) ([]string, error) {
+	var found []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && IsEnexFile(path) {
+			found = append(found, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search %s: %w", dir, err)
+	}
+
+	slices.Sort(found)
+	return found, nil
+}
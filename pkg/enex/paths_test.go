@@ -0,0 +1,113 @@
+package enex
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"Work.enex", "Travel.ENEX", "notes.txt", "Compressed.enex.zst", "archive/2019/Old.enex", "single/Only.enex"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("<en-export/>"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		expected    []string
+		expectError bool
+	}{
+		{
+			name:     "single file",
+			args:     []string{"notes.txt"},
+			expected: []string{"notes.txt"},
+		},
+		{
+			name:     "several files",
+			args:     []string{"Work.enex", "Travel.ENEX"},
+			expected: []string{"Work.enex", "Travel.ENEX"},
+		},
+		{
+			name:     "glob",
+			args:     []string{"*.enex"},
+			expected: []string{"Work.enex"},
+		},
+		{
+			name:     "directory is searched recursively",
+			args:     []string{"."},
+			expected: []string{"Compressed.enex.zst", "Travel.ENEX", "Work.enex", "archive/2019/Old.enex", "single/Only.enex"},
+		},
+		{
+			name:     "duplicates are removed",
+			args:     []string{"Work.enex", "./Work.enex", "single", "single/Only.enex"},
+			expected: []string{"Work.enex", "single/Only.enex"},
+		},
+		{
+			name:     "stdin",
+			args:     []string{"-", "Work.enex"},
+			expected: []string{"-", "Work.enex"},
+		},
+		{
+			name:        "missing file",
+			args:        []string{"Missing.enex"},
+			expectError: true,
+		},
+		{
+			name:        "glob without matches",
+			args:        []string{"*.zip"},
+			expectError: true,
+		},
+		{
+			name:        "directory without ENEX files",
+			args:        []string{"empty"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := make([]string, len(tt.args))
+			for i, arg := range tt.args {
+				args[i] = testPath(dir, arg)
+			}
+
+			files, err := FindFiles(args)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			expected := make([]string, len(tt.expected))
+			for i, path := range tt.expected {
+				expected[i] = testPath(dir, path)
+			}
+			if !reflect.DeepEqual(files, expected) {
+				t.Errorf("FindFiles() = %v, expected %v", files, expected)
+			}
+		})
+	}
+}
+
+// testPath returns the path of a test file in dir, stdin is kept
+func testPath(dir, path string) string {
+	if path == StdinPath {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -1,9 +1,10 @@
 package enex
 
 import (
+	"context"
+	"enex2paperless/pkg/paperless"
 	"fmt"
 	"log/slog"
-	"os"
 	"sync"
 )
 
@@ -19,6 +20,10 @@ type ProcessOptions struct {
 	// to decide whether to retry. Return true to retry, false to stop.
 	// If nil, retries are automatically attempted without prompting.
 	RetryPromptFunc func(failedCount int) bool
+
+	// Context cancels the processing. Notes that are being uploaded are finished,
+	// the remaining notes are skipped and not retried. If nil, processing can't be canceled.
+	Context context.Context
 }
 
 // ProcessResult contains the results of processing
@@ -26,11 +31,38 @@ type ProcessResult struct {
 	// NotesProcessed is the total number of notes processed
 	NotesProcessed int
 
+	// NotesFiltered is the number of notes skipped by the filter
+	NotesFiltered int
+
 	// FilesUploaded is the total number of files successfully uploaded
 	FilesUploaded int
 
 	// FailedNotes contains any notes that failed processing after all retries
 	FailedNotes []Note
+
+	// Mirrors summarizes the uploads to each mirror
+	Mirrors []MirrorResult
+
+	// Files summarizes each processed ENEX file
+	Files []FileResult
+}
+
+// FileResult summarizes the processing of one ENEX file
+type FileResult struct {
+	FilePath       string
+	NotesProcessed int
+	NotesFiltered  int
+	FilesUploaded  int
+	FailedNotes    int
+
+	// Err is set if the file couldn't be read
+	Err error
+}
+
+// noteJob is a note together with the file it was read from
+type noteJob struct {
+	file *EnexFile
+	note Note
 }
 
 // Process orchestrates the complete ENEX processing workflow:
@@ -39,36 +71,86 @@ type ProcessResult struct {
 // - Handles failures and retries
 // - Returns results and any remaining failures
 func (e *EnexFile) Process(opts ProcessOptions) (*ProcessResult, error) {
-	// Validate we have a file to process
-	if e.FilePath == "" {
+	return ProcessFiles([]*EnexFile{e}, opts)
+}
+
+// ProcessFiles processes several ENEX files with one pool of workers. The files are
+// read one after another, their notes keep the settings of their file, e.g. the
+// notebook tag. The first file's document importer manifest and mirrors are shared
+// by all files, so that the result and the summary cover the whole run.
+func ProcessFiles(files []*EnexFile, opts ProcessOptions) (*ProcessResult, error) {
+	// Validate we have files to process
+	if len(files) == 0 {
 		return nil, fmt.Errorf("no file path provided")
 	}
 
-	_, err := e.Fs.Stat(e.FilePath)
-	if err != nil {
-		return nil, fmt.Errorf("cannot access file %s: %w", e.FilePath, err)
+	for _, e := range files {
+		if e.FilePath == "" {
+			return nil, fmt.Errorf("no file path provided")
+		}
+
+		if e.FilePath == StdinPath {
+			continue
+		}
+
+		_, err := e.Fs.Stat(e.FilePath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot access file %s: %w", e.FilePath, err)
+		}
 	}
 
 	// Set defaults
 	if opts.ConcurrentWorkers <= 0 {
 		opts.ConcurrentWorkers = 1
 	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
 
-	// Failure Catcher
-	var failedNotes []Note
-	go func() {
-		e.FailedNoteCatcher(&failedNotes)
-		e.FailedNoteSignal <- true
-	}()
+	// uploads don't wait for the consumption of each document
+	postConsume := paperless.NewPostConsumeQueue(ctx)
+
+	first := files[0]
+	for _, e := range files {
+		e.manifest = first.manifest
+		e.mirrors = first.mirrors
+		e.postConsume = postConsume
+	}
+
+	// Failure Catchers, one per file so that failed notes are retried with their file
+	failedNotes := make([][]Note, len(files))
+	for i, e := range files {
+		go func() {
+			e.FailedNoteCatcher(&failedNotes[i])
+			e.FailedNoteSignal <- true
+		}()
+	}
 
-	// Producer: read from file and feed notes to channel
+	// Producer: read the files one after another and feed their notes to the workers
+	jobs := make(chan noteJob)
+	readErrs := make([]error, len(files))
 	go func() {
-		err := e.ReadFromFile()
-		if err != nil {
-			slog.Error("failed to read from file", "error", err)
-			// critical error, cant read file -> exit
-			os.Exit(1)
+		for i, e := range files {
+			errs := make(chan error, 1)
+			go func() {
+				errs <- e.ReadFromFile()
+			}()
+
+			for note := range e.NoteChannel {
+				select {
+				case jobs <- noteJob{file: e, note: note}:
+				case <-ctx.Done():
+					// skip the remaining notes, the reader stops at the end of the file
+				}
+			}
+
+			if err := <-errs; err != nil {
+				slog.Error("failed to read from file", "file", e.FilePath, "error", err)
+				readErrs[i] = err
+			}
 		}
+		close(jobs)
 	}()
 
 	// Consumers: spawn concurrent upload workers
@@ -76,51 +158,70 @@ func (e *EnexFile) Process(opts ProcessOptions) (*ProcessResult, error) {
 	wg.Add(opts.ConcurrentWorkers)
 
 	for i := 0; i < opts.ConcurrentWorkers; i++ {
-		go func(workerID int) {
-			err := e.UploadFromNoteChannel(opts.OutputFolder)
-			if err != nil {
-				slog.Error("worker failed to upload resources",
-					"workerID", workerID,
-					"error", err)
+		go func() {
+			for job := range jobs {
+				job.file.processNote(job.note, opts.OutputFolder)
 			}
 			wg.Done()
-		}(i)
+		}()
 	}
 
 	slog.Debug("waiting for upload workers to complete")
 	wg.Wait()
 
-	// Close failedNoteChannel when consumers are done
-	close(e.FailedNoteChannel)
-
-	// Wait for FailedNoteCatcher to finish
-	slog.Debug("waiting for FailedNoteCatcher")
-	<-e.FailedNoteSignal
+	// Close the failed note channels when consumers are done and
+	// wait for the FailedNoteCatchers to finish
+	slog.Debug("waiting for FailedNoteCatchers")
+	for _, e := range files {
+		close(e.FailedNoteChannel)
+		<-e.FailedNoteSignal
+	}
 
 	// Log initial results
-	notesProcessed := int(e.NumNotes.Load())
-	filesUploaded := int(e.Uploads.Load())
+	results := make([]FileResult, len(files))
+	var notesProcessed, notesFiltered, filesUploaded int
+	for i, e := range files {
+		results[i] = FileResult{
+			FilePath:       e.FilePath,
+			NotesProcessed: int(e.NumNotes.Load()),
+			NotesFiltered:  e.notesFiltered,
+			FilesUploaded:  int(e.Uploads.Load()),
+			Err:            readErrs[i],
+		}
+		notesProcessed += results[i].NotesProcessed
+		notesFiltered += results[i].NotesFiltered
+		filesUploaded += results[i].FilesUploaded
+	}
 
-	slog.Info("ENEX processing complete",
+	attrs := []any{
 		slog.Int("notesProcessed", notesProcessed),
 		slog.Int("filesUploaded", filesUploaded),
-	)
+	}
+	if notesFiltered > 0 {
+		attrs = append(attrs, slog.Int("notesFiltered", notesFiltered))
+	}
+	slog.Info("ENEX processing complete", attrs...)
 
 	// Retry loop for failed notes
 	for {
-		// If no failed notes, we're done
-		if len(failedNotes) == 0 {
+		failedCount := 0
+		for _, notes := range failedNotes {
+			failedCount += len(notes)
+		}
+
+		// If no failed notes or canceled, we're done
+		if failedCount == 0 || ctx.Err() != nil {
 			break
 		}
 
 		slog.Warn("notes failed to process",
-			slog.Int("failedCount", len(failedNotes)),
+			slog.Int("failedCount", failedCount),
 		)
 
 		// Check if we should retry
 		shouldRetry := true
 		if opts.RetryPromptFunc != nil {
-			shouldRetry = opts.RetryPromptFunc(len(failedNotes))
+			shouldRetry = opts.RetryPromptFunc(failedCount)
 		}
 
 		if !shouldRetry {
@@ -129,61 +230,157 @@ func (e *EnexFile) Process(opts ProcessOptions) (*ProcessResult, error) {
 		}
 
 		slog.Info("retrying failed notes",
-			slog.Int("retryCount", len(failedNotes)),
+			slog.Int("retryCount", failedCount),
 		)
 
-		// Prepare for retry
-		failedThisCycle := []Note{}
-
-		// Create a fresh EnexFile for the retry (no file path since we're feeding notes)
-		retryFile := NewEnexFile("", e.config)
-
-		// Start failure catcher for this retry
-		go func() {
-			retryFile.FailedNoteCatcher(&failedThisCycle)
-			retryFile.FailedNoteSignal <- true
-		}()
+		for i, e := range files {
+			if len(failedNotes[i]) == 0 {
+				continue
+			}
 
-		// Feed the failed notes into the retry channel
-		go retryFile.RetryFeeder(&failedNotes)
+			// Move notes that failed this cycle into failedNotes for next iteration
+			var uploads int
+			failedNotes[i], uploads = e.retry(failedNotes[i], opts.OutputFolder)
 
-		// Start a single worker for retry
-		wg.Add(1)
-		go func() {
-			err := retryFile.UploadFromNoteChannel(opts.OutputFolder)
-			if err != nil {
-				slog.Error("retry worker failed", "error", err)
-			}
-			wg.Done()
-		}()
+			// Update metrics with retry results
+			results[i].FilesUploaded += uploads
+			filesUploaded += uploads
+		}
+	}
 
-		// Wait for retry to complete
-		wg.Wait()
+	var allFailedNotes []Note
+	var readFailures int
+	for i := range files {
+		results[i].FailedNotes = len(failedNotes[i])
+		allFailedNotes = append(allFailedNotes, failedNotes[i]...)
+		if results[i].Err != nil {
+			readFailures++
+		}
+	}
 
-		// Close the retry file's failed note channel
-		close(retryFile.FailedNoteChannel)
+	// the error is returned with the result, so that the summary isn't lost
+	var manifestErr error
+	if first.manifest != nil && opts.OutputFolder != "" {
+		manifestErr = first.writeManifest(opts.OutputFolder)
+	}
 
-		// Wait for the failure catcher to finish
-		<-retryFile.FailedNoteSignal
+	// Combined report of all files
+	if len(files) > 1 {
+		for _, fileResult := range results {
+			attrs := []any{
+				slog.String("file", fileResult.FilePath),
+				slog.Int("notesProcessed", fileResult.NotesProcessed),
+				slog.Int("filesUploaded", fileResult.FilesUploaded),
+				slog.Int("failedNotes", fileResult.FailedNotes),
+			}
+			if fileResult.Err != nil {
+				attrs = append(attrs, slog.Any("error", fileResult.Err))
+			}
+			slog.Info("file summary", attrs...)
+		}
+	}
 
-		// Update metrics with retry results
-		filesUploaded += int(retryFile.Uploads.Load())
+	// Retry failed uploads to mirrors and summarize every Paperless instance
+	var mirrorResults []MirrorResult
+	var mirrorFailures int
+	if len(first.mirrors) > 0 && opts.OutputFolder == "" && ctx.Err() == nil {
+		mirrorResults = first.retryMirrors(opts.RetryPromptFunc)
 
-		// Move notes that failed this cycle into failedNotes for next iteration
-		failedNotes = failedThisCycle
+		slog.Info("upload summary",
+			slog.String("target", first.config.PaperlessAPI),
+			slog.Int("uploaded", filesUploaded),
+			slog.Int("failedNotes", len(allFailedNotes)),
+		)
+		for _, mirrorResult := range mirrorResults {
+			slog.Info("upload summary",
+				slog.String("target", mirrorResult.Name),
+				slog.Int("uploaded", mirrorResult.Uploaded),
+				slog.Int("failed", mirrorResult.Failed),
+			)
+			mirrorFailures += mirrorResult.Failed
+		}
 	}
 
+	slog.Debug("waiting for the updates after consumption")
+	postConsume.Wait()
+
 	// Final results
 	result := &ProcessResult{
 		NotesProcessed: notesProcessed,
+		NotesFiltered:  notesFiltered,
 		FilesUploaded:  filesUploaded,
-		FailedNotes:    failedNotes,
+		FailedNotes:    allFailedNotes,
+		Mirrors:        mirrorResults,
+		Files:          results,
 	}
 
-	if len(failedNotes) > 0 {
-		return result, fmt.Errorf("%d notes failed to process", len(failedNotes))
+	if err := ctx.Err(); err != nil {
+		return result, fmt.Errorf("processing canceled: %w", err)
+	}
+	if manifestErr != nil {
+		return result, manifestErr
+	}
+	if len(allFailedNotes) > 0 {
+		return result, fmt.Errorf("%d notes failed to process", len(allFailedNotes))
+	}
+	if readFailures > 0 {
+		return result, fmt.Errorf("%d files couldn't be read", readFailures)
+	}
+	if mirrorFailures > 0 {
+		return result, fmt.Errorf("%d files failed to upload to mirrors", mirrorFailures)
 	}
 
 	slog.Info("all notes processed successfully")
 	return result, nil
 }
+
+// RetryOnce returns a RetryPromptFunc that retries once without asking, for runs
+// without a user at the terminal
+func RetryOnce() func(failedCount int) bool {
+	retried := false
+	return func(failedCount int) bool {
+		if retried {
+			return false
+		}
+		retried = true
+		slog.Warn("there have been errors, starting retry cycle", "errors", failedCount)
+		return true
+	}
+}
+
+// retry processes failed notes of the file again with a single worker. It returns
+// the notes that failed again and the number of uploaded files.
+func (e *EnexFile) retry(failedNotes []Note, outputFolder string) ([]Note, int) {
+	failedThisCycle := []Note{}
+
+	// Create a fresh EnexFile for the retry. Notes are fed from failedNotes,
+	// the file path is only kept for rule matching.
+	retryFile := NewEnexFile(e.FilePath, e.config)
+	retryFile.Fs = e.Fs
+	retryFile.NotebookTag = e.NotebookTag
+	retryFile.manifest = e.manifest
+	retryFile.mirrors = e.mirrors
+	retryFile.postConsume = e.postConsume
+
+	// Start failure catcher for this retry
+	go func() {
+		retryFile.FailedNoteCatcher(&failedThisCycle)
+		retryFile.FailedNoteSignal <- true
+	}()
+
+	// Feed the failed notes into the retry channel
+	go retryFile.RetryFeeder(&failedNotes)
+
+	// Run a single worker for retry and wait for it to complete
+	err := retryFile.UploadFromNoteChannel(outputFolder)
+	if err != nil {
+		slog.Error("retry worker failed", "error", err)
+	}
+
+	// Close the retry file's failed note channel and
+	// wait for the failure catcher to finish
+	close(retryFile.FailedNoteChannel)
+	<-retryFile.FailedNoteSignal
+
+	return failedThisCycle, int(retryFile.Uploads.Load())
+}
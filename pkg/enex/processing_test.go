@@ -1,7 +1,11 @@
 package enex
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"enex2paperless/internal/config"
+	"errors"
 	"strings"
 	"testing"
 
@@ -129,15 +133,17 @@ func TestProcessingMultipleResourcesPerNote(t *testing.T) {
 		t.Errorf("Expected 1 note processed, got %d", enexFile.NumNotes.Load())
 	}
 
-	// Verify only first resource was saved (due to break after first save when outputFolder is set)
-	if enexFile.Uploads.Load() != 1 {
-		t.Errorf("Expected 1 upload (breaks after first when saving to disk), got %d", enexFile.Uploads.Load())
+	// Verify every resource was saved
+	if enexFile.Uploads.Load() != 3 {
+		t.Errorf("Expected 3 uploads, got %d", enexFile.Uploads.Load())
 	}
 
-	// Verify the first file was created
-	exists, _ := afero.Exists(mockFs, outputFolder+"/document1.pdf")
-	if !exists {
-		t.Error("First resource file was not created")
+	// Verify the files were created
+	for _, fileName := range []string{"document1.pdf", "document2.pdf", "notes.txt"} {
+		exists, _ := afero.Exists(mockFs, outputFolder+"/"+fileName)
+		if !exists {
+			t.Errorf("Resource file %s was not created", fileName)
+		}
 	}
 }
 
@@ -349,3 +355,95 @@ func TestProcessingInvalidDateFormat(t *testing.T) {
 		t.Error("Expected failed note in FailedNoteChannel")
 	}
 }
+
+// TestProcessingMultipleFiles verifies that notes keep the tags of their file and
+// that the files share the manifest and the report
+func TestProcessingMultipleFiles(t *testing.T) {
+	mockFs := afero.NewMemMapFs()
+	note := func(title, fileName string) string {
+		data := base64.StdEncoding.EncodeToString([]byte(title))
+		return `<note><title>` + title + `</title><created>20230403T123000Z</created>
+			<resource><data>` + data + `</data><mime>application/pdf</mime>
+			<resource-attributes><file-name>` + fileName + `</file-name></resource-attributes></resource></note>`
+	}
+	afero.WriteFile(mockFs, "/export/Work.enex", []byte(`<en-export>`+note("Contract", "contract.pdf")+note("Offer", "offer.pdf")+`</en-export>`), 0644)
+	afero.WriteFile(mockFs, "/export/Travel.enex", []byte(`<en-export>`+note("Ticket", "ticket.pdf")+`</en-export>`), 0644)
+
+	var files []*EnexFile
+	for _, notebook := range []string{"Work", "Travel"} {
+		e := NewEnexFile("/export/"+notebook+".enex", config.Config{
+			FileTypes:        []string{"pdf"},
+			AdditionalTags:   []string{notebook},
+			DocumentImporter: true,
+		})
+		e.Fs = mockFs
+		files = append(files, e)
+	}
+
+	result, err := ProcessFiles(files, ProcessOptions{ConcurrentWorkers: 2, OutputFolder: "/tmp/export"})
+	if err != nil {
+		t.Fatalf("ProcessFiles() error: %v", err)
+	}
+
+	if result.NotesProcessed != 3 || result.FilesUploaded != 3 {
+		t.Errorf("got %d notes and %d files, expected 3 and 3", result.NotesProcessed, result.FilesUploaded)
+	}
+	if len(result.Files) != 2 || result.Files[0].FilesUploaded != 2 || result.Files[1].FilesUploaded != 1 {
+		t.Errorf("unexpected file results: %+v", result.Files)
+	}
+
+	data, err := afero.ReadFile(mockFs, "/tmp/export/manifest.json")
+	if err != nil {
+		t.Fatalf("manifest not written: %v", err)
+	}
+
+	var records []struct {
+		Model  string         `json:"model"`
+		PK     int            `json:"pk"`
+		Fields map[string]any `json:"fields"`
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		t.Fatalf("invalid manifest: %v", err)
+	}
+
+	tagNames := make(map[float64]string)
+	for _, record := range records {
+		if record.Model == "documents.tag" {
+			tagNames[float64(record.PK)] = record.Fields["name"].(string)
+		}
+	}
+
+	expectedTags := map[string]string{"Contract": "Work", "Offer": "Work", "Ticket": "Travel"}
+	var documents int
+	for _, record := range records {
+		if record.Model != "documents.document" {
+			continue
+		}
+		documents++
+		title := record.Fields["title"].(string)
+		tags := record.Fields["tags"].([]any)
+		if len(tags) != 1 || tagNames[tags[0].(float64)] != expectedTags[title] {
+			t.Errorf("note %s has tags %v, expected %s", title, tags, expectedTags[title])
+		}
+	}
+	if documents != 3 {
+		t.Errorf("got %d documents in the manifest, expected 3", documents)
+	}
+}
+
+// TestProcessingCanceled verifies that a canceled run stops and reports the cancellation
+func TestProcessingCanceled(t *testing.T) {
+	mockFs := afero.NewMemMapFs()
+	afero.WriteFile(mockFs, "/export/Work.enex", []byte(`<en-export><note><title>Contract</title></note></en-export>`), 0644)
+
+	e := NewEnexFile("/export/Work.enex", config.Config{FileTypes: []string{"pdf"}})
+	e.Fs = mockFs
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := e.Process(ProcessOptions{OutputFolder: "/tmp/export", Context: ctx})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation error, got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package enex
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"enex2paperless/pkg/enml"
+	"enex2paperless/pkg/pdf"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// hasWantedResources reports whether a note has at least one resource of a wanted file type
+func (e *EnexFile) hasWantedResources(note Note) bool {
+	for _, resource := range note.Resources {
+		isWantedFileType, err := e.checkFileType(resource.Mime)
+		if err == nil && isWantedFileType {
+			return true
+		}
+	}
+	return false
+}
+
+// importRenderedNote renders a note to PDF and saves it to disk or uploads it
+// to Paperless like an attachment of the note
+func (e *EnexFile) importRenderedNote(note Note, outputFolder string, createdDate string, tags []string) error {
+	slog.Info("rendering note to PDF", slog.String("note", note.Title))
+
+	pdfNote, err := newPDFNote(note)
+	if err != nil {
+		return err
+	}
+	pdfNote.Media = noteMedia(note)
+
+	data, err := pdf.RenderNote(pdfNote)
+	if err != nil {
+		return fmt.Errorf("error rendering note %q: %w", note.Title, err)
+	}
+
+	return e.importPDF(note, data, 1, outputFolder, createdDate, tags)
+}
+
+// importPDF saves a PDF document generated from a note to disk or uploads it to
+// Paperless. Generated documents are the first of the count documents of a note.
+func (e *EnexFile) importPDF(note Note, data []byte, count int, outputFolder string, createdDate string, tags []string) error {
+	fileName := sanitizeFilename(note.Title) + ".pdf"
+	tmplData := e.templateData(note, fileName, 1, count)
+
+	resource := Resource{
+		Mime: "application/pdf",
+		ResourceAttributes: ResourceAttributes{
+			FileName: e.documentFileName(tmplData, fileName),
+		},
+	}
+
+	if outputFolder != "" {
+		resource.ResourceAttributes.FileName = e.documentPath(tmplData, resource.ResourceAttributes.FileName)
+		return e.saveDocument(note, tmplData, e.documentTitle(tmplData, note.Title), data, resource, outputFolder)
+	}
+
+	paperlessFile := e.newPaperlessFile(note, resource, e.documentTitle(tmplData, note.Title), data, createdDate, tags)
+	return e.upload(paperlessFile, e.uploadKey(note, "note"))
+}
+
+// newPDFNote prepares the details and the parsed content of a note for rendering
+func newPDFNote(note Note) (pdf.Note, error) {
+	body, err := enml.Parse(note.Content)
+	if err != nil {
+		// the parser is lenient, render whatever could be parsed
+		slog.Debug("couldn't parse note content", "note", note.Title, "error", err)
+	}
+
+	created, err := time.Parse("20060102T150405Z", note.Created)
+	if err != nil {
+		return pdf.Note{}, fmt.Errorf("error parsing time: %w", err)
+	}
+
+	// the updated date is optional
+	updated, _ := time.Parse("20060102T150405Z", note.Updated)
+
+	return pdf.Note{
+		Title:     note.Title,
+		Created:   created,
+		Updated:   updated,
+		Tags:      note.Tags,
+		SourceURL: note.NoteAttributes.SourceURL,
+		Body:      body,
+	}, nil
+}
+
+// noteMedia resolves the en-media hashes of a note to its decoded resources
+func noteMedia(note Note) func(hash string) (enml.Media, bool) {
+	media := make(map[string]enml.Media)
+	for _, resource := range decodeResources(note.Resources) {
+		media[resource.hash] = resource.media
+	}
+
+	return func(hash string) (enml.Media, bool) {
+		m, ok := media[strings.ToLower(hash)]
+		return m, ok
+	}
+}
+
+// decodedResource is a resource with its decoded data
+type decodedResource struct {
+	media enml.Media
+	// hash is the hex encoded MD5 hash of the data that en-media elements refer to
+	hash string
+}
+
+// decodeResources decodes the data of resources. Resources that can't be decoded are skipped.
+func decodeResources(resources []Resource) []decodedResource {
+	var decoded []decodedResource
+	for _, resource := range resources {
+		data, err := decodeResourceData(resource.Data)
+		if err != nil {
+			slog.Warn("couldn't decode resource", "file", resource.ResourceAttributes.FileName, "error", err)
+			continue
+		}
+
+		decoded = append(decoded, decodedResource{
+			media: enml.Media{
+				FileName: resource.ResourceAttributes.FileName,
+				MimeType: resource.Mime,
+				Data:     data,
+			},
+			hash: dataHash(data),
+		})
+	}
+	return decoded
+}
+
+// dataHash returns the hex encoded MD5 hash Evernote uses to refer to resource data
+func dataHash(data []byte) string {
+	hash := md5.Sum(data)
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,107 @@
+package enex
+
+import (
+	"encoding/base64"
+	"enex2paperless/internal/config"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// TestProcessingRenderedNotes verifies notes without wanted attachments are rendered to PDF
+func TestProcessingRenderedNotes(t *testing.T) {
+	mockFs := afero.NewMemMapFs()
+	cfg := config.Config{
+		FileTypes:   []string{"pdf"},
+		RenderNotes: true,
+	}
+
+	enexFile := &EnexFile{
+		Fs:                mockFs,
+		config:            cfg,
+		NoteChannel:       make(chan Note, 10),
+		FailedNoteChannel: make(chan Note, 10),
+	}
+
+	done := make(chan bool)
+	go func() {
+		err := enexFile.UploadFromNoteChannel("/tmp/output")
+		if err != nil {
+			t.Errorf("UploadFromNoteChannel error: %v", err)
+		}
+		done <- true
+	}()
+
+	enexFile.NoteChannel <- Note{
+		Title:   "Letter: Insurance",
+		Created: "20220101T120000Z",
+		Content: "<en-note><div>Dear Sir or Madam,</div><ul><li>item</li></ul></en-note>",
+	}
+
+	// the image isn't a wanted file type, so the note is rendered with the image inline
+	enexFile.NoteChannel <- Note{
+		Title:   "Clipped receipt",
+		Created: "20220101T120000Z",
+		Content: `<en-note><div>Total: 12.50</div><en-media hash="0f" type="image/png"/></en-note>`,
+		Resources: []Resource{{
+			Data: base64.StdEncoding.EncodeToString([]byte("not really an image")),
+			Mime: "image/png",
+		}},
+	}
+
+	close(enexFile.NoteChannel)
+	<-done
+
+	if enexFile.NumNotes.Load() != 2 {
+		t.Errorf("Expected 2 notes processed, got %d", enexFile.NumNotes.Load())
+	}
+	if enexFile.Uploads.Load() != 2 {
+		t.Errorf("Expected 2 uploads, got %d", enexFile.Uploads.Load())
+	}
+
+	for _, fileName := range []string{"/tmp/output/Letter_ Insurance.pdf", "/tmp/output/Clipped receipt.pdf"} {
+		data, err := afero.ReadFile(mockFs, fileName)
+		if err != nil {
+			t.Errorf("Expected rendered note %s: %v", fileName, err)
+			continue
+		}
+		if !strings.HasPrefix(string(data), "%PDF-") {
+			t.Errorf("Expected %s to be a PDF document", fileName)
+		}
+	}
+}
+
+// TestNoteMedia verifies en-media hashes are resolved to the decoded resources
+func TestNoteMedia(t *testing.T) {
+	note := Note{
+		Resources: []Resource{
+			{
+				Data: base64.StdEncoding.EncodeToString([]byte("hello")),
+				Mime: "image/png",
+				ResourceAttributes: ResourceAttributes{
+					FileName: "hello.png",
+				},
+			},
+			{
+				Data: "not base64!",
+				Mime: "image/png",
+			},
+		},
+	}
+
+	media := noteMedia(note)
+
+	// MD5 of "hello"
+	m, ok := media("5D41402ABC4B2A76B9719D911017C592")
+	if !ok {
+		t.Fatal("Expected resource to be resolved by its MD5 hash")
+	}
+	if m.FileName != "hello.png" || m.MimeType != "image/png" || string(m.Data) != "hello" {
+		t.Errorf("Unexpected media: %+v", m)
+	}
+
+	if _, ok := media("0123"); ok {
+		t.Error("Expected unknown hash not to be resolved")
+	}
+}
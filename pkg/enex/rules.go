@@ -0,0 +1,117 @@
+package enex
+
+import (
+	"enex2paperless/internal/config"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// assignment holds the document type and storage path selected by the rules
+type assignment struct {
+	DocumentType string
+	StoragePath  string
+}
+
+// rule is a configured rule with its compiled title regex
+type rule struct {
+	config.Rule
+	title *regexp.Regexp
+
+	// invalid is set if the title regex doesn't compile, the rule never matches
+	invalid bool
+}
+
+// compiledRules returns the rules of the configuration. Their title regexes are
+// compiled on first use and shared by all notes of the file.
+func (e *EnexFile) compiledRules() []rule {
+	e.rulesOnce.Do(func() {
+		for _, r := range e.config.Rules {
+			compiled := rule{Rule: r}
+			if r.Title != "" {
+				re, err := regexp.Compile(r.Title)
+				if err != nil {
+					// the configuration validation reports invalid regexes
+					slog.Error("invalid title regex in rule", "regex", r.Title, "error", err)
+					compiled.invalid = true
+				}
+				compiled.title = re
+			}
+			e.rules = append(e.rules, compiled)
+		}
+	})
+	return e.rules
+}
+
+// matchRules evaluates the configured rules in order against a note and the
+// MIME type of one of its files. For document type and storage path, the first
+// matching rule that sets a value wins.
+func (e *EnexFile) matchRules(note Note, mimeType string) assignment {
+	var result assignment
+
+	for _, rule := range e.compiledRules() {
+		if result.DocumentType != "" && result.StoragePath != "" {
+			break
+		}
+
+		if !e.ruleMatches(rule, note, mimeType) {
+			continue
+		}
+
+		if result.DocumentType == "" {
+			result.DocumentType = rule.DocumentType
+		}
+		if result.StoragePath == "" {
+			result.StoragePath = rule.StoragePath
+		}
+	}
+
+	if result.DocumentType != "" || result.StoragePath != "" {
+		slog.Debug("rules matched",
+			"title", note.Title,
+			"documentType", result.DocumentType,
+			"storagePath", result.StoragePath,
+		)
+	}
+
+	return result
+}
+
+// ruleMatches checks whether all conditions of a rule are met
+func (e *EnexFile) ruleMatches(rule rule, note Note, mimeType string) bool {
+	if rule.invalid {
+		return false
+	}
+
+	for _, tag := range rule.Tags {
+		hasTag := slices.ContainsFunc(note.Tags, func(t string) bool {
+			return strings.EqualFold(t, tag)
+		})
+		if !hasTag {
+			return false
+		}
+	}
+
+	if rule.title != nil && !rule.title.MatchString(note.Title) {
+		return false
+	}
+
+	if rule.MimeType != "" {
+		ok, err := path.Match(strings.ToLower(rule.MimeType), strings.ToLower(mimeType))
+		if err != nil || !ok {
+			return false
+		}
+	}
+
+	if rule.EnexFile != "" {
+		ok, err := path.Match(rule.EnexFile, filepath.Base(e.FilePath))
+		if err != nil || !ok {
+			return false
+		}
+	}
+
+	return true
+}
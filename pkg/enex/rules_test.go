@@ -0,0 +1,96 @@
+package enex
+
+import (
+	"enex2paperless/internal/config"
+	"testing"
+)
+
+// TestMatchRules tests rule evaluation for document type and storage path assignment
+func TestMatchRules(t *testing.T) {
+	rules := []config.Rule{
+		{Tags: []string{"tax"}, Title: "(?i)invoice", DocumentType: "Invoice"},
+		{MimeType: "image/*", DocumentType: "Photo", StoragePath: "Photos"},
+		{EnexFile: "Finance*.enex", StoragePath: "Finance"},
+		{Tags: []string{"tax"}, DocumentType: "Tax"},
+	}
+
+	testCases := []struct {
+		name     string
+		filePath string
+		note     Note
+		mimeType string
+		expected assignment
+	}{
+		{
+			name:     "tag and title match",
+			filePath: "export.enex",
+			note:     Note{Title: "Invoice 2022", Tags: []string{"TAX"}},
+			mimeType: "application/pdf",
+			expected: assignment{DocumentType: "Invoice"},
+		},
+		{
+			name:     "first matching rule wins per attribute",
+			filePath: "Finance 2022.enex",
+			note:     Note{Title: "Invoice 2022", Tags: []string{"tax"}},
+			mimeType: "image/jpeg",
+			expected: assignment{DocumentType: "Invoice", StoragePath: "Photos"},
+		},
+		{
+			name:     "enex file glob",
+			filePath: "/exports/Finance.enex",
+			note:     Note{Title: "Statement"},
+			mimeType: "application/pdf",
+			expected: assignment{StoragePath: "Finance"},
+		},
+		{
+			name:     "tag without title match falls through",
+			filePath: "export.enex",
+			note:     Note{Title: "Receipt", Tags: []string{"tax"}},
+			mimeType: "application/pdf",
+			expected: assignment{DocumentType: "Tax"},
+		},
+		{
+			name:     "no match",
+			filePath: "export.enex",
+			note:     Note{Title: "Receipt"},
+			mimeType: "application/pdf",
+			expected: assignment{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &EnexFile{
+				FilePath: tc.filePath,
+				config:   config.Config{Rules: rules},
+			}
+
+			result := e.matchRules(tc.note, tc.mimeType)
+			if result != tc.expected {
+				t.Errorf("matchRules() = %+v, expected %+v", result, tc.expected)
+			}
+		})
+	}
+}
+
+// TestCompiledRules verifies that the title regexes are compiled once and that a
+// rule with an invalid regex never matches
+func TestCompiledRules(t *testing.T) {
+	e := NewEnexFile("export.enex", config.Config{Rules: []config.Rule{
+		{Title: "(unclosed", DocumentType: "Invalid"},
+		{Title: "(?i)^invoice", DocumentType: "Invoice"},
+	}})
+
+	rules := e.compiledRules()
+	if len(rules) != 2 || !rules[0].invalid || rules[1].title == nil {
+		t.Fatalf("unexpected rules: %+v", rules)
+	}
+	if &e.compiledRules()[0] != &rules[0] {
+		t.Error("rules were compiled again")
+	}
+
+	result := e.matchRules(Note{Title: "Invoice 2022"}, "application/pdf")
+	if result != (assignment{DocumentType: "Invoice"}) {
+		t.Errorf("matchRules() = %+v, expected the Invoice document type", result)
+	}
+}
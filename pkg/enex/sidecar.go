@@ -0,0 +1,95 @@
+package enex
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"enex2paperless/pkg/templates"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// sidecar holds the metadata of a file saved to the output folder, so that other
+// tools or a later import into Paperless can rebuild it
+type sidecar struct {
+	Title            string            `json:"title" yaml:"title"`
+	Tags             []string          `json:"tags" yaml:"tags"`
+	Created          string            `json:"created,omitempty" yaml:"created,omitempty"`
+	Updated          string            `json:"updated,omitempty" yaml:"updated,omitempty"`
+	NoteAttributes   sidecarAttributes `json:"note_attributes" yaml:"note_attributes"`
+	OriginalFileName string            `json:"original_filename" yaml:"original_filename"`
+	ZipFile          string            `json:"zip_file,omitempty" yaml:"zip_file,omitempty"`
+	EnexFile         string            `json:"enex_file,omitempty" yaml:"enex_file,omitempty"`
+	MimeType         string            `json:"mime_type" yaml:"mime_type"`
+	SHA256           string            `json:"sha256" yaml:"sha256"`
+}
+
+// sidecarAttributes has the same fields as NoteAttr, but with keys for JSON and YAML
+type sidecarAttributes struct {
+	Location          string  `json:"location,omitempty" yaml:"location,omitempty"`
+	SubjectDate       string  `json:"subject_date,omitempty" yaml:"subject_date,omitempty"`
+	Latitude          float64 `json:"latitude,omitempty" yaml:"latitude,omitempty"`
+	Longitude         float64 `json:"longitude,omitempty" yaml:"longitude,omitempty"`
+	Altitude          float64 `json:"altitude,omitempty" yaml:"altitude,omitempty"`
+	Author            string  `json:"author,omitempty" yaml:"author,omitempty"`
+	Source            string  `json:"source,omitempty" yaml:"source,omitempty"`
+	SourceURL         string  `json:"source_url,omitempty" yaml:"source_url,omitempty"`
+	SourceApplication string  `json:"source_application,omitempty" yaml:"source_application,omitempty"`
+	PlaceName         string  `json:"place_name,omitempty" yaml:"place_name,omitempty"`
+	ContentClass      string  `json:"content_class,omitempty" yaml:"content_class,omitempty"`
+}
+
+// newSidecar collects the metadata of a document. data holds the original file name.
+func newSidecar(note Note, data templates.Data, mimeType string, content []byte) sidecar {
+	hash := sha256.Sum256(content)
+
+	s := sidecar{
+		Title:            note.Title,
+		Tags:             note.Tags,
+		NoteAttributes:   sidecarAttributes(note.NoteAttributes),
+		OriginalFileName: data.FileName,
+		ZipFile:          data.ZipFile,
+		EnexFile:         data.EnexFile,
+		MimeType:         mimeType,
+		SHA256:           hex.EncodeToString(hash[:]),
+	}
+	if s.Tags == nil {
+		s.Tags = []string{}
+	}
+	if !data.Note.Created.IsZero() {
+		s.Created = data.Note.Created.Format(time.RFC3339)
+	}
+	if !data.Note.Updated.IsZero() {
+		s.Updated = data.Note.Updated.Format(time.RFC3339)
+	}
+	return s
+}
+
+// saveSidecar writes the metadata next to the file, e.g. scan.pdf.json
+func (e *EnexFile) saveSidecar(fileName string, metadata sidecar) error {
+	var out []byte
+	var err error
+	switch e.config.Sidecar {
+	case "json":
+		out, err = json.MarshalIndent(metadata, "", "  ")
+	case "yaml":
+		out, err = yaml.Marshal(metadata)
+	default:
+		return fmt.Errorf("unknown sidecar format: %s", e.config.Sidecar)
+	}
+	if err != nil {
+		return fmt.Errorf("error encoding sidecar: %w", err)
+	}
+
+	sidecarName := fileName + "." + e.config.Sidecar
+	if err := afero.WriteFile(e.Fs, sidecarName, out, 0644); err != nil {
+		return fmt.Errorf("failed to write sidecar: %w", err)
+	}
+
+	slog.Debug("sidecar saved", "file", sidecarName)
+	return nil
+}
@@ -0,0 +1,160 @@
+package enex
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"enex2paperless/internal/config"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// TestProcessingSidecars verifies a metadata file is written next to saved files
+func TestProcessingSidecars(t *testing.T) {
+	note := Note{
+		Title:   "Insurance",
+		Created: "20230403T123000Z",
+		Updated: "20230405T080000Z",
+		Tags:    []string{"Finance", "Car"},
+		NoteAttributes: NoteAttr{
+			Author:    "Jane",
+			SourceURL: "https://example.com/policy",
+		},
+		Resources: []Resource{
+			{
+				// "test data"
+				Data:               "dGVzdCBkYXRh",
+				Mime:               "application/pdf",
+				ResourceAttributes: ResourceAttributes{FileName: "policy.pdf"},
+			},
+		},
+	}
+
+	expected := sidecar{
+		Title:   "Insurance",
+		Tags:    []string{"Finance", "Car"},
+		Created: "2023-04-03T12:30:00Z",
+		Updated: "2023-04-05T08:00:00Z",
+		NoteAttributes: sidecarAttributes{
+			Author:    "Jane",
+			SourceURL: "https://example.com/policy",
+		},
+		OriginalFileName: "policy.pdf",
+		EnexFile:         "Archive",
+		MimeType:         "application/pdf",
+		SHA256:           "916f0027a575074ce72a331777c3478d6513f786a591bd892da1a577bf2335f9",
+	}
+
+	tests := []struct {
+		format    string
+		unmarshal func([]byte, any) error
+	}{
+		{"json", json.Unmarshal},
+		{"yaml", yaml.Unmarshal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			mockFs := afero.NewMemMapFs()
+			enexFile := &EnexFile{
+				Fs:                mockFs,
+				FilePath:          "/exports/Archive.enex",
+				config:            config.Config{FileTypes: []string{"pdf"}, Sidecar: tt.format},
+				NoteChannel:       make(chan Note, 10),
+				FailedNoteChannel: make(chan Note, 10),
+			}
+
+			done := make(chan bool)
+			go func() {
+				if err := enexFile.UploadFromNoteChannel("/tmp/output"); err != nil {
+					t.Errorf("UploadFromNoteChannel error: %v", err)
+				}
+				done <- true
+			}()
+
+			enexFile.NoteChannel <- note
+			close(enexFile.NoteChannel)
+			<-done
+
+			data, err := afero.ReadFile(mockFs, "/tmp/output/policy.pdf."+tt.format)
+			if err != nil {
+				t.Fatalf("sidecar not written: %v", err)
+			}
+
+			var metadata sidecar
+			if err := tt.unmarshal(data, &metadata); err != nil {
+				t.Fatalf("couldn't decode sidecar: %v", err)
+			}
+
+			if !reflect.DeepEqual(metadata, expected) {
+				t.Errorf("sidecar = %+v, expected %+v", metadata, expected)
+			}
+		})
+	}
+}
+
+// TestProcessingZipSidecars verifies that the files of zip attachments get a sidecar
+// and aren't extracted into the output folder as they are
+func TestProcessingZipSidecars(t *testing.T) {
+	var archive bytes.Buffer
+	zipWriter := zip.NewWriter(&archive)
+	f, err := zipWriter.Create("scan.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("test data"))
+	zipWriter.Close()
+
+	note := Note{
+		Title:   "Insurance",
+		Created: "20230403T123000Z",
+		Resources: []Resource{
+			{
+				Data:               base64.StdEncoding.EncodeToString(archive.Bytes()),
+				Mime:               "application/zip",
+				ResourceAttributes: ResourceAttributes{FileName: "documents.zip"},
+			},
+		},
+	}
+
+	mockFs := afero.NewMemMapFs()
+	enexFile := &EnexFile{
+		Fs:                mockFs,
+		FilePath:          "/exports/Archive.enex",
+		config:            config.Config{FileTypes: []string{"pdf", "zip"}, Sidecar: "json"},
+		NoteChannel:       make(chan Note, 10),
+		FailedNoteChannel: make(chan Note, 10),
+	}
+
+	done := make(chan bool)
+	go func() {
+		if err := enexFile.UploadFromNoteChannel("/tmp/output"); err != nil {
+			t.Errorf("UploadFromNoteChannel error: %v", err)
+		}
+		done <- true
+	}()
+
+	enexFile.NoteChannel <- note
+	close(enexFile.NoteChannel)
+	<-done
+
+	data, err := afero.ReadFile(mockFs, "/tmp/output/Insurance_documents_scan.pdf.json")
+	if err != nil {
+		t.Fatalf("sidecar not written: %v", err)
+	}
+	var metadata sidecar
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		t.Fatalf("couldn't decode sidecar: %v", err)
+	}
+	if metadata.ZipFile != "documents" || metadata.OriginalFileName != "scan.pdf" {
+		t.Errorf("unexpected sidecar: %+v", metadata)
+	}
+
+	if exists, _ := afero.Exists(mockFs, "/tmp/output/scan.pdf"); exists {
+		t.Error("zip contents were extracted into the output folder")
+	}
+}
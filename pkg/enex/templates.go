@@ -0,0 +1,205 @@
+package enex
+
+import (
+	"enex2paperless/pkg/templates"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+var errEmptyTemplate = errors.New("template rendered an empty string")
+
+// templateData returns the data available in the title and filename templates
+// for a document imported from a note
+func (e *EnexFile) templateData(note Note, fileName string, index, count int) templates.Data {
+	// dates are optional in templates
+	created, _ := time.Parse("20060102T150405Z", note.Created)
+	updated, _ := time.Parse("20060102T150405Z", note.Updated)
+
+	data := templates.NewData(templates.Note{
+		Title:     note.Title,
+		Created:   created,
+		Updated:   updated,
+		Tags:      note.Tags,
+		Author:    note.NoteAttributes.Author,
+		SourceURL: note.NoteAttributes.SourceURL,
+	}, fileName, index, count)
+
+	data.EnexFile = e.notebook(note)
+
+	return data
+}
+
+// documentCount returns the number of documents imported from a note.
+// Resources that are combined into one PDF count as one document.
+func (e *EnexFile) documentCount(note Note) int {
+	count := 0
+	merged := false
+	for _, resource := range note.Resources {
+		isWantedFileType, err := e.checkFileType(resource.Mime)
+		if err != nil || !isWantedFileType {
+			continue
+		}
+		if e.isMergedResource(resource) {
+			merged = true
+			continue
+		}
+		count++
+	}
+
+	if merged {
+		count++
+	}
+	return count
+}
+
+// documentTitle renders the title template, defaultTitle is used if no template is configured
+func (e *EnexFile) documentTitle(data templates.Data, defaultTitle string) string {
+	tmpl := e.parsedTemplates().title
+	if tmpl == nil {
+		return defaultTitle
+	}
+
+	title, err := executeTemplate(tmpl, data)
+	if err != nil {
+		slog.Warn("couldn't render title template, using default title", "title", defaultTitle, "error", err)
+		return defaultTitle
+	}
+	return title
+}
+
+// documentFileName renders the filename template, defaultName is used if no template
+// is configured. The extension of the original file is added unless the result ends with it.
+func (e *EnexFile) documentFileName(data templates.Data, defaultName string) string {
+	tmpl := e.parsedTemplates().filename
+	if tmpl == nil {
+		return defaultName
+	}
+
+	fileName, err := executeTemplate(tmpl, data)
+	if err != nil {
+		slog.Warn("couldn't render filename template, using default file name", "file", defaultName, "error", err)
+		return defaultName
+	}
+
+	fileName = sanitizeFilename(fileName)
+	if !strings.HasSuffix(strings.ToLower(fileName), strings.ToLower(data.Ext)) {
+		fileName += data.Ext
+	}
+	return fileName
+}
+
+// documentPath renders the path template to the path of a file in the output folder.
+// fileName is used if no template is configured and appended if the result ends with
+// a slash. Every path component is sanitized, empty ones and "." or ".." are dropped.
+// The consume layout takes precedence over the path template.
+func (e *EnexFile) documentPath(data templates.Data, fileName string) string {
+	if e.config.ConsumeLayout {
+		return e.consumePath(data, fileName)
+	}
+	tmpl := e.parsedTemplates().path
+	if tmpl == nil {
+		return fileName
+	}
+
+	result, err := executeTemplate(tmpl, data)
+	if err != nil {
+		slog.Warn("couldn't render path template, using file name", "file", fileName, "error", err)
+		return fileName
+	}
+
+	var components []string
+	for _, component := range strings.Split(result, "/") {
+		component = strings.TrimSpace(component)
+		if component == "" || component == "." || component == ".." {
+			continue
+		}
+		components = append(components, sanitizeFilename(component))
+	}
+
+	switch {
+	case len(components) == 0:
+		return fileName
+	case strings.HasSuffix(result, "/"):
+		components = append(components, fileName)
+	default:
+		last := components[len(components)-1]
+		if !strings.HasSuffix(strings.ToLower(last), strings.ToLower(data.Ext)) {
+			components[len(components)-1] = last + data.Ext
+		}
+	}
+
+	return filepath.Join(components...)
+}
+
+// consumePath returns the path of a file in a Paperless consumption directory.
+// With PAPERLESS_CONSUMER_SUBDIRS_AS_TAGS, Paperless assigns every directory as
+// tag, and with PAPERLESS_FILENAME_DATE_ORDER=YMD it reads the created date from
+// the file name.
+func (e *EnexFile) consumePath(data templates.Data, fileName string) string {
+	var components []string
+	for _, tag := range append(slices.Clone(data.Tags), e.additionalTags(data.EnexFile)...) {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "." || tag == ".." {
+			continue
+		}
+		tag = sanitizeFilename(tag)
+		if !slices.Contains(components, tag) {
+			components = append(components, tag)
+		}
+	}
+
+	if !data.Note.Created.IsZero() {
+		fileName = data.Note.Created.Format("2006-01-02") + " " + fileName
+	}
+
+	return filepath.Join(append(components, fileName)...)
+}
+
+// documentTemplates are the parsed title, filename and path templates, nil if not configured
+type documentTemplates struct {
+	title, filename, path *templates.Template
+}
+
+// parsedTemplates returns the templates of the configuration. They are parsed on
+// first use and shared by all documents of the file.
+func (e *EnexFile) parsedTemplates() *documentTemplates {
+	e.templatesOnce.Do(func() {
+		e.templates = &documentTemplates{
+			title:    parseTemplate("title", e.config.TitleTemplate),
+			filename: parseTemplate("filename", e.config.FilenameTemplate),
+			path:     parseTemplate("path", e.config.PathTemplate),
+		}
+	})
+	return e.templates
+}
+
+// parseTemplate parses a configured template. The configuration validation reports
+// invalid templates, if one gets here anyway it is logged and the default is used.
+func parseTemplate(name, text string) *templates.Template {
+	if text == "" {
+		return nil
+	}
+
+	tmpl, err := templates.Parse(name, text)
+	if err != nil {
+		slog.Warn("invalid template, using the default", "template", name, "error", err)
+		return nil
+	}
+	return tmpl
+}
+
+// executeTemplate renders a template. Empty results are treated as error.
+func executeTemplate(tmpl *templates.Template, data templates.Data) (string, error) {
+	result, err := tmpl.Execute(data)
+	if err != nil {
+		return "", err
+	}
+	if result == "" {
+		return "", errEmptyTemplate
+	}
+	return result, nil
+}
@@ -0,0 +1,209 @@
+package enex
+
+import (
+	"enex2paperless/internal/config"
+	"path/filepath"
+	"testing"
+)
+
+// TestDocumentTemplates tests rendering titles and file names from templates
+func TestDocumentTemplates(t *testing.T) {
+	note := Note{
+		Title:   "Insurance",
+		Created: "20230403T123000Z",
+		Tags:    []string{"Finance"},
+	}
+
+	tests := []struct {
+		name             string
+		titleTemplate    string
+		filenameTemplate string
+		expectedTitle    string
+		expectedFileName string
+	}{
+		{
+			name:             "no templates",
+			expectedTitle:    "Insurance",
+			expectedFileName: "scan.pdf",
+		},
+		{
+			name:             "index and count",
+			titleTemplate:    "{{.Note.Title}} ({{.Index}}/{{.Count}})",
+			filenameTemplate: "{{.Year}}-{{.Month}}-{{.Day}} {{.Title}} {{.Index}}",
+			expectedTitle:    "Insurance (2/3)",
+			expectedFileName: "2023-04-03 Insurance 2.pdf",
+		},
+		{
+			name:             "extension is kept",
+			filenameTemplate: "{{.EnexFile}}_{{.FileName}}",
+			expectedTitle:    "Insurance",
+			expectedFileName: "Archive_scan.pdf",
+		},
+		{
+			name:             "file names are sanitized",
+			filenameTemplate: "{{.FirstTag}}/{{.BaseName}}",
+			expectedTitle:    "Insurance",
+			expectedFileName: "Finance_scan.pdf",
+		},
+		{
+			name:             "empty result falls back to default",
+			titleTemplate:    "{{.ZipFile}}",
+			filenameTemplate: "{{.ZipFile}}",
+			expectedTitle:    "Insurance",
+			expectedFileName: "scan.pdf",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enexFile := &EnexFile{
+				FilePath: "/exports/Archive.enex",
+				config: config.Config{
+					TitleTemplate:    tt.titleTemplate,
+					FilenameTemplate: tt.filenameTemplate,
+				},
+			}
+
+			data := enexFile.templateData(note, "scan.pdf", 2, 3)
+
+			if title := enexFile.documentTitle(data, note.Title); title != tt.expectedTitle {
+				t.Errorf("documentTitle() = %q, expected %q", title, tt.expectedTitle)
+			}
+			if fileName := enexFile.documentFileName(data, "scan.pdf"); fileName != tt.expectedFileName {
+				t.Errorf("documentFileName() = %q, expected %q", fileName, tt.expectedFileName)
+			}
+		})
+	}
+}
+
+// TestDocumentCount verifies combined resources count as one document
+func TestDocumentCount(t *testing.T) {
+	note := Note{
+		Resources: []Resource{
+			{Mime: "application/pdf"},
+			{Mime: "image/png"},
+			{Mime: "image/jpeg"},
+			{Mime: "text/plain"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		expected int
+	}{
+		{"wanted file types", config.Config{FileTypes: []string{"pdf", "png", "jpeg"}}, 3},
+		{"combined images", config.Config{FileTypes: []string{"pdf", "png", "jpeg"}, CombineImages: true}, 2},
+		{"merged attachments", config.Config{FileTypes: []string{"any"}, MergeAttachments: true}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enexFile := &EnexFile{config: tt.cfg}
+			if count := enexFile.documentCount(note); count != tt.expected {
+				t.Errorf("documentCount() = %d, expected %d", count, tt.expected)
+			}
+		})
+	}
+}
+
+// TestDocumentPath tests rendering paths in the output folder from the path template
+func TestDocumentPath(t *testing.T) {
+	note := Note{
+		Title:   "Insurance: Car",
+		Created: "20230403T123000Z",
+	}
+
+	tests := []struct {
+		name         string
+		pathTemplate string
+		tags         []string
+		expected     string
+	}{
+		{"no template", "", nil, "scan.pdf"},
+		{"year and tag", "{{.Year}}/{{.FirstTag}}/{{.Title}}{{.Ext}}", []string{"Finance"}, "2023/Finance/Insurance_ Car.pdf"},
+		{"extension is added", "{{.Year}}/{{.BaseName}}", nil, "2023/scan.pdf"},
+		{"trailing slash appends file name", "{{.EnexFile}}/{{.Year}}/", nil, "Archive/2023/scan.pdf"},
+		{"empty components are dropped", "{{.Year}}/{{.FirstTag}}/{{.BaseName}}", nil, "2023/scan.pdf"},
+		{"parent directories are dropped", "../{{.Year}}/./{{.BaseName}}", nil, "2023/scan.pdf"},
+		{"components are sanitized", "{{.Year}}/{{.FirstTag}}/{{.BaseName}}", []string{`a\b:c`}, "2023/a_b_c/scan.pdf"},
+		{"empty result falls back to file name", "{{.ZipFile}}", nil, "scan.pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enexFile := &EnexFile{
+				FilePath: "/exports/Archive.enex",
+				config:   config.Config{PathTemplate: tt.pathTemplate},
+			}
+
+			note.Tags = tt.tags
+			data := enexFile.templateData(note, "scan.pdf", 1, 1)
+
+			if path := enexFile.documentPath(data, "scan.pdf"); path != filepath.FromSlash(tt.expected) {
+				t.Errorf("documentPath() = %q, expected %q", path, tt.expected)
+			}
+		})
+	}
+}
+
+// TestConsumePath tests the layout for the Paperless consumption directory
+func TestConsumePath(t *testing.T) {
+	tests := []struct {
+		name           string
+		created        string
+		tags           []string
+		additionalTags []string
+		expected       string
+	}{
+		{"tags become directories", "20230403T123000Z", []string{"Finance", "Car"}, nil, "Finance/Car/2023-04-03 scan.pdf"},
+		{"additional tags follow note tags", "20230403T123000Z", []string{"Finance"}, []string{"Evernote", "Finance"}, "Finance/Evernote/2023-04-03 scan.pdf"},
+		{"tags are sanitized", "20230403T123000Z", []string{"a/b", "..", " "}, nil, "a_b/2023-04-03 scan.pdf"},
+		{"no tags", "20230403T123000Z", nil, nil, "2023-04-03 scan.pdf"},
+		{"no created date", "", []string{"Finance"}, nil, "Finance/scan.pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enexFile := &EnexFile{
+				config: config.Config{
+					ConsumeLayout:  true,
+					PathTemplate:   "{{.Year}}/",
+					AdditionalTags: tt.additionalTags,
+				},
+			}
+
+			note := Note{Title: "Insurance", Created: tt.created, Tags: tt.tags}
+			data := enexFile.templateData(note, "scan.pdf", 1, 1)
+
+			if path := enexFile.documentPath(data, "scan.pdf"); path != filepath.FromSlash(tt.expected) {
+				t.Errorf("documentPath() = %q, expected %q", path, tt.expected)
+			}
+		})
+	}
+}
+
+// TestParsedTemplates verifies that the templates are parsed once per file and that
+// an invalid template falls back to the default
+func TestParsedTemplates(t *testing.T) {
+	enexFile := NewEnexFile("/export/Work.enex", config.Config{
+		TitleTemplate:    "{{.Note.Title}} {{.Year}}",
+		FilenameTemplate: "{{.Unknown}}",
+	})
+
+	parsed := enexFile.parsedTemplates()
+	if parsed.title == nil || parsed.filename != nil || parsed.path != nil {
+		t.Fatalf("unexpected templates: %+v", parsed)
+	}
+	if enexFile.parsedTemplates() != parsed {
+		t.Error("templates were parsed again")
+	}
+
+	data := enexFile.templateData(Note{Title: "Receipt", Created: "20230403T123000Z"}, "scan.pdf", 1, 1)
+	if title := enexFile.documentTitle(data, "default"); title != "Receipt 2023" {
+		t.Errorf("documentTitle() = %q, expected %q", title, "Receipt 2023")
+	}
+	if fileName := enexFile.documentFileName(data, "scan.pdf"); fileName != "scan.pdf" {
+		t.Errorf("documentFileName() = %q, expected %q", fileName, "scan.pdf")
+	}
+}
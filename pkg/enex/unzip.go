@@ -3,7 +3,6 @@ package enex
 import (
 	"archive/zip"
 	"bytes"
-	"enex2paperless/pkg/paperless"
 	"fmt"
 	"io"
 	"log/slog"
@@ -48,12 +47,14 @@ type ExtractedFile struct {
 
 // processZipFile handles a zip file, extracts its contents and processes each file
 // based on the current settings (either saving to disk or uploading to Paperless)
-func (e *EnexFile) processZipFile(decodedData []byte, resource Resource, note Note, outputFolder string, formattedCreatedDate string, allTags []string) error {
+func (e *EnexFile) processZipFile(decodedData []byte, resource Resource, note Note, outputFolder string, formattedCreatedDate string, allTags []string, index, count int) error {
 	slog.Info("processing zip file", "file", resource.ResourceAttributes.FileName)
 
-	// Create a temporary directory for extraction if output folder is not set
+	// Create a temporary directory for extraction if output folder is not set.
+	// With a path template, the consume layout, the document importer export or
+	// sidecars, only the saved copies end up in the output folder.
 	extractDir := outputFolder
-	if extractDir == "" {
+	if extractDir == "" || e.config.PathTemplate != "" || e.config.ConsumeLayout || e.config.DocumentImporter || e.config.Sidecar != "" {
 		extractDir = os.TempDir()
 	}
 
@@ -97,16 +98,26 @@ func (e *EnexFile) processZipFile(decodedData []byte, resource Resource, note No
 			continue
 		}
 
+		zipFileNameWithoutExt := strings.TrimSuffix(file.ZipFileName, filepath.Ext(file.ZipFileName))
+		fileNameWithoutExt := strings.TrimSuffix(file.Name, filepath.Ext(file.Name))
+
+		tmplData := e.templateData(note, file.Name, index, count)
+		tmplData.ZipFile = zipFileNameWithoutExt
+
+		combinedTitle := fmt.Sprintf("%s | %s | %s",
+			note.Title,
+			zipFileNameWithoutExt,
+			fileNameWithoutExt)
+
 		// Handle output to disk if specified
 		if outputFolder != "" {
-			zipFileNameWithoutExt := strings.TrimSuffix(file.ZipFileName, filepath.Ext(file.ZipFileName))
-			fileNameWithoutExt := strings.TrimSuffix(file.Name, filepath.Ext(file.Name))
 			outputName := fmt.Sprintf("%s_%s_%s%s",
 				note.Title,
 				zipFileNameWithoutExt,
 				fileNameWithoutExt,
 				filepath.Ext(file.Name))
-			outputName = sanitizeFilename(outputName)
+			outputName = e.documentFileName(tmplData, sanitizeFilename(outputName))
+			outputName = e.documentPath(tmplData, outputName)
 
 			extractedResource := Resource{
 				Mime: file.MimeType,
@@ -115,7 +126,7 @@ func (e *EnexFile) processZipFile(decodedData []byte, resource Resource, note No
 				},
 			}
 
-			err = e.SaveResourceToDisk(file.Data, extractedResource, outputFolder)
+			err = e.saveDocument(note, tmplData, e.documentTitle(tmplData, combinedTitle), file.Data, extractedResource, outputFolder)
 			if err != nil {
 				slog.Error("failed to save extracted file to disk", "error", err)
 			} else {
@@ -123,24 +134,14 @@ func (e *EnexFile) processZipFile(decodedData []byte, resource Resource, note No
 			}
 		} else {
 			// Upload to Paperless
-			zipFileNameWithoutExt := strings.TrimSuffix(file.ZipFileName, filepath.Ext(file.ZipFileName))
-			fileNameWithoutExt := strings.TrimSuffix(file.Name, filepath.Ext(file.Name))
-			combinedTitle := fmt.Sprintf("%s | %s | %s",
-				note.Title,
-				zipFileNameWithoutExt,
-				fileNameWithoutExt)
-
-			paperlessFile := paperless.NewPaperlessFile(
-				combinedTitle,
-				file.Name,
-				file.MimeType,
-				formattedCreatedDate,
-				file.Data,
-				allTags,
-				e.config,
-			)
-
-			err = paperlessFile.Upload()
+			// rules and custom fields refer to the extracted file, not the zip file
+			extractedResource := resource
+			extractedResource.Mime = file.MimeType
+			extractedResource.ResourceAttributes.FileName = file.Name
+
+			paperlessFile := e.newPaperlessFile(note, extractedResource, e.documentTitle(tmplData, combinedTitle), file.Data, formattedCreatedDate, allTags)
+			paperlessFile.FileName = e.documentFileName(tmplData, file.Name)
+			err = e.upload(paperlessFile, e.uploadKey(note, fmt.Sprintf("resource %d/%s", index, file.Name)))
 			if err != nil {
 				slog.Error("failed to upload extracted file", "error", err)
 			} else {
@@ -0,0 +1,357 @@
+// Package enml parses Evernote's ENML note content into a tree and renders it
+// as plain text, Markdown or self-contained HTML.
+package enml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// NodeType identifies the kind of a Node
+type NodeType int
+
+const (
+	DocumentNode NodeType = iota
+	TextNode
+	ParagraphNode
+	HeadingNode
+	LineBreakNode
+	ListNode
+	ListItemNode
+	TodoNode
+	TableNode
+	TableRowNode
+	TableCellNode
+	LinkNode
+	MediaNode
+	CryptNode
+	BoldNode
+	ItalicNode
+	UnderlineNode
+	StrikethroughNode
+	CodeNode
+	PreformattedNode
+	QuoteNode
+	RuleNode
+	ImageNode
+)
+
+// Node is an element of the parsed ENML tree
+type Node struct {
+	Type NodeType
+
+	// Text is the content of a TextNode and the encrypted content of a CryptNode
+	Text string
+
+	// Level is the level of a HeadingNode (1-6)
+	Level int
+	// Ordered is set for numbered lists
+	Ordered bool
+	// Checked is set for checked en-todo checkboxes
+	Checked bool
+	// Header is set for table header cells
+	Header bool
+
+	// Attrs holds the relevant attributes of the source element,
+	// e.g. href of links, hash and type of en-media, hint of en-crypt
+	Attrs map[string]string
+
+	Children []*Node
+}
+
+// Attr returns an attribute of the node or an empty string
+func (n *Node) Attr(name string) string {
+	if n.Attrs == nil {
+		return ""
+	}
+	return n.Attrs[name]
+}
+
+// Walk calls fn for the node and all of its descendants in document order.
+// Children of a node are skipped if fn returns false.
+func (n *Node) Walk(fn func(*Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, child := range n.Children {
+		child.Walk(fn)
+	}
+}
+
+// Media returns all en-media references in document order
+func (n *Node) Media() []*Node {
+	var media []*Node
+	n.Walk(func(node *Node) bool {
+		if node.Type == MediaNode {
+			media = append(media, node)
+		}
+		return true
+	})
+	return media
+}
+
+// isBlock reports whether the node starts a new line when rendered
+func (n *Node) isBlock() bool {
+	switch n.Type {
+	case DocumentNode, ParagraphNode, HeadingNode, ListNode, ListItemNode,
+		TableNode, TableRowNode, TableCellNode, PreformattedNode, QuoteNode, RuleNode:
+		return true
+	}
+	return false
+}
+
+// elementTypes maps ENML and XHTML elements to node types. Elements not
+// listed here are transparent: their children are added to the parent.
+var elementTypes = map[string]NodeType{
+	"div":        ParagraphNode,
+	"p":          ParagraphNode,
+	"center":     ParagraphNode,
+	"address":    ParagraphNode,
+	"dl":         ParagraphNode,
+	"dt":         ParagraphNode,
+	"dd":         ParagraphNode,
+	"h1":         HeadingNode,
+	"h2":         HeadingNode,
+	"h3":         HeadingNode,
+	"h4":         HeadingNode,
+	"h5":         HeadingNode,
+	"h6":         HeadingNode,
+	"br":         LineBreakNode,
+	"ul":         ListNode,
+	"ol":         ListNode,
+	"li":         ListItemNode,
+	"en-todo":    TodoNode,
+	"table":      TableNode,
+	"tr":         TableRowNode,
+	"td":         TableCellNode,
+	"th":         TableCellNode,
+	"a":          LinkNode,
+	"en-media":   MediaNode,
+	"en-crypt":   CryptNode,
+	"b":          BoldNode,
+	"strong":     BoldNode,
+	"i":          ItalicNode,
+	"em":         ItalicNode,
+	"cite":       ItalicNode,
+	"u":          UnderlineNode,
+	"ins":        UnderlineNode,
+	"s":          StrikethroughNode,
+	"strike":     StrikethroughNode,
+	"del":        StrikethroughNode,
+	"code":       CodeNode,
+	"tt":         CodeNode,
+	"kbd":        CodeNode,
+	"samp":       CodeNode,
+	"pre":        PreformattedNode,
+	"blockquote": QuoteNode,
+	"hr":         RuleNode,
+	"img":        ImageNode,
+}
+
+// skippedElements are dropped together with their content
+var skippedElements = map[string]bool{
+	"head":   true,
+	"title":  true,
+	"style":  true,
+	"script": true,
+	"col":    true,
+}
+
+// keptAttrs lists the attributes stored in Node.Attrs
+var keptAttrs = map[string]bool{
+	"href":   true,
+	"hash":   true,
+	"type":   true,
+	"hint":   true,
+	"cipher": true,
+	"length": true,
+	"src":    true,
+	"alt":    true,
+	"width":  true,
+	"height": true,
+	"start":  true,
+}
+
+// Parse parses ENML note content into a tree with a DocumentNode at its root.
+// The parser is lenient: malformed markup is parsed as far as possible and
+// content that isn't wrapped in en-note is treated as part of the document.
+func Parse(content string) (*Node, error) {
+	root := &Node{Type: DocumentNode}
+
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	// stack holds the node each open element appends to. Transparent elements
+	// push their parent again, so every end element pops exactly once.
+	stack := []*Node{root}
+	skipDepth := 0
+
+	for {
+		t, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return root, fmt.Errorf("error parsing ENML: %w", err)
+		}
+
+		parent := stack[len(stack)-1]
+
+		switch se := t.(type) {
+		case xml.StartElement:
+			if skipDepth > 0 {
+				skipDepth++
+				continue
+			}
+
+			name := strings.ToLower(se.Name.Local)
+			style := attrValue(se.Attr, "style")
+			if skippedElements[name] || isHidden(style) {
+				skipDepth = 1
+				continue
+			}
+
+			node, content := newElementNode(name, se.Attr, style)
+			if node == nil {
+				// transparent element, but inline styles may still apply
+				node, content = styleNodes(style)
+			}
+			if node == nil {
+				stack = append(stack, parent)
+				continue
+			}
+
+			parent.Children = append(parent.Children, node)
+			stack = append(stack, content)
+
+		case xml.EndElement:
+			if skipDepth > 0 {
+				skipDepth--
+				continue
+			}
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+
+		case xml.CharData:
+			if skipDepth > 0 {
+				continue
+			}
+			if parent.Type == CryptNode {
+				parent.Text += strings.TrimSpace(string(se))
+				continue
+			}
+			text := string(se)
+			// merge adjacent text, e.g. around entities
+			if last := len(parent.Children) - 1; last >= 0 && parent.Children[last].Type == TextNode {
+				parent.Children[last].Text += text
+				continue
+			}
+			parent.Children = append(parent.Children, &Node{Type: TextNode, Text: text})
+		}
+	}
+
+	return root, nil
+}
+
+// newElementNode creates the node for a known element and returns it together
+// with the node its content is added to. It returns nil for transparent elements.
+func newElementNode(name string, attrs []xml.Attr, style string) (*Node, *Node) {
+	nodeType, ok := elementTypes[name]
+	if !ok {
+		return nil, nil
+	}
+
+	node := &Node{Type: nodeType}
+	for _, attr := range attrs {
+		key := strings.ToLower(attr.Name.Local)
+		if keptAttrs[key] {
+			if node.Attrs == nil {
+				node.Attrs = make(map[string]string)
+			}
+			node.Attrs[key] = attr.Value
+		}
+	}
+
+	switch nodeType {
+	case HeadingNode:
+		node.Level = int(name[1] - '0')
+	case ListNode:
+		node.Ordered = name == "ol"
+	case TodoNode:
+		node.Checked = strings.EqualFold(attrValue(attrs, "checked"), "true")
+	case TableCellNode:
+		node.Header = name == "th"
+	case ParagraphNode:
+		// Evernote marks code blocks with a custom style property
+		if strings.Contains(strings.ReplaceAll(style, " ", ""), "--en-codeblock:true") {
+			node.Type = PreformattedNode
+		}
+	}
+
+	// inline styles of containers wrap their content, e.g. <div style="font-weight:bold">
+	switch node.Type {
+	case ParagraphNode, HeadingNode, ListItemNode, TableCellNode, QuoteNode, LinkNode:
+		if styled, content := styleNodes(style); styled != nil {
+			node.Children = append(node.Children, styled)
+			return node, content
+		}
+	}
+
+	return node, node
+}
+
+// styleNodes returns a chain of formatting nodes for the bold, italic,
+// underline and strikethrough properties of an inline style, together with
+// the innermost node of the chain. It returns nil if the style doesn't format text.
+func styleNodes(style string) (*Node, *Node) {
+	style = strings.ToLower(strings.ReplaceAll(style, " ", ""))
+	if style == "" {
+		return nil, nil
+	}
+
+	var types []NodeType
+	if strings.Contains(style, "font-weight:bold") || strings.Contains(style, "font-weight:700") {
+		types = append(types, BoldNode)
+	}
+	if strings.Contains(style, "font-style:italic") {
+		types = append(types, ItalicNode)
+	}
+	if strings.Contains(style, "text-decoration:underline") {
+		types = append(types, UnderlineNode)
+	}
+	if strings.Contains(style, "text-decoration:line-through") {
+		types = append(types, StrikethroughNode)
+	}
+
+	var root, current *Node
+	for _, t := range types {
+		node := &Node{Type: t}
+		if root == nil {
+			root = node
+		} else {
+			current.Children = append(current.Children, node)
+		}
+		current = node
+	}
+	return root, current
+}
+
+// isHidden reports whether an inline style hides the element
+func isHidden(style string) bool {
+	style = strings.ToLower(strings.ReplaceAll(style, " ", ""))
+	return strings.Contains(style, "display:none")
+}
+
+func attrValue(attrs []xml.Attr, name string) string {
+	for _, attr := range attrs {
+		if strings.EqualFold(attr.Name.Local, name) {
+			return attr.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,155 @@
+package enml
+
+import (
+	"testing"
+)
+
+const testNote = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<!DOCTYPE en-note SYSTEM "http://xml.evernote.com/pub/enml2.dtd">
+<en-note><div style="display:none;--en-chs:&quot;abc&quot;">hidden</div>
+<h1>Receipt</h1>
+<div>paid on 3.4., <b>ref 12345</b> &amp; <span style="font-style: italic;">more</span></div>
+<div><br/></div>
+<div>line one<br/>line two</div>
+<ul><li>first</li><li><div>second</div></li><ul><li>nested</li></ul></ul>
+<ol><li>one</li><li>two</li></ol>
+<div><en-todo checked="true"/>done</div>
+<div><en-todo/>open</div>
+<table><tbody><tr><th>Item</th><th>Price</th></tr><tr><td>Coffee</td><td>3.50</td></tr></tbody></table>
+<div>see <a href="https://example.com">the site</a></div>
+<en-media hash="abc123" type="image/png"/>
+<en-crypt hint="pin" cipher="AES">c2VjcmV0</en-crypt>
+<div style="--en-codeblock:true"><div>func main() {</div><div>  x := 1</div><div>}</div></div>
+</en-note>`
+
+// testOptions resolves the en-media of testNote
+var testOptions = Options{
+	Title: "Receipt",
+	Media: func(hash string) (Media, bool) {
+		if hash != "abc123" {
+			return Media{}, false
+		}
+		return Media{FileName: "scan 1.png", Data: []byte{1, 2, 3}}, true
+	},
+}
+
+// TestParse tests the tree built from ENML content
+func TestParse(t *testing.T) {
+	doc, err := Parse(testNote)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	if doc.Type != DocumentNode {
+		t.Fatalf("root type = %v, expected DocumentNode", doc.Type)
+	}
+
+	counts := make(map[NodeType]int)
+	var todos []bool
+	var crypt *Node
+	doc.Walk(func(n *Node) bool {
+		counts[n.Type]++
+		switch n.Type {
+		case TodoNode:
+			todos = append(todos, n.Checked)
+		case CryptNode:
+			crypt = n
+		case TextNode:
+			if n.Text == "hidden" {
+				t.Error("hidden content should be skipped")
+			}
+		}
+		return true
+	})
+
+	expected := map[NodeType]int{
+		HeadingNode:      1,
+		ListNode:         3,
+		ListItemNode:     5,
+		TodoNode:         2,
+		TableNode:        1,
+		TableRowNode:     2,
+		TableCellNode:    4,
+		LinkNode:         1,
+		MediaNode:        1,
+		CryptNode:        1,
+		BoldNode:         1,
+		ItalicNode:       1,
+		PreformattedNode: 1,
+	}
+	for nodeType, count := range expected {
+		if counts[nodeType] != count {
+			t.Errorf("found %d nodes of type %v, expected %d", counts[nodeType], nodeType, count)
+		}
+	}
+
+	if len(todos) != 2 || !todos[0] || todos[1] {
+		t.Errorf("todo states = %v, expected [true false]", todos)
+	}
+
+	if crypt == nil || crypt.Text != "c2VjcmV0" || crypt.Attr("hint") != "pin" || crypt.Attr("cipher") != "AES" {
+		t.Errorf("unexpected en-crypt node: %+v", crypt)
+	}
+
+	media := doc.Media()
+	if len(media) != 1 || media[0].Attr("hash") != "abc123" || media[0].Attr("type") != "image/png" {
+		t.Errorf("unexpected media: %+v", media)
+	}
+}
+
+// TestParseMediaOrder verifies en-media references are returned in document order
+func TestParseMediaOrder(t *testing.T) {
+	doc, err := Parse(`<en-note><div><en-media hash="b"/></div><en-media hash="a"/><div><div><en-media hash="c"/></div></div></en-note>`)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	var hashes []string
+	for _, m := range doc.Media() {
+		hashes = append(hashes, m.Attr("hash"))
+	}
+
+	if len(hashes) != 3 || hashes[0] != "b" || hashes[1] != "a" || hashes[2] != "c" {
+		t.Errorf("media order = %v, expected [b a c]", hashes)
+	}
+}
+
+// TestParseLenient verifies malformed and plain content is still parsed
+func TestParseLenient(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "plain text without en-note",
+			content:  "Test note with a PDF attachment.",
+			expected: "Test note with a PDF attachment.",
+		},
+		{
+			name:     "unclosed elements",
+			content:  "<en-note><div>first<div>second</en-note>",
+			expected: "first\nsecond",
+		},
+		{
+			name:     "HTML entities",
+			content:  "<en-note><div>caf&eacute;&nbsp;&mdash; bar</div></en-note>",
+			expected: "café — bar",
+		},
+		{
+			name:     "empty",
+			content:  "",
+			expected: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, _ := Parse(tc.content)
+			result := Text(doc, Options{})
+			if result != tc.expected {
+				t.Errorf("Text() = %q, expected %q", result, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package enml
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"mime"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// htmlStyle is embedded in rendered documents
+const htmlStyle = `body { font-family: sans-serif; max-width: 50em; margin: 2em auto; padding: 0 1em; line-height: 1.4; }
+img { max-width: 100%; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 0.3em 0.6em; vertical-align: top; }
+pre { background: #f5f5f5; padding: 0.6em; overflow-x: auto; }
+blockquote { border-left: 3px solid #ccc; margin-left: 0; padding-left: 1em; color: #555; }
+.en-crypt { color: #888; font-style: italic; }`
+
+// HTML renders the tree as a self-contained HTML document. Images referenced
+// by en-media are embedded as data URIs, other resources become download links.
+func HTML(doc *Node, opts Options) string {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n", html.EscapeString(opts.Title))
+	fmt.Fprintf(&b, "<style>\n%s\n</style>\n</head>\n<body>\n", htmlStyle)
+	if opts.Title != "" {
+		fmt.Fprintf(&b, "<h1>%s</h1>\n", html.EscapeString(opts.Title))
+	}
+	writeHTMLChildren(&b, doc, opts)
+	b.WriteString("\n</body>\n</html>\n")
+	return b.String()
+}
+
+// htmlTags maps node types to the HTML element they are rendered as
+var htmlTags = map[NodeType]string{
+	ParagraphNode:     "div",
+	ListItemNode:      "li",
+	TableNode:         "table",
+	TableRowNode:      "tr",
+	BoldNode:          "strong",
+	ItalicNode:        "em",
+	UnderlineNode:     "u",
+	StrikethroughNode: "s",
+	CodeNode:          "code",
+	QuoteNode:         "blockquote",
+}
+
+func writeHTMLChildren(b *strings.Builder, n *Node, opts Options) {
+	for _, child := range n.Children {
+		writeHTML(b, child, opts)
+	}
+}
+
+func writeHTML(b *strings.Builder, n *Node, opts Options) {
+	switch n.Type {
+	case TextNode:
+		b.WriteString(html.EscapeString(n.Text))
+
+	case LineBreakNode:
+		b.WriteString("<br>")
+
+	case RuleNode:
+		b.WriteString("<hr>\n")
+
+	case HeadingNode:
+		level := min(max(n.Level, 1), 6)
+		fmt.Fprintf(b, "<h%d>", level)
+		writeHTMLChildren(b, n, opts)
+		fmt.Fprintf(b, "</h%d>\n", level)
+
+	case ListNode:
+		tag := "ul"
+		if n.Ordered {
+			tag = "ol"
+		}
+		b.WriteString("<" + tag)
+		if start := n.Attr("start"); start != "" {
+			fmt.Fprintf(b, ` start="%s"`, html.EscapeString(start))
+		}
+		b.WriteString(">\n")
+		writeHTMLChildren(b, n, opts)
+		b.WriteString("</" + tag + ">\n")
+
+	case PreformattedNode:
+		fmt.Fprintf(b, "<pre>%s</pre>\n", html.EscapeString(PreText(n)))
+
+	case TableCellNode:
+		tag := "td"
+		if n.Header {
+			tag = "th"
+		}
+		b.WriteString("<" + tag + ">")
+		writeHTMLChildren(b, n, opts)
+		b.WriteString("</" + tag + ">")
+
+	case TodoNode:
+		if n.Checked {
+			b.WriteString(`<input type="checkbox" disabled checked> `)
+		} else {
+			b.WriteString(`<input type="checkbox" disabled> `)
+		}
+
+	case LinkNode:
+		// other schemes like javascript: and evernote: are rendered as text
+		href := n.Attr("href")
+		if !isSafeLink(href) {
+			writeHTMLChildren(b, n, opts)
+			return
+		}
+		fmt.Fprintf(b, `<a href="%s">`, html.EscapeString(href))
+		writeHTMLChildren(b, n, opts)
+		b.WriteString("</a>")
+
+	case MediaNode:
+		m, ok := opts.media(n)
+		if !ok {
+			return
+		}
+		mediaType := dataMediaType(m.MimeType)
+		dataURI := fmt.Sprintf("data:%s;base64,%s", mediaType, base64.StdEncoding.EncodeToString(m.Data))
+		if strings.HasPrefix(mediaType, "image/") {
+			fmt.Fprintf(b, `<img src="%s" alt="%s">`, html.EscapeString(dataURI), html.EscapeString(m.FileName))
+			return
+		}
+		name := m.FileName
+		if name == "" {
+			name = "attachment"
+		}
+		fmt.Fprintf(b, `<a href="%s" download="%s">%s</a>`, html.EscapeString(dataURI), html.EscapeString(name), html.EscapeString(name))
+
+	case CryptNode:
+		text := "[encrypted content]"
+		if hint := n.Attr("hint"); hint != "" {
+			text = "[encrypted content: " + hint + "]"
+		}
+		fmt.Fprintf(b, `<span class="en-crypt">%s</span>`, html.EscapeString(text))
+
+	case ImageNode:
+		// remote images would be loaded whenever the document is opened, only
+		// embedded images are kept
+		src := n.Attr("src")
+		if !isImageDataURI(src) {
+			return
+		}
+		fmt.Fprintf(b, `<img src="%s" alt="%s">`, html.EscapeString(src), html.EscapeString(n.Attr("alt")))
+
+	default:
+		tag, ok := htmlTags[n.Type]
+		if !ok {
+			writeHTMLChildren(b, n, opts)
+			return
+		}
+		b.WriteString("<" + tag + ">")
+		writeHTMLChildren(b, n, opts)
+		b.WriteString("</" + tag + ">")
+		if n.isBlock() {
+			b.WriteString("\n")
+		}
+	}
+}
+
+// safeLinkSchemes are the URL schemes of links that are kept
+var safeLinkSchemes = []string{"http", "https", "mailto"}
+
+// isSafeLink reports whether href is an absolute http, https or mailto URL
+func isSafeLink(href string) bool {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return false
+	}
+	return slices.Contains(safeLinkSchemes, strings.ToLower(u.Scheme))
+}
+
+// dataMediaType returns the media type of a resource without its parameters for
+// a data URI, application/octet-stream if it's invalid
+func dataMediaType(mimeType string) string {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil || !strings.Contains(mediaType, "/") {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
+
+// isImageDataURI reports whether src is an image embedded as data URI
+func isImageDataURI(src string) bool {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(src), "data:")
+	if !ok {
+		return false
+	}
+	mediaType, _, ok := strings.Cut(rest, ",")
+	if !ok {
+		return false
+	}
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	return strings.HasPrefix(dataMediaType(mediaType), "image/")
+}
@@ -0,0 +1,88 @@
+package enml
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestHTML tests the self-contained HTML renderer
+func TestHTML(t *testing.T) {
+	doc, err := Parse(testNote)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	result := HTML(doc, testOptions)
+
+	expectedParts := []string{
+		"<title>Receipt</title>",
+		"<div>paid on 3.4., <strong>ref 12345</strong> &amp; <em>more</em></div>",
+		"<li><div>second</div>",
+		`<input type="checkbox" disabled checked> done`,
+		"<tr><th>Item</th><th>Price</th></tr>",
+		`<a href="https://example.com">the site</a>`,
+		`<img src="data:image/png;base64,AQID" alt="scan 1.png">`,
+		`<span class="en-crypt">[encrypted content: pin]</span>`,
+		"<pre>func main() {\n  x := 1\n}</pre>",
+	}
+	for _, part := range expectedParts {
+		if !strings.Contains(result, part) {
+			t.Errorf("HTML() is missing %q", part)
+		}
+	}
+
+	if strings.Contains(result, "hidden") {
+		t.Error("HTML() contains hidden content")
+	}
+}
+
+// TestHTMLEscaping verifies text and attributes are escaped
+func TestHTMLEscaping(t *testing.T) {
+	doc, _ := Parse(`<en-note><div>&lt;script&gt;</div><a href="https://example.com/?a=1&amp;b=&quot;2&quot;">x</a></en-note>`)
+
+	result := HTML(doc, Options{Title: "<b>"})
+	for _, part := range []string{"&lt;script&gt;", `href="https://example.com/?a=1&amp;b=&#34;2&#34;"`, "<title>&lt;b&gt;</title>"} {
+		if !strings.Contains(result, part) {
+			t.Errorf("HTML() is missing %q:\n%s", part, result)
+		}
+	}
+}
+
+// TestHTMLSanitizing verifies that unsafe links, remote images and invalid media
+// types aren't rendered
+func TestHTMLSanitizing(t *testing.T) {
+	doc, err := Parse(`<en-note>
+<a href="javascript:alert(1)">script</a>
+<a href="evernote:///view/1/s1/abc/abc/">other note</a>
+<a href="mailto:me@example.com">mail</a>
+<img src="https://tracker.example.com/pixel.gif" alt="pixel"/>
+<img src="data:image/gif;base64,R0lGOD" alt="inline"/>
+<img src="data:text/html;base64,PHNjcmlwdD4=" alt="html"/>
+<en-media hash="aaa" type="image/png"/>
+</en-note>`)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	opts := Options{Media: func(hash string) (Media, bool) {
+		return Media{MimeType: `image/png" onerror="alert(1)`, Data: []byte{1, 2, 3}, FileName: "scan.png"}, true
+	}}
+	result := HTML(doc, opts)
+
+	for _, part := range []string{
+		"script",
+		"other note",
+		`<a href="mailto:me@example.com">mail</a>`,
+		`<img src="data:image/gif;base64,R0lGOD" alt="inline">`,
+		`<a href="data:application/octet-stream;base64,AQID" download="scan.png">scan.png</a>`,
+	} {
+		if !strings.Contains(result, part) {
+			t.Errorf("HTML() is missing %q:\n%s", part, result)
+		}
+	}
+	for _, part := range []string{"javascript:", "evernote:", "tracker.example.com", "text/html", "onerror"} {
+		if strings.Contains(result, part) {
+			t.Errorf("HTML() contains %q:\n%s", part, result)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package enml
+
+import "strings"
+
+// Markdown renders the tree as GitHub flavored Markdown. Checkboxes become
+// task list items and en-media references link to the file name of the resource.
+func Markdown(doc *Node, opts Options) string {
+	r := newRenderer(opts, true)
+	r.renderBlock(doc)
+	return r.w.String()
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"_", `\_`,
+	"`", "\\`",
+	"[", `\[`,
+	"]", `\]`,
+	"<", `\<`,
+)
+
+// escapeMarkdown escapes characters with a meaning in Markdown
+func escapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
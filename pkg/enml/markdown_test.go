@@ -0,0 +1,60 @@
+package enml
+
+import (
+	"testing"
+)
+
+// TestMarkdown tests the Markdown renderer
+func TestMarkdown(t *testing.T) {
+	doc, err := Parse(testNote)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	expected := "# Receipt\n" +
+		"\n" +
+		"paid on 3.4., **ref 12345** & *more*\n" +
+		"\n" +
+		"line one\\\n" +
+		"line two\n" +
+		"\n" +
+		"- first\n" +
+		"- second\n" +
+		"  - nested\n" +
+		"\n" +
+		"1. one\n" +
+		"2. two\n" +
+		"\n" +
+		"- [x] done\n" +
+		"- [ ] open\n" +
+		"\n" +
+		"| Item | Price |\n" +
+		"| --- | --- |\n" +
+		"| Coffee | 3.50 |\n" +
+		"\n" +
+		"see [the site](https://example.com)\n" +
+		"\n" +
+		"![scan 1.png](scan%201.png) [encrypted content: pin]\n" +
+		"\n" +
+		"```\n" +
+		"func main() {\n" +
+		"  x := 1\n" +
+		"}\n" +
+		"```"
+
+	result := Markdown(doc, testOptions)
+	if result != expected {
+		t.Errorf("Markdown() =\n%s\n\nexpected:\n%s", result, expected)
+	}
+}
+
+// TestMarkdownEscaping verifies Markdown syntax in text is escaped
+func TestMarkdownEscaping(t *testing.T) {
+	doc, _ := Parse(`<en-note><div>2*3 = [x] <b> bold </b>_end_</div><table><tr><td>a|b</td></tr></table></en-note>`)
+
+	expected := "2\\*3 = \\[x\\] **bold** \\_end\\_\n\n| a\\|b |\n| --- |"
+	result := Markdown(doc, Options{})
+	if result != expected {
+		t.Errorf("Markdown() = %q, expected %q", result, expected)
+	}
+}
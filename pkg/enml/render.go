@@ -0,0 +1,550 @@
+package enml
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Options configure the renderers
+type Options struct {
+	// Title is used as document title by the HTML renderer
+	Title string
+
+	// Media resolves en-media hashes to the resources of the note.
+	// Media that can't be resolved is left out.
+	Media func(hash string) (Media, bool)
+}
+
+// Media is a resource referenced by an en-media element
+type Media struct {
+	FileName string
+	MimeType string
+	Data     []byte
+}
+
+// media resolves the resource of a MediaNode
+func (o Options) media(n *Node) (Media, bool) {
+	if o.Media == nil {
+		return Media{}, false
+	}
+	m, ok := o.Media(n.Attr("hash"))
+	if ok && m.MimeType == "" {
+		m.MimeType = n.Attr("type")
+	}
+	return m, ok
+}
+
+// softBreak ends the current line unless it is empty. It is used around
+// block nodes that end up in inline content.
+const softBreak = '\x00'
+
+var whitespace = regexp.MustCompile(`[\s\x{00a0}]+`)
+
+// lineWriter writes lines with nested prefixes for list items and quotes
+type lineWriter struct {
+	b        strings.Builder
+	prefixes []*linePrefix
+	blank    bool
+	started  bool
+	// task is set if the last line is a Markdown task list item
+	task bool
+}
+
+// linePrefix is written in front of each line. first is used for the
+// first line only, e.g. the marker of a list item.
+type linePrefix struct {
+	first, rest string
+	used        bool
+}
+
+func (w *lineWriter) push(first, rest string) {
+	w.prefixes = append(w.prefixes, &linePrefix{first: first, rest: rest})
+}
+
+func (w *lineWriter) pop() {
+	w.prefixes = w.prefixes[:len(w.prefixes)-1]
+}
+
+// separate requests a blank line before the next line
+func (w *lineWriter) separate() {
+	w.blank = true
+}
+
+func (w *lineWriter) line(s string) {
+	if w.blank && w.started {
+		var continuation strings.Builder
+		for _, p := range w.prefixes {
+			if p.used {
+				continuation.WriteString(p.rest)
+			}
+		}
+		w.b.WriteString(strings.TrimRight(continuation.String(), " "))
+		w.b.WriteString("\n")
+	}
+	w.blank = false
+
+	var line strings.Builder
+	for _, p := range w.prefixes {
+		if p.used {
+			line.WriteString(p.rest)
+		} else {
+			line.WriteString(p.first)
+			p.used = true
+		}
+	}
+	line.WriteString(s)
+
+	w.b.WriteString(strings.TrimRight(line.String(), " \t"))
+	w.b.WriteString("\n")
+	w.started = true
+	w.task = false
+}
+
+func (w *lineWriter) String() string {
+	return strings.TrimRight(w.b.String(), "\n")
+}
+
+// renderer renders the tree line by line as plain text or Markdown
+type renderer struct {
+	w         *lineWriter
+	opts      Options
+	markdown  bool
+	listDepth int
+}
+
+func newRenderer(opts Options, markdown bool) *renderer {
+	return &renderer{w: &lineWriter{}, opts: opts, markdown: markdown}
+}
+
+// renderChildren renders the children of a block node. Runs of inline
+// children are collected and written as lines.
+func (r *renderer) renderChildren(n *Node) {
+	var inline strings.Builder
+	for _, child := range n.Children {
+		if child.isBlock() {
+			r.flush(inline.String())
+			inline.Reset()
+			r.renderBlock(child)
+			continue
+		}
+		inline.WriteString(r.inline(child))
+	}
+	r.flush(inline.String())
+}
+
+// flush writes rendered inline content as lines
+func (r *renderer) flush(s string) {
+	if strings.Trim(s, " \x00") == "" {
+		return
+	}
+
+	lines := splitLines(s)
+	for i, line := range lines {
+		line = strings.TrimSpace(whitespace.ReplaceAllString(line, " "))
+		task := false
+
+		if r.markdown {
+			// empty lines separate paragraphs
+			if line == "" {
+				r.w.separate()
+				continue
+			}
+			// checkboxes at the start of a line become task list items,
+			// consecutive ones form a single list
+			if r.listDepth == 0 && (strings.HasPrefix(line, "[ ] ") || strings.HasPrefix(line, "[x] ")) {
+				line = "- " + line
+				task = true
+				if r.w.task {
+					r.w.blank = false
+				}
+			}
+			// keep line breaks within the paragraph
+			if i < len(lines)-1 && strings.TrimSpace(lines[i+1]) != "" {
+				line += "\\"
+			}
+		}
+
+		r.w.line(line)
+		r.w.task = task
+	}
+}
+
+// splitLines splits inline content at line breaks and soft breaks. A line
+// break at the end doesn't add an empty line, like in HTML.
+func splitLines(s string) []string {
+	var lines []string
+	var current strings.Builder
+	for _, c := range s {
+		switch c {
+		case '\n':
+			lines = append(lines, current.String())
+			current.Reset()
+		case softBreak:
+			if strings.TrimSpace(current.String()) != "" {
+				lines = append(lines, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(c)
+		}
+	}
+	if strings.TrimSpace(current.String()) != "" {
+		lines = append(lines, current.String())
+	}
+	return lines
+}
+
+func (r *renderer) renderBlock(n *Node) {
+	switch n.Type {
+	case HeadingNode:
+		text := r.inlineLine(n)
+		if text == "" {
+			return
+		}
+		if r.markdown {
+			r.w.separate()
+			r.w.line(strings.Repeat("#", max(n.Level, 1)) + " " + text)
+			r.w.separate()
+		} else {
+			r.w.line(text)
+		}
+
+	case ListNode:
+		r.renderList(n)
+
+	case ListItemNode:
+		// list item outside of a list
+		r.w.push("- ", "  ")
+		r.listDepth++
+		r.renderChildren(n)
+		r.listDepth--
+		r.w.pop()
+
+	case TableNode:
+		r.renderTable(tableRows(n))
+
+	case TableRowNode:
+		r.renderTable([]*Node{n})
+
+	case PreformattedNode:
+		text := PreText(n)
+		if r.markdown {
+			r.w.separate()
+			r.w.line("```")
+		}
+		for _, line := range strings.Split(text, "\n") {
+			r.w.line(line)
+		}
+		if r.markdown {
+			r.w.line("```")
+			r.w.separate()
+		}
+
+	case QuoteNode:
+		if r.markdown {
+			r.w.separate()
+		}
+		r.w.push("> ", "> ")
+		r.renderChildren(n)
+		r.w.pop()
+		if r.markdown {
+			r.w.separate()
+		}
+
+	case RuleNode:
+		if r.markdown {
+			r.w.separate()
+			r.w.line("---")
+			r.w.separate()
+		} else {
+			r.w.line(strings.Repeat("-", 20))
+		}
+
+	default:
+		// documents, paragraphs and table cells outside of tables
+		r.renderChildren(n)
+		if r.markdown && r.listDepth == 0 {
+			r.w.separate()
+		}
+	}
+}
+
+func (r *renderer) renderList(n *Node) {
+	number := 1
+	if start, err := strconv.Atoi(n.Attr("start")); err == nil {
+		number = start
+	}
+
+	r.listDepth++
+	for _, child := range n.Children {
+		switch {
+		case child.Type == ListItemNode:
+			marker := "- "
+			if n.Ordered {
+				marker = fmt.Sprintf("%d. ", number)
+				number++
+			}
+			r.w.push(marker, strings.Repeat(" ", len(marker)))
+			r.renderChildren(child)
+			r.w.pop()
+		case child.Type == ListNode:
+			// Evernote nests lists directly inside lists
+			r.w.push("  ", "  ")
+			r.renderList(child)
+			r.w.pop()
+		case child.isBlock():
+			r.renderBlock(child)
+		default:
+			r.flush(r.inline(child))
+		}
+	}
+	r.listDepth--
+
+	if r.markdown && r.listDepth == 0 {
+		r.w.separate()
+	}
+}
+
+// tableRows returns the rows of a table
+func tableRows(n *Node) []*Node {
+	var rows []*Node
+	n.Walk(func(node *Node) bool {
+		if node.Type == TableRowNode {
+			rows = append(rows, node)
+			return false
+		}
+		// rows of nested tables belong to the nested table
+		return node == n || node.Type != TableNode
+	})
+	return rows
+}
+
+func (r *renderer) renderTable(rows []*Node) {
+	var table [][]string
+	columns := 0
+	for _, row := range rows {
+		var cells []string
+		for _, cell := range row.Children {
+			if cell.Type == TableCellNode {
+				cells = append(cells, r.cellText(cell))
+			}
+		}
+		columns = max(columns, len(cells))
+		table = append(table, cells)
+	}
+	if columns == 0 {
+		return
+	}
+
+	// pad rows to the same number of columns
+	widths := make([]int, columns)
+	for i := range table {
+		for len(table[i]) < columns {
+			table[i] = append(table[i], "")
+		}
+		for j, cell := range table[i] {
+			widths[j] = max(widths[j], len([]rune(cell)))
+		}
+	}
+
+	if r.markdown {
+		r.w.separate()
+		for i, cells := range table {
+			r.w.line("| " + strings.Join(cells, " | ") + " |")
+			if i == 0 {
+				r.w.line("|" + strings.Repeat(" --- |", columns))
+			}
+		}
+		r.w.separate()
+		return
+	}
+
+	for _, cells := range table {
+		padded := make([]string, len(cells))
+		for j, cell := range cells {
+			padded[j] = cell + strings.Repeat(" ", widths[j]-len([]rune(cell)))
+		}
+		r.w.line(strings.Join(padded, " | "))
+	}
+}
+
+// cellText renders the content of a table cell on a single line
+func (r *renderer) cellText(cell *Node) string {
+	sub := newRenderer(r.opts, r.markdown)
+	sub.listDepth = 1
+	sub.renderChildren(cell)
+
+	lines := strings.Split(sub.w.String(), "\n")
+	if r.markdown {
+		for i := range lines {
+			lines[i] = strings.TrimSuffix(lines[i], "\\")
+		}
+		return strings.ReplaceAll(strings.Join(lines, "<br>"), "|", "\\|")
+	}
+	return strings.Join(lines, " ")
+}
+
+// inlineLine renders the children of a node as a single line
+func (r *renderer) inlineLine(n *Node) string {
+	text := r.inlineChildren(n)
+	text = strings.NewReplacer("\n", " ", string(softBreak), " ").Replace(text)
+	return strings.TrimSpace(whitespace.ReplaceAllString(text, " "))
+}
+
+func (r *renderer) inlineChildren(n *Node) string {
+	var b strings.Builder
+	for _, child := range n.Children {
+		b.WriteString(r.inline(child))
+	}
+	return b.String()
+}
+
+// inline renders a node as inline content. Line breaks are kept as newlines.
+func (r *renderer) inline(n *Node) string {
+	switch n.Type {
+	case TextNode:
+		text := whitespace.ReplaceAllString(n.Text, " ")
+		if r.markdown {
+			return escapeMarkdown(text)
+		}
+		return text
+
+	case LineBreakNode:
+		return "\n"
+
+	case TodoNode:
+		if n.Checked {
+			return "[x] "
+		}
+		return "[ ] "
+
+	case LinkNode:
+		label := strings.TrimSpace(r.inlineChildren(n))
+		href := n.Attr("href")
+		switch {
+		case href == "":
+			return label
+		case r.markdown && label == "":
+			return "<" + href + ">"
+		case r.markdown:
+			return "[" + label + "](" + href + ")"
+		case label == "" || label == href:
+			return href
+		default:
+			return label + " (" + href + ")"
+		}
+
+	case MediaNode:
+		m, ok := r.opts.media(n)
+		if !ok {
+			return ""
+		}
+		name := m.FileName
+		if name == "" {
+			name = "attachment"
+		}
+		if !r.markdown {
+			return "[" + name + "]"
+		}
+		target := strings.ReplaceAll(name, " ", "%20")
+		if strings.HasPrefix(m.MimeType, "image/") {
+			return "![" + escapeMarkdown(name) + "](" + target + ")"
+		}
+		return "[" + escapeMarkdown(name) + "](" + target + ")"
+
+	case CryptNode:
+		if hint := n.Attr("hint"); hint != "" {
+			return "[encrypted content: " + hint + "]"
+		}
+		return "[encrypted content]"
+
+	case ImageNode:
+		alt := n.Attr("alt")
+		src := n.Attr("src")
+		if r.markdown && src != "" && !strings.HasPrefix(src, "data:") {
+			return "![" + escapeMarkdown(alt) + "](" + src + ")"
+		}
+		return alt
+
+	case CodeNode:
+		if r.markdown {
+			return wrapInline(codeText(n), "`")
+		}
+		return r.inlineChildren(n)
+
+	case BoldNode:
+		return r.wrap(n, "**")
+	case ItalicNode:
+		return r.wrap(n, "*")
+	case StrikethroughNode:
+		return r.wrap(n, "~~")
+	case UnderlineNode:
+		return r.inlineChildren(n)
+	}
+
+	// block nodes nested in inline content end up on their own lines
+	if n.isBlock() {
+		return string(softBreak) + r.inlineChildren(n) + string(softBreak)
+	}
+	return r.inlineChildren(n)
+}
+
+// wrap renders the children of a formatting node and wraps them in a Markdown marker
+func (r *renderer) wrap(n *Node, marker string) string {
+	content := r.inlineChildren(n)
+	if !r.markdown {
+		return content
+	}
+	return wrapInline(content, marker)
+}
+
+// wrapInline wraps text in a marker, keeping surrounding whitespace outside
+func wrapInline(text, marker string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	start := strings.Index(text, trimmed)
+	return text[:start] + marker + trimmed + marker + text[start+len(trimmed):]
+}
+
+// codeText returns the unformatted text of a node
+func codeText(n *Node) string {
+	var b strings.Builder
+	n.Walk(func(node *Node) bool {
+		if node.Type == TextNode {
+			b.WriteString(whitespace.ReplaceAllString(node.Text, " "))
+		}
+		return true
+	})
+	return b.String()
+}
+
+// PreText returns the text of preformatted content with its whitespace preserved
+func PreText(n *Node) string {
+	var b strings.Builder
+	var walk func(node *Node)
+	walk = func(node *Node) {
+		for _, child := range node.Children {
+			switch {
+			case child.Type == TextNode:
+				b.WriteString(strings.ReplaceAll(child.Text, "\u00a0", " "))
+			case child.Type == LineBreakNode:
+				b.WriteString("\n")
+			case child.isBlock():
+				walk(child)
+				if !strings.HasSuffix(b.String(), "\n") {
+					b.WriteString("\n")
+				}
+			default:
+				walk(child)
+			}
+		}
+	}
+	walk(n)
+
+	text := strings.TrimPrefix(b.String(), "\n")
+	return strings.TrimRight(text, "\n")
+}
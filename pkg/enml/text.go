@@ -0,0 +1,10 @@
+package enml
+
+// Text renders the tree as plain text. Paragraphs become lines, lists are
+// indented with markers, checkboxes are written as [ ] and [x] and table
+// cells are aligned in columns.
+func Text(doc *Node, opts Options) string {
+	r := newRenderer(opts, false)
+	r.renderBlock(doc)
+	return r.w.String()
+}
@@ -0,0 +1,48 @@
+package enml
+
+import (
+	"testing"
+)
+
+// TestText tests the plain text renderer
+func TestText(t *testing.T) {
+	doc, err := Parse(testNote)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	expected := `Receipt
+paid on 3.4., ref 12345 & more
+
+line one
+line two
+- first
+- second
+  - nested
+1. one
+2. two
+[x] done
+[ ] open
+Item   | Price
+Coffee | 3.50
+see the site (https://example.com)
+[scan 1.png] [encrypted content: pin]
+func main() {
+  x := 1
+}`
+
+	result := Text(doc, testOptions)
+	if result != expected {
+		t.Errorf("Text() =\n%s\n\nexpected:\n%s", result, expected)
+	}
+}
+
+// TestTextWithoutMedia verifies unresolved media is left out
+func TestTextWithoutMedia(t *testing.T) {
+	doc, _ := Parse(`<en-note><div>scan:</div><en-media hash="abc123" type="image/png"/></en-note>`)
+
+	result := Text(doc, Options{})
+	if result != "scan:" {
+		t.Errorf("Text() = %q, expected %q", result, "scan:")
+	}
+}
@@ -0,0 +1,282 @@
+package paperless
+
+import (
+	"enex2paperless/internal/config"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authenticator adds credentials to requests to a Paperless instance
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// tokenAuth authenticates with an API token
+type tokenAuth string
+
+func (a tokenAuth) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Token "+string(a))
+	return nil
+}
+
+// basicAuth authenticates with username and password on every request
+type basicAuth struct {
+	username, password string
+}
+
+func (a basicAuth) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(a.username, a.password)
+	return nil
+}
+
+// remoteUserAuth sends the username in a header that a reverse proxy with
+// header-based SSO would set after login
+type remoteUserAuth struct {
+	header, username string
+}
+
+func (a remoteUserAuth) Authenticate(req *http.Request) error {
+	req.Header.Set(a.header, a.username)
+	return nil
+}
+
+// sessionAuth logs in like the Paperless web interface and sends the session
+// cookie, and the CSRF token with requests that change data
+type sessionAuth struct {
+	config   config.Config
+	mu       sync.Mutex
+	jar      http.CookieJar
+	loggedIn bool
+}
+
+var (
+	sessions      = make(map[string]*sessionAuth)
+	sessionsMutex sync.Mutex
+)
+
+// NewAuthenticator returns the authenticator for the auth mode and credentials of a
+// configuration. Sessions are shared by all files uploaded to the same instance.
+func NewAuthenticator(cfg config.Config) Authenticator {
+	switch cfg.AuthMode {
+	case config.AuthModeRemoteUser:
+		header := cfg.RemoteUserHeader
+		if header == "" {
+			header = config.DefaultRemoteUserHeader
+		}
+		return remoteUserAuth{header: header, username: cfg.Username}
+	case config.AuthModeSession:
+		return session(cfg)
+	}
+
+	if cfg.Token != "" {
+		return tokenAuth(cfg.Token)
+	}
+	return basicAuth{username: cfg.Username, password: cfg.Password}
+}
+
+// session returns the session of the user at the instance
+func session(cfg config.Config) *sessionAuth {
+	key := cfg.PaperlessAPI + "|" + cfg.Username
+
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+	if s, exists := sessions[key]; exists {
+		return s
+	}
+
+	jar, _ := cookiejar.New(nil)
+	s := &sessionAuth{config: cfg, jar: jar}
+	sessions[key] = s
+	return s
+}
+
+func (a *sessionAuth) Authenticate(req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.loggedIn {
+		if err := a.login(); err != nil {
+			return err
+		}
+		a.loggedIn = true
+	}
+
+	for _, cookie := range a.jar.Cookies(req.URL) {
+		req.AddCookie(cookie)
+	}
+
+	// Django checks the CSRF token of requests that change data, and the referer over HTTPS
+	if req.Method != "GET" && req.Method != "HEAD" {
+		if token := a.cookie(req.URL, "csrftoken"); token != "" {
+			req.Header.Set("X-CSRFToken", token)
+		}
+		req.Header.Set("Referer", a.config.PaperlessAPI+"/")
+	}
+	return nil
+}
+
+// invalidate discards the session if it is still the one a rejected request was
+// sent with, so that the next request logs in again. A session renewed by
+// another request in the meantime is kept.
+func (a *sessionAuth) invalidate(req *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var used string
+	for _, cookie := range req.Cookies() {
+		if strings.HasSuffix(cookie.Name, "sessionid") {
+			used = cookie.Value
+		}
+	}
+	if !a.loggedIn || a.cookie(req.URL, "sessionid") != used {
+		return
+	}
+
+	slog.Info("Paperless session expired, logging in again", "api", a.config.PaperlessAPI, "user", a.config.Username)
+	a.jar, _ = cookiejar.New(nil)
+	a.loggedIn = false
+}
+
+// login posts username and password to the login form of Paperless
+func (a *sessionAuth) login() error {
+	loginURL := a.config.PaperlessAPI + "/accounts/login/"
+	client := &http.Client{
+		Jar:     a.jar,
+		Timeout: time.Second * 10,
+		// a successful login redirects, the session cookie is set on the redirect
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	// the login page sets the CSRF cookie
+	req, err := http.NewRequest("GET", loginURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	setHeaders(req, a.config)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to load login page: %w", err)
+	}
+	resp.Body.Close()
+
+	parsedURL, err := url.Parse(loginURL)
+	if err != nil {
+		return fmt.Errorf("invalid login URL: %w", err)
+	}
+	csrfToken := a.cookie(parsedURL, "csrftoken")
+	if csrfToken == "" {
+		return errors.New("session login failed: Paperless didn't set a CSRF cookie")
+	}
+
+	// Paperless-ngx 2.x uses django-allauth with the login field, older versions username
+	form := url.Values{
+		"csrfmiddlewaretoken": {csrfToken},
+		"login":               {a.config.Username},
+		"username":            {a.config.Username},
+		"password":            {a.config.Password},
+	}
+	req, err = http.NewRequest("POST", loginURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	setHeaders(req, a.config)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Referer", loginURL)
+	req.Header.Set("X-CSRFToken", csrfToken)
+
+	slog.Debug("request details", "method", req.Method, "url", req.URL.String())
+
+	resp, err = client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to log in: %w", err)
+	}
+	resp.Body.Close()
+
+	if a.cookie(parsedURL, "sessionid") == "" {
+		return errors.New("session login failed: invalid username or password")
+	}
+
+	slog.Debug("logged in to Paperless", "api", a.config.PaperlessAPI, "user", a.config.Username)
+	return nil
+}
+
+// cookie returns the value of a cookie for the URL. Paperless names its cookies
+// with an optional prefix (PAPERLESS_COOKIE_PREFIX), so the suffix is matched.
+func (a *sessionAuth) cookie(u *url.URL, name string) string {
+	for _, cookie := range a.jar.Cookies(u) {
+		if strings.HasSuffix(cookie.Name, name) {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// setHeaders adds the configured static headers and cookies to a request
+func setHeaders(req *http.Request, cfg config.Config) {
+	for name, value := range cfg.Headers {
+		req.Header.Set(name, value)
+	}
+	for _, cookie := range cfg.Cookies {
+		name, value, _ := strings.Cut(cookie, "=")
+		req.AddCookie(&http.Cookie{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value)})
+	}
+}
+
+// errAuthentication wraps errors of authenticators, e.g. a failed session login
+var errAuthentication = errors.New("authentication failed")
+
+// authenticate adds the static headers and the credentials of a configuration to a request
+func authenticate(req *http.Request, cfg config.Config) error {
+	setHeaders(req, cfg)
+	if err := NewAuthenticator(cfg).Authenticate(req); err != nil {
+		return fmt.Errorf("%w: %w", errAuthentication, err)
+	}
+	return nil
+}
+
+// do sends an authenticated request. In session mode, a request rejected because
+// the session expired, Paperless restarted or the CSRF token changed is sent once
+// more with a new login.
+func do(client *http.Client, req *http.Request, cfg config.Config) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil || cfg.AuthMode != config.AuthModeSession {
+		return resp, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden {
+		return resp, nil
+	}
+	// the body of the request can't be sent again
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+
+	session(cfg).invalidate(req)
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	retry.Header.Del("Cookie")
+	retry.Header.Del("X-CSRFToken")
+	if err := authenticate(retry, cfg); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	resp.Body.Close()
+	return client.Do(retry)
+}
@@ -0,0 +1,178 @@
+package paperless
+
+import (
+	"enex2paperless/internal/config"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name            string
+		config          config.Config
+		expectedHeaders map[string]string
+	}{
+		{
+			name:            "token",
+			config:          config.Config{Token: "abc", Username: "user", Password: "secret"},
+			expectedHeaders: map[string]string{"Authorization": "Token abc"},
+		},
+		{
+			name:            "basic auth",
+			config:          config.Config{Username: "user", Password: "secret"},
+			expectedHeaders: map[string]string{"Authorization": "Basic dXNlcjpzZWNyZXQ="},
+		},
+		{
+			name:            "remote user",
+			config:          config.Config{AuthMode: config.AuthModeRemoteUser, Username: "jane"},
+			expectedHeaders: map[string]string{"Remote-User": "jane", "Authorization": ""},
+		},
+		{
+			name:            "custom remote user header",
+			config:          config.Config{AuthMode: config.AuthModeRemoteUser, RemoteUserHeader: "X-Forwarded-User", Username: "jane"},
+			expectedHeaders: map[string]string{"X-Forwarded-User": "jane", "Remote-User": ""},
+		},
+		{
+			name: "static headers and cookies",
+			config: config.Config{
+				Token:   "abc",
+				Headers: map[string]string{"x-proxy-key": "key"},
+				Cookies: []string{"authelia_session=xyz", "Lang = de"},
+			},
+			expectedHeaders: map[string]string{
+				"Authorization": "Token abc",
+				"X-Proxy-Key":   "key",
+				"Cookie":        "authelia_session=xyz; Lang=de",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://paperless/api/", nil)
+			if err := authenticate(req, tt.config); err != nil {
+				t.Fatalf("authenticate() error: %v", err)
+			}
+			for name, expected := range tt.expectedHeaders {
+				if got := req.Header.Get(name); got != expected {
+					t.Errorf("header %s = %q, expected %q", name, got, expected)
+				}
+			}
+		})
+	}
+}
+
+// TestSessionAuth verifies the login with CSRF token and that the session is reused
+func TestSessionAuth(t *testing.T) {
+	var logins int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/accounts/login/" && r.Method == "GET":
+			http.SetCookie(w, &http.Cookie{Name: "csrftoken", Value: "csrf-1", Path: "/"})
+		case r.URL.Path == "/accounts/login/" && r.Method == "POST":
+			logins++
+			if r.PostFormValue("csrfmiddlewaretoken") != "csrf-1" || r.PostFormValue("login") != "user" || r.PostFormValue("password") != "secret" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "sessionid", Value: "session-1", Path: "/"})
+			http.Redirect(w, r, "/", http.StatusFound)
+		default:
+			session, err := r.Cookie("sessionid")
+			if err != nil || session.Value != "session-1" || r.Header.Get("X-CSRFToken") != "csrf-1" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer server.Close()
+
+	t.Run("login", func(t *testing.T) {
+		cfg := config.Config{PaperlessAPI: server.URL, AuthMode: config.AuthModeSession, Username: "user", Password: "secret"}
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequest("POST", server.URL+"/api/tags/", nil)
+			if err := authenticate(req, cfg); err != nil {
+				t.Fatalf("authenticate() error: %v", err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusCreated {
+				t.Errorf("status = %d, expected %d", resp.StatusCode, http.StatusCreated)
+			}
+		}
+		if logins != 1 {
+			t.Errorf("expected 1 login, got %d", logins)
+		}
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		cfg := config.Config{PaperlessAPI: server.URL, AuthMode: config.AuthModeSession, Username: "other", Password: "wrong"}
+		req, _ := http.NewRequest("GET", server.URL+"/api/", nil)
+		if err := authenticate(req, cfg); err == nil {
+			t.Error("expected login error but got none")
+		}
+	})
+}
+
+// TestSessionAuthExpired verifies that a rejected session is replaced with a new
+// login and the request is sent again
+func TestSessionAuthExpired(t *testing.T) {
+	var logins int
+	var valid string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/accounts/login/" && r.Method == "GET":
+			http.SetCookie(w, &http.Cookie{Name: "csrftoken", Value: "csrf", Path: "/"})
+		case r.URL.Path == "/accounts/login/" && r.Method == "POST":
+			logins++
+			valid = fmt.Sprintf("session-%d", logins)
+			http.SetCookie(w, &http.Cookie{Name: "sessionid", Value: valid, Path: "/"})
+			http.Redirect(w, r, "/", http.StatusFound)
+		default:
+			session, err := r.Cookie("sessionid")
+			body, _ := io.ReadAll(r.Body)
+			if err != nil || session.Value != valid || string(body) != "data" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer server.Close()
+
+	cfg := config.Config{PaperlessAPI: server.URL, AuthMode: config.AuthModeSession, Username: "user", Password: "secret"}
+	send := func() int {
+		req, _ := http.NewRequest("POST", server.URL+"/api/tags/", strings.NewReader("data"))
+		if err := authenticate(req, cfg); err != nil {
+			t.Fatalf("authenticate() error: %v", err)
+		}
+		resp, err := do(http.DefaultClient, req, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := send(); status != http.StatusCreated {
+		t.Fatalf("status = %d, expected %d", status, http.StatusCreated)
+	}
+
+	// Paperless restarts and forgets the session
+	valid = ""
+
+	if status := send(); status != http.StatusCreated {
+		t.Errorf("status after expiry = %d, expected %d", status, http.StatusCreated)
+	}
+	if logins != 2 {
+		t.Errorf("expected 2 logins, got %d", logins)
+	}
+}
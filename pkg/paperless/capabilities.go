@@ -0,0 +1,147 @@
+package paperless
+
+import (
+	"enex2paperless/internal/config"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// clientAPIVersion is the newest Paperless API version this client requests.
+// Servers that support it answer in this version even if they support newer ones.
+const clientAPIVersion = 7
+
+// Features lists the API features that depend on the Paperless version
+type Features struct {
+	// Notes is the /api/documents/<id>/notes/ endpoint (1.12.0)
+	Notes bool
+	// CustomFields are custom fields on documents (2.0.0)
+	CustomFields bool
+	// CustomFieldsOnUpload is the custom_fields field of post_document (2.15.0)
+	CustomFieldsOnUpload bool
+	// NestedTags are tags with a parent tag (2.19.0)
+	NestedTags bool
+}
+
+// allFeatures is assumed for instances that weren't checked or report an unknown version
+var allFeatures = Features{Notes: true, CustomFields: true, CustomFieldsOnUpload: true, NestedTags: true}
+
+var (
+	servers      = make(map[string]ServerInfo)
+	serversMutex sync.RWMutex
+)
+
+// GetServerInfo returns the version and features of a Paperless instance detected
+// by Preflight. ok is false if the instance hasn't been checked.
+func GetServerInfo(api string) (info ServerInfo, ok bool) {
+	serversMutex.RLock()
+	defer serversMutex.RUnlock()
+	info, ok = servers[api]
+	return info, ok
+}
+
+// setServerInfo stores the detected server info for the requests to an instance
+func setServerInfo(info ServerInfo) {
+	serversMutex.Lock()
+	defer serversMutex.Unlock()
+	servers[info.API] = info
+}
+
+// detectServerInfo reads the Paperless version and the newest supported API version
+// from the response headers of an authenticated request and negotiates the API version.
+// Without a version header, e.g. behind a proxy that strips it, all features are
+// assumed and the default API version is used.
+func detectServerInfo(api string, header http.Header) ServerInfo {
+	info := ServerInfo{API: api, Version: header.Get("X-Version")}
+	if info.Version == "" {
+		slog.Warn("Paperless didn't send its version, assuming all features are supported", "api", api)
+		info.Features = allFeatures
+		return info
+	}
+
+	// old versions don't report their API version and only know version 1
+	if serverAPIVersion, err := strconv.Atoi(header.Get("X-Api-Version")); err == nil && serverAPIVersion > 0 {
+		info.APIVersion = min(serverAPIVersion, clientAPIVersion)
+	}
+
+	version, ok := parseVersion(info.Version)
+	if !ok {
+		// e.g. development builds
+		info.Features = allFeatures
+		return info
+	}
+
+	info.Features = Features{
+		Notes:                atLeast(version, 1, 12),
+		CustomFields:         atLeast(version, 2, 0),
+		CustomFieldsOnUpload: atLeast(version, 2, 15),
+		NestedTags:           atLeast(version, 2, 19),
+	}
+	return info
+}
+
+// parseVersion parses the major and minor part of a version like 2.15.3
+func parseVersion(version string) ([2]int, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return [2]int{}, false
+	}
+
+	var parsed [2]int
+	for i := range parsed {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return [2]int{}, false
+		}
+		parsed[i] = n
+	}
+	return parsed, true
+}
+
+// atLeast reports whether version is major.minor or newer
+func atLeast(version [2]int, major, minor int) bool {
+	return version[0] > major || (version[0] == major && version[1] >= minor)
+}
+
+// setAPIVersion requests the negotiated API version of the instance
+func setAPIVersion(req *http.Request, api string) {
+	if info, ok := GetServerInfo(api); ok && info.APIVersion > 0 {
+		req.Header.Set("Accept", fmt.Sprintf("application/json; version=%d", info.APIVersion))
+	}
+}
+
+// features returns the features of the instance the file is uploaded to
+func (pf *PaperlessFile) features() Features {
+	if info, ok := GetServerInfo(pf.config.PaperlessAPI); ok {
+		return info.Features
+	}
+	return allFeatures
+}
+
+// customFieldsOnUpload reports whether custom fields are sent with the upload
+func (pf *PaperlessFile) customFieldsOnUpload() bool {
+	return pf.config.CustomFieldsOnUpload && pf.features().CustomFieldsOnUpload
+}
+
+// hasNote reports whether a note is attached to the document after consumption
+func (pf *PaperlessFile) hasNote() bool {
+	return pf.Note != "" && pf.features().Notes
+}
+
+// unsupportedFeatures lists the configured features the instance doesn't support
+func unsupportedFeatures(cfg config.Config, features Features) []string {
+	var unsupported []string
+	if len(cfg.CustomFields) > 0 && !features.CustomFields {
+		unsupported = append(unsupported, "CustomFields")
+	}
+	if cfg.CustomFieldsOnUpload && features.CustomFields && !features.CustomFieldsOnUpload {
+		unsupported = append(unsupported, "CustomFieldsOnUpload")
+	}
+	if cfg.NoteBody && !features.Notes {
+		unsupported = append(unsupported, "NoteBody")
+	}
+	return unsupported
+}
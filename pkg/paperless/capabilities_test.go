@@ -0,0 +1,130 @@
+package paperless
+
+import (
+	"encoding/json"
+	"enex2paperless/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDetectServerInfo(t *testing.T) {
+	tests := []struct {
+		name               string
+		version            string
+		apiVersion         string
+		expectedAPIVersion int
+		expectedFeatures   Features
+	}{
+		{
+			name:               "current version",
+			version:            "2.19.1",
+			apiVersion:         "9",
+			expectedAPIVersion: clientAPIVersion,
+			expectedFeatures:   allFeatures,
+		},
+		{
+			name:               "custom fields on upload",
+			version:            "2.15.3",
+			apiVersion:         "7",
+			expectedAPIVersion: 7,
+			expectedFeatures:   Features{Notes: true, CustomFields: true, CustomFieldsOnUpload: true},
+		},
+		{
+			name:               "custom fields",
+			version:            "2.3.0",
+			apiVersion:         "5",
+			expectedAPIVersion: 5,
+			expectedFeatures:   Features{Notes: true, CustomFields: true},
+		},
+		{
+			name:               "notes only",
+			version:            "1.17.4",
+			apiVersion:         "3",
+			expectedAPIVersion: 3,
+			expectedFeatures:   Features{Notes: true},
+		},
+		{
+			name:             "no API version",
+			version:          "1.10.2",
+			expectedFeatures: Features{},
+		},
+		{
+			name:               "development build",
+			version:            "dev",
+			apiVersion:         "9",
+			expectedAPIVersion: clientAPIVersion,
+			expectedFeatures:   allFeatures,
+		},
+		{
+			name:             "no version",
+			apiVersion:       "9",
+			expectedFeatures: allFeatures,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.version != "" {
+				header.Set("X-Version", tt.version)
+			}
+			if tt.apiVersion != "" {
+				header.Set("X-Api-Version", tt.apiVersion)
+			}
+
+			info := detectServerInfo("http://paperless", header)
+
+			if info.APIVersion != tt.expectedAPIVersion {
+				t.Errorf("APIVersion = %d, expected %d", info.APIVersion, tt.expectedAPIVersion)
+			}
+			if info.Features != tt.expectedFeatures {
+				t.Errorf("Features = %+v, expected %+v", info.Features, tt.expectedFeatures)
+			}
+		})
+	}
+}
+
+// TestNegotiatedAPIVersion verifies that requests after the preflight ask for the
+// negotiated API version and that unsupported features are skipped
+func TestNegotiatedAPIVersion(t *testing.T) {
+	var accept string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Version", "1.11.0")
+		w.Header().Set("X-Api-Version", "2")
+		if r.URL.Path == "/api/document_types/" {
+			accept = r.Header.Get("Accept")
+		}
+		json.NewEncoder(w).Encode(map[string]any{"count": 1, "results": []any{map[string]int{"id": 3}}})
+	}))
+	defer server.Close()
+
+	cfg, err := Connect(config.Config{
+		PaperlessAPI: server.URL,
+		Token:        "test-token",
+		NoteBody:     true,
+		CustomFields: []config.CustomField{{Name: "Source URL", Source: "sourceurl"}},
+	})
+	if err != nil {
+		t.Fatalf("Connect() error: %v", err)
+	}
+
+	pf := NewPaperlessFile("title", "file.pdf", "application/pdf", "", nil, nil, cfg)
+	pf.DocumentType = "Invoice"
+	pf.Note = "note text"
+	pf.CustomFields = []CustomField{{Name: "Source URL", DataType: "url", Value: "https://example.com"}}
+
+	if err := pf.processAssignments(); err != nil {
+		t.Fatalf("processAssignments() error: %v", err)
+	}
+	if accept != "application/json; version=2" {
+		t.Errorf("Accept = %q, expected version 2", accept)
+	}
+
+	if err := pf.processCustomFields(); err != nil {
+		t.Fatalf("processCustomFields() error: %v", err)
+	}
+	if pf.needsDocumentID() {
+		t.Error("expected custom fields and notes to be skipped on Paperless 1.11")
+	}
+}
@@ -19,3 +19,12 @@ func getSharedClient() *http.Client {
 	})
 	return client
 }
+
+// setAuth adds the configured headers, the credentials and the negotiated API version to a request
+func (pf *PaperlessFile) setAuth(req *http.Request) error {
+	if err := authenticate(req, pf.config); err != nil {
+		return err
+	}
+	setAPIVersion(req, pf.config.PaperlessAPI)
+	return nil
+}
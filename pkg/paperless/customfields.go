@@ -0,0 +1,186 @@
+package paperless
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const customFieldsEndpoint = "custom_fields"
+
+// CustomField is a custom field value to set on the uploaded document
+type CustomField struct {
+	Name     string
+	DataType string
+	Value    string
+}
+
+// customFieldInstance is a custom field value as expected by the Paperless API
+type customFieldInstance struct {
+	Field int `json:"field"`
+	Value any `json:"value"`
+}
+
+// processCustomFields gets or creates all custom fields and converts their values
+func (pf *PaperlessFile) processCustomFields() error {
+	pf.customFieldInstances = nil
+	if !pf.features().CustomFields {
+		return nil
+	}
+
+	for _, field := range pf.CustomFields {
+		value, err := customFieldValue(field)
+		if err != nil {
+			slog.Warn("skipping custom field with invalid value",
+				"field", field.Name,
+				"value", field.Value,
+				"error", err)
+			continue
+		}
+
+		id, err := pf.getOrCreateObjectID(customFieldsEndpoint, field.Name, map[string]any{
+			"data_type": field.DataType,
+		})
+		if err != nil {
+			return err
+		}
+
+		pf.customFieldInstances = append(pf.customFieldInstances, customFieldInstance{Field: id, Value: value})
+	}
+
+	return nil
+}
+
+// customFieldValue converts the string value of a custom field to its data type
+func customFieldValue(field CustomField) (any, error) {
+	value := strings.TrimSpace(field.Value)
+
+	switch field.DataType {
+	case "integer":
+		return strconv.Atoi(value)
+	case "float":
+		return strconv.ParseFloat(value, 64)
+	case "boolean":
+		return strconv.ParseBool(value)
+	case "monetary":
+		// plain amounts are formatted with two decimals, values with a
+		// currency code (e.g. EUR12.50) are passed on unchanged
+		if amount, err := strconv.ParseFloat(value, 64); err == nil {
+			return fmt.Sprintf("%.2f", amount), nil
+		}
+		return value, nil
+	default:
+		return value, nil
+	}
+}
+
+// customFieldsFormValue encodes the custom field values as JSON object for post_document
+func (pf *PaperlessFile) customFieldsFormValue() (string, error) {
+	values := make(map[string]any, len(pf.customFieldInstances))
+	for _, instance := range pf.customFieldInstances {
+		values[strconv.Itoa(instance.Field)] = instance.Value
+	}
+
+	jsonData, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal custom fields: %w", err)
+	}
+	return string(jsonData), nil
+}
+
+// applyCustomFields sets the custom field values on a consumed document. The
+// fields Paperless already set, e.g. with workflows, are kept.
+func (pf *PaperlessFile) applyCustomFields(documentID int) error {
+	url := fmt.Sprintf("%s/api/documents/%d/", pf.config.PaperlessAPI, documentID)
+
+	existing, err := pf.documentCustomFields(documentID)
+	if err != nil {
+		return err
+	}
+
+	jsonData, err := json.Marshal(map[string]any{
+		"custom_fields": mergeCustomFields(existing, pf.customFieldInstances),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	req, err := http.NewRequest("PATCH", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := pf.setAuth(req); err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	slog.Debug("request details", "method", req.Method, "url", req.URL.String(), "body", string(jsonData))
+
+	resp, err := do(pf.client, req, pf.config)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(resp.Body)
+		return fmt.Errorf("non 200 status code received (%d): %s", resp.StatusCode, buf.String())
+	}
+
+	slog.Debug("applied custom fields", "document", documentID, "fields", len(pf.customFieldInstances))
+	return nil
+}
+
+// documentCustomFields returns the custom field values of a document
+func (pf *PaperlessFile) documentCustomFields(documentID int) ([]customFieldInstance, error) {
+	url := fmt.Sprintf("%s/api/documents/%d/", pf.config.PaperlessAPI, documentID)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := pf.setAuth(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := do(pf.client, req, pf.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve document: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(resp.Body)
+		return nil, fmt.Errorf("non 200 status code received (%d): %s", resp.StatusCode, buf.String())
+	}
+
+	var document struct {
+		CustomFields []customFieldInstance `json:"custom_fields"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return document.CustomFields, nil
+}
+
+// mergeCustomFields returns the existing custom field values with the new values
+// added, new values replace existing values of the same field
+func mergeCustomFields(existing, values []customFieldInstance) []customFieldInstance {
+	merged := make([]customFieldInstance, 0, len(existing)+len(values))
+	for _, instance := range existing {
+		replaced := slices.ContainsFunc(values, func(value customFieldInstance) bool {
+			return value.Field == instance.Field
+		})
+		if !replaced {
+			merged = append(merged, instance)
+		}
+	}
+	return append(merged, values...)
+}
@@ -0,0 +1,107 @@
+package paperless
+
+import (
+	"encoding/json"
+	"enex2paperless/internal/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestCustomFieldValue tests the conversion of custom field values to their data types
+func TestCustomFieldValue(t *testing.T) {
+	testCases := []struct {
+		field       CustomField
+		expected    any
+		expectError bool
+	}{
+		{field: CustomField{DataType: "string", Value: "Pixel 7"}, expected: "Pixel 7"},
+		{field: CustomField{DataType: "url", Value: "https://example.com"}, expected: "https://example.com"},
+		{field: CustomField{DataType: "float", Value: "47.5"}, expected: 47.5},
+		{field: CustomField{DataType: "integer", Value: "12"}, expected: 12},
+		{field: CustomField{DataType: "monetary", Value: "12.5"}, expected: "12.50"},
+		{field: CustomField{DataType: "monetary", Value: "EUR12.50"}, expected: "EUR12.50"},
+		{field: CustomField{DataType: "integer", Value: "twelve"}, expectError: true},
+	}
+
+	for _, tc := range testCases {
+		value, err := customFieldValue(tc.field)
+		if tc.expectError {
+			if err == nil {
+				t.Errorf("expected error for %+v", tc.field)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for %+v: %v", tc.field, err)
+			continue
+		}
+		if value != tc.expected {
+			t.Errorf("customFieldValue(%+v) = %v, expected %v", tc.field, value, tc.expected)
+		}
+	}
+}
+
+// TestUploadAppliesCustomFieldsAfterConsumption verifies that custom fields are
+// patched onto the document once its consumption task succeeded
+func TestUploadAppliesCustomFieldsAfterConsumption(t *testing.T) {
+	ClearObjectCache()
+	consumptionPollInterval = time.Millisecond
+
+	var patched string
+	taskPolls := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/custom_fields/" && r.Method == "GET":
+			json.NewEncoder(w).Encode(map[string]any{"count": 1, "results": []map[string]any{{"id": 7}}})
+		case r.URL.Path == "/api/documents/post_document/":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Errorf("failed to parse upload: %v", err)
+			}
+			if r.FormValue("custom_fields") != "" {
+				t.Errorf("custom fields should not be sent with the upload")
+			}
+			json.NewEncoder(w).Encode("task-123")
+		case r.URL.Path == "/api/tasks/":
+			taskPolls++
+			if taskPolls < 2 {
+				json.NewEncoder(w).Encode([]map[string]any{{"task_id": "task-123", "status": "STARTED"}})
+				return
+			}
+			json.NewEncoder(w).Encode([]map[string]any{{"task_id": "task-123", "status": "SUCCESS", "related_document": "55"}})
+		case r.URL.Path == "/api/documents/55/" && r.Method == "GET":
+			// a workflow already set a field and the field of the import
+			json.NewEncoder(w).Encode(map[string]any{"id": 55, "custom_fields": []map[string]any{
+				{"field": 3, "value": "from workflow"},
+				{"field": 7, "value": "https://old.example.com"},
+			}})
+		case r.URL.Path == "/api/documents/55/" && r.Method == "PATCH":
+			body, _ := io.ReadAll(r.Body)
+			patched = string(body)
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	pf := NewPaperlessFile("title", "file.pdf", "application/pdf", "2022-01-01 12:00:00+00:00", []byte("data"), nil, config.Config{
+		PaperlessAPI: server.URL,
+		Token:        "test-token",
+	})
+	pf.CustomFields = []CustomField{{Name: "Source", DataType: "url", Value: "https://example.com"}}
+
+	if err := pf.Upload(); err != nil {
+		t.Fatalf("Upload() error: %v", err)
+	}
+
+	if pf.TaskID != "task-123" {
+		t.Errorf("TaskID = %q, expected task-123", pf.TaskID)
+	}
+	if patched != `{"custom_fields":[{"field":3,"value":"from workflow"},{"field":7,"value":"https://example.com"}]}` {
+		t.Errorf("unexpected custom fields patch: %s", patched)
+	}
+}
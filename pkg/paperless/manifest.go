@@ -0,0 +1,188 @@
+package paperless
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrDuplicateDocument is returned when a document with the same content is
+// already part of the manifest. Paperless requires unique checksums.
+var ErrDuplicateDocument = errors.New("document with the same checksum already in manifest")
+
+// Manifest collects the records of a Paperless export directory, as written by
+// `manage.py document_exporter`, so that `manage.py document_importer` can import
+// the documents without API access. It is safe for concurrent use.
+type Manifest struct {
+	mu sync.Mutex
+
+	tags           []manifestRecord
+	correspondents []manifestRecord
+	documentTypes  []manifestRecord
+	documents      []manifestRecord
+	notes          []manifestRecord
+
+	// primary keys by name
+	tagIDs           map[string]int
+	correspondentIDs map[string]int
+	documentTypeIDs  map[string]int
+	checksums        map[string]bool
+}
+
+// manifestRecord is a Django fixture record
+type manifestRecord struct {
+	Model  string `json:"model"`
+	PK     int    `json:"pk"`
+	Fields any    `json:"fields"`
+
+	// ExportedFileName is the path of the original file, relative to the export directory
+	ExportedFileName string `json:"__exported_file_name__,omitempty"`
+}
+
+// matchingFields are shared by tags, correspondents and document types.
+// Automatic matching is disabled for the created objects.
+type matchingFields struct {
+	Name              string `json:"name"`
+	Match             string `json:"match"`
+	MatchingAlgorithm int    `json:"matching_algorithm"`
+	IsInsensitive     bool   `json:"is_insensitive"`
+	Owner             *int   `json:"owner"`
+}
+
+type documentFields struct {
+	Title            string `json:"title"`
+	Content          string `json:"content"`
+	MimeType         string `json:"mime_type"`
+	Checksum         string `json:"checksum"`
+	Created          string `json:"created,omitempty"`
+	StorageType      string `json:"storage_type"`
+	OriginalFilename string `json:"original_filename"`
+	Correspondent    *int   `json:"correspondent"`
+	DocumentType     *int   `json:"document_type"`
+	Tags             []int  `json:"tags"`
+	Owner            *int   `json:"owner"`
+}
+
+type noteFields struct {
+	Document int    `json:"document"`
+	Note     string `json:"note"`
+	User     *int   `json:"user"`
+}
+
+// NewManifest creates an empty manifest
+func NewManifest() *Manifest {
+	return &Manifest{
+		tagIDs:           make(map[string]int),
+		correspondentIDs: make(map[string]int),
+		documentTypeIDs:  make(map[string]int),
+		checksums:        make(map[string]bool),
+	}
+}
+
+// AddDocument adds a document with its tags, document type, correspondent and note.
+// exportedFileName is the path of the saved file relative to the export directory.
+// Storage paths and custom fields aren't part of the manifest.
+func (m *Manifest) AddDocument(file *PaperlessFile, exportedFileName, correspondent string) error {
+	hash := md5.Sum(file.Data)
+	checksum := hex.EncodeToString(hash[:])
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.checksums[checksum] {
+		return ErrDuplicateDocument
+	}
+	m.checksums[checksum] = true
+
+	fields := documentFields{
+		Title:            file.Title,
+		MimeType:         file.MimeType,
+		Checksum:         checksum,
+		StorageType:      "unencrypted",
+		OriginalFilename: file.FileName,
+		Tags:             []int{},
+	}
+
+	// Paperless stores the created date without time since 2.16, older versions
+	// accept the date as well
+	if created, err := time.Parse("2006-01-02 15:04:05-07:00", file.Created); err == nil {
+		fields.Created = created.Format("2006-01-02")
+	}
+
+	for _, tag := range file.Tags {
+		id := m.objectID(&m.tags, m.tagIDs, "documents.tag", tag)
+		if id != 0 && !slices.Contains(fields.Tags, id) {
+			fields.Tags = append(fields.Tags, id)
+		}
+	}
+	if id := m.objectID(&m.correspondents, m.correspondentIDs, "documents.correspondent", correspondent); id != 0 {
+		fields.Correspondent = &id
+	}
+	if id := m.objectID(&m.documentTypes, m.documentTypeIDs, "documents.documenttype", file.DocumentType); id != 0 {
+		fields.DocumentType = &id
+	}
+
+	documentID := len(m.documents) + 1
+	m.documents = append(m.documents, manifestRecord{
+		Model:            "documents.document",
+		PK:               documentID,
+		Fields:           fields,
+		ExportedFileName: exportedFileName,
+	})
+
+	if file.Note != "" {
+		m.notes = append(m.notes, manifestRecord{
+			Model:  "documents.note",
+			PK:     len(m.notes) + 1,
+			Fields: noteFields{Document: documentID, Note: file.Note},
+		})
+	}
+
+	return nil
+}
+
+// Len returns the number of documents in the manifest
+func (m *Manifest) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.documents)
+}
+
+// MarshalJSON encodes the manifest as manifest.json. Referenced objects come before the documents.
+func (m *Manifest) MarshalJSON() ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	records := make([]manifestRecord, 0, len(m.correspondents)+len(m.tags)+len(m.documentTypes)+len(m.documents)+len(m.notes))
+	records = append(records, m.correspondents...)
+	records = append(records, m.tags...)
+	records = append(records, m.documentTypes...)
+	records = append(records, m.documents...)
+	records = append(records, m.notes...)
+
+	return json.MarshalIndent(records, "", "  ")
+}
+
+// objectID returns the primary key of a named object, adding a record if necessary.
+// Empty names return 0. Must be called with the lock held.
+func (m *Manifest) objectID(records *[]manifestRecord, ids map[string]int, model, name string) int {
+	if name == "" {
+		return 0
+	}
+	if id, ok := ids[name]; ok {
+		return id
+	}
+
+	id := len(*records) + 1
+	ids[name] = id
+	*records = append(*records, manifestRecord{
+		Model:  model,
+		PK:     id,
+		Fields: matchingFields{Name: name, IsInsensitive: true},
+	})
+	return id
+}
@@ -0,0 +1,108 @@
+package paperless
+
+import (
+	"encoding/json"
+	"enex2paperless/internal/config"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestManifest verifies the records written for the document_importer
+func TestManifest(t *testing.T) {
+	manifest := NewManifest()
+
+	first := NewPaperlessFile("Insurance", "policy.pdf", "application/pdf", "2023-04-03 12:30:00+00:00", []byte("policy"), []string{"Finance", "Car", "Finance"}, config.Config{})
+	first.DocumentType = "Contract"
+	first.Note = "renew in April"
+	if err := manifest.AddDocument(first, "policy.pdf", "Jane"); err != nil {
+		t.Fatalf("AddDocument() error: %v", err)
+	}
+
+	second := NewPaperlessFile("Receipt", "receipt.jpg", "image/jpeg", "invalid", []byte("receipt"), []string{"Finance"}, config.Config{})
+	if err := manifest.AddDocument(second, "2023/receipt.jpg", ""); err != nil {
+		t.Fatalf("AddDocument() error: %v", err)
+	}
+
+	duplicate := NewPaperlessFile("Copy", "copy.pdf", "application/pdf", "", []byte("policy"), nil, config.Config{})
+	if err := manifest.AddDocument(duplicate, "copy.pdf", ""); !errors.Is(err, ErrDuplicateDocument) {
+		t.Errorf("AddDocument() error = %v, expected ErrDuplicateDocument", err)
+	}
+
+	if manifest.Len() != 2 {
+		t.Errorf("Len() = %d, expected 2", manifest.Len())
+	}
+
+	data, err := manifest.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON() error: %v", err)
+	}
+
+	var records []struct {
+		Model            string         `json:"model"`
+		PK               int            `json:"pk"`
+		Fields           map[string]any `json:"fields"`
+		ExportedFileName string         `json:"__exported_file_name__"`
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		t.Fatalf("invalid manifest: %v", err)
+	}
+
+	var models []string
+	for _, record := range records {
+		models = append(models, record.Model)
+	}
+	expectedModels := []string{
+		"documents.correspondent",
+		"documents.tag",
+		"documents.tag",
+		"documents.documenttype",
+		"documents.document",
+		"documents.document",
+		"documents.note",
+	}
+	if !reflect.DeepEqual(models, expectedModels) {
+		t.Fatalf("models = %v, expected %v", models, expectedModels)
+	}
+
+	if name := records[2].Fields["name"]; name != "Car" || records[2].PK != 2 {
+		t.Errorf("second tag = %v (pk %d), expected Car (pk 2)", name, records[2].PK)
+	}
+	if algorithm := records[1].Fields["matching_algorithm"]; algorithm != 0.0 {
+		t.Errorf("matching_algorithm = %v, expected 0", algorithm)
+	}
+
+	document := records[4]
+	expectedFields := map[string]any{
+		"title":             "Insurance",
+		"content":           "",
+		"mime_type":         "application/pdf",
+		"checksum":          "f4af8b5789576c000ce9105b25609bd6",
+		"created":           "2023-04-03",
+		"storage_type":      "unencrypted",
+		"original_filename": "policy.pdf",
+		"correspondent":     1.0,
+		"document_type":     1.0,
+		"tags":              []any{1.0, 2.0},
+		"owner":             nil,
+	}
+	if !reflect.DeepEqual(document.Fields, expectedFields) {
+		t.Errorf("document fields = %v, expected %v", document.Fields, expectedFields)
+	}
+	if document.ExportedFileName != "policy.pdf" {
+		t.Errorf("exported file name = %q, expected policy.pdf", document.ExportedFileName)
+	}
+
+	receipt := records[5]
+	if _, ok := receipt.Fields["created"]; ok {
+		t.Errorf("created should be left out for invalid dates, got %v", receipt.Fields["created"])
+	}
+	if receipt.Fields["correspondent"] != nil || receipt.ExportedFileName != "2023/receipt.jpg" {
+		t.Errorf("unexpected receipt record: %+v", receipt)
+	}
+
+	note := records[6]
+	if note.Fields["document"] != 1.0 || note.Fields["note"] != "renew in April" {
+		t.Errorf("unexpected note record: %+v", note)
+	}
+}
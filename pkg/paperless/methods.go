@@ -2,6 +2,8 @@ package paperless
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
@@ -44,6 +46,43 @@ func (pf *PaperlessFile) Upload() error {
 		}
 	}
 
+	// Resolve document type and storage path
+	err = pf.processAssignments()
+	if err != nil {
+		return err
+	}
+
+	if pf.DocumentTypeID != 0 {
+		err = writer.WriteField("document_type", strconv.Itoa(pf.DocumentTypeID))
+		if err != nil {
+			return fmt.Errorf("couldn't write fields: %w", err)
+		}
+	}
+
+	if pf.StoragePathID != 0 {
+		err = writer.WriteField("storage_path", strconv.Itoa(pf.StoragePathID))
+		if err != nil {
+			return fmt.Errorf("couldn't write fields: %w", err)
+		}
+	}
+
+	// Resolve custom fields
+	err = pf.processCustomFields()
+	if err != nil {
+		return err
+	}
+
+	if pf.customFieldsOnUpload() && len(pf.customFieldInstances) > 0 {
+		customFields, err := pf.customFieldsFormValue()
+		if err != nil {
+			return err
+		}
+		err = writer.WriteField("custom_fields", customFields)
+		if err != nil {
+			return fmt.Errorf("couldn't write fields: %w", err)
+		}
+	}
+
 	// Create form file header
 	h := make(textproto.MIMEHeader)
 	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="document"; filename="%s"`, pf.FileName))
@@ -69,11 +108,8 @@ func (pf *PaperlessFile) Upload() error {
 		return fmt.Errorf("error creating new HTTP request: %w", err)
 	}
 
-	// Get settings for authentication
-	if pf.config.Token != "" {
-		req.Header.Set("Authorization", "Token "+pf.config.Token)
-	} else {
-		req.SetBasicAuth(pf.config.Username, pf.config.Password)
+	if err := pf.setAuth(req); err != nil {
+		return err
 	}
 
 	req.Header.Set("Content-Type", writer.FormDataContentType())
@@ -82,7 +118,7 @@ func (pf *PaperlessFile) Upload() error {
 	slog.Debug("sending POST request", "file", pf.FileName)
 	slog.Debug("request details", "method", req.Method, "url", req.URL.String(), "headers", req.Header)
 
-	resp, err := pf.client.Do(req)
+	resp, err := do(pf.client, req, pf.config)
 	if err != nil {
 		return fmt.Errorf("error making POST request: %w", err)
 	}
@@ -97,6 +133,62 @@ func (pf *PaperlessFile) Upload() error {
 		return fmt.Errorf("non 200 status code received (%d): %s", resp.StatusCode, buf.String())
 	}
 
+	// Paperless responds with the ID of the consumption task
+	err = json.NewDecoder(resp.Body).Decode(&pf.TaskID)
+	if err != nil {
+		slog.Debug("couldn't decode consumption task ID", "error", err)
+	}
+
+	// The document is uploaded at this point. Errors after consumption are
+	// only logged, retrying the upload would create a duplicate.
+	if pf.needsDocumentID() {
+		if pf.PostConsume != nil {
+			pf.PostConsume.add(pf)
+		} else {
+			pf.finishUpload(context.Background())
+		}
+	}
+
+	return nil
+}
+
+// finishUpload applies what's left after the consumption of the uploaded document
+func (pf *PaperlessFile) finishUpload(ctx context.Context) {
+	err := pf.postConsume(ctx)
+	if err != nil {
+		slog.Error("document uploaded, but couldn't update it after consumption",
+			"file", pf.FileName,
+			"error", err)
+	}
+}
+
+// needsDocumentID reports whether work is left that requires the consumed document
+func (pf *PaperlessFile) needsDocumentID() bool {
+	return pf.hasNote() || (len(pf.customFieldInstances) > 0 && !pf.customFieldsOnUpload())
+}
+
+// postConsume waits for the document to be consumed and applies everything
+// that can only be set on an existing document
+func (pf *PaperlessFile) postConsume(ctx context.Context) error {
+	documentID, err := pf.waitForDocument(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(pf.customFieldInstances) > 0 && !pf.customFieldsOnUpload() {
+		err = pf.applyCustomFields(documentID)
+		if err != nil {
+			return fmt.Errorf("failed to apply custom fields: %w", err)
+		}
+	}
+
+	if pf.hasNote() {
+		err = pf.addNote(documentID)
+		if err != nil {
+			return fmt.Errorf("failed to add note: %w", err)
+		}
+	}
+
 	return nil
 }
 
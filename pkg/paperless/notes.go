@@ -0,0 +1,48 @@
+package paperless
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// addNote attaches the note text to a consumed document
+func (pf *PaperlessFile) addNote(documentID int) error {
+	url := fmt.Sprintf("%s/api/documents/%d/notes/", pf.config.PaperlessAPI, documentID)
+
+	jsonData, err := json.Marshal(map[string]string{
+		"note": pf.Note,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := pf.setAuth(req); err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	slog.Debug("request details", "method", req.Method, "url", req.URL.String())
+
+	resp, err := do(pf.client, req, pf.config)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// depending on the version, Paperless answers with 200 or 201
+	if resp.StatusCode != 200 && resp.StatusCode != 201 {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(resp.Body)
+		return fmt.Errorf("non 2xx status code received (%d): %s", resp.StatusCode, buf.String())
+	}
+
+	slog.Debug("added note to document", "document", documentID)
+	return nil
+}
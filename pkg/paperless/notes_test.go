@@ -0,0 +1,47 @@
+package paperless
+
+import (
+	"encoding/json"
+	"enex2paperless/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestUploadAddsNoteAfterConsumption verifies the note text is posted to the consumed document
+func TestUploadAddsNoteAfterConsumption(t *testing.T) {
+	consumptionPollInterval = time.Millisecond
+
+	var postedNote map[string]string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/documents/post_document/":
+			json.NewEncoder(w).Encode("task-456")
+		case "/api/tasks/":
+			json.NewEncoder(w).Encode([]map[string]any{{"task_id": "task-456", "status": "SUCCESS", "related_document": 12}})
+		case "/api/documents/12/notes/":
+			json.NewDecoder(r.Body).Decode(&postedNote)
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	pf := NewPaperlessFile("title", "file.pdf", "application/pdf", "2022-01-01 12:00:00+00:00", []byte("data"), nil, config.Config{
+		PaperlessAPI: server.URL,
+		Token:        "test-token",
+	})
+	pf.Note = "paid on 3.4., ref 12345"
+
+	if err := pf.Upload(); err != nil {
+		t.Fatalf("Upload() error: %v", err)
+	}
+
+	if postedNote["note"] != pf.Note {
+		t.Errorf("posted note = %q, expected %q", postedNote["note"], pf.Note)
+	}
+}
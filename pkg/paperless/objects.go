@@ -0,0 +1,202 @@
+package paperless
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// API endpoints of named Paperless objects that are resolved by name
+const (
+	documentTypesEndpoint = "document_types"
+	storagePathsEndpoint  = "storage_paths"
+)
+
+// defaultStoragePathFormat is appended to the storage path name when a
+// storage path has to be created
+const defaultStoragePathFormat = "{created_year}/{title}"
+
+var (
+	objectCache      = make(map[string]int)
+	objectCacheMutex sync.RWMutex
+)
+
+// ClearObjectCache clears the cache of document types, storage paths and other named objects
+func ClearObjectCache() {
+	objectCacheMutex.Lock()
+	defer objectCacheMutex.Unlock()
+	objectCache = make(map[string]int)
+	slog.Debug("object cache cleared")
+}
+
+// objectCacheKey builds the cache key for a named object of an endpoint,
+// objects are cached per Paperless instance
+func objectCacheKey(api, endpoint, name string) string {
+	return api + "|" + endpoint + "/" + name
+}
+
+// getOrCreateObjectID retrieves or creates a named object in a thread-safe manner.
+// fields are sent in addition to the name when the object has to be created.
+func (pf *PaperlessFile) getOrCreateObjectID(endpoint, name string, fields map[string]any) (int, error) {
+	key := objectCacheKey(pf.config.PaperlessAPI, endpoint, name)
+
+	// First check the cache with a read lock
+	objectCacheMutex.RLock()
+	if id, exists := objectCache[key]; exists {
+		objectCacheMutex.RUnlock()
+		slog.Debug("object found in cache", "endpoint", endpoint, "name", name, "id", id)
+		return id, nil
+	}
+	objectCacheMutex.RUnlock()
+
+	// If not in cache, acquire write lock to prevent concurrent creation
+	objectCacheMutex.Lock()
+	defer objectCacheMutex.Unlock()
+
+	// Double-check the cache in case another goroutine added it
+	if id, exists := objectCache[key]; exists {
+		return id, nil
+	}
+
+	id, err := pf.getObjectID(endpoint, name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check for %s: %w", endpoint, err)
+	}
+
+	if id == 0 {
+		slog.Debug("creating object", "endpoint", endpoint, "name", name)
+		id, err = pf.createObject(endpoint, name, fields)
+		if err != nil {
+			return 0, fmt.Errorf("couldn't create %s %q: %w", endpoint, name, err)
+		}
+	} else {
+		slog.Debug("found object", "endpoint", endpoint, "name", name, "id", id)
+	}
+
+	objectCache[key] = id
+	return id, nil
+}
+
+// getObjectID looks up a named object by its exact name (case-insensitive).
+// It returns 0 if no such object exists.
+func (pf *PaperlessFile) getObjectID(endpoint, name string) (int, error) {
+	url := fmt.Sprintf("%v/api/%s/?name__iexact=%s", pf.config.PaperlessAPI, endpoint, url.QueryEscape(name))
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := pf.setAuth(req); err != nil {
+		return 0, err
+	}
+
+	slog.Debug("request details", "method", req.Method, "url", req.URL.String())
+
+	resp, err := do(pf.client, req, pf.config)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve %s: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(resp.Body)
+		slog.Error("non 200 status code received", "status code", resp.StatusCode, "body", buf.String())
+		return 0, fmt.Errorf("non 200 status code received (%d)", resp.StatusCode)
+	}
+
+	var listResponse struct {
+		Count   int `json:"count"`
+		Results []struct {
+			ID int `json:"id"`
+		} `json:"results"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&listResponse); err != nil {
+		return 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if listResponse.Count == 0 || len(listResponse.Results) == 0 {
+		return 0, nil
+	}
+
+	return listResponse.Results[0].ID, nil
+}
+
+// createObject creates a named object and returns its ID
+func (pf *PaperlessFile) createObject(endpoint, name string, fields map[string]any) (int, error) {
+	url := fmt.Sprintf("%v/api/%s/", pf.config.PaperlessAPI, endpoint)
+
+	payload := map[string]any{"name": name}
+	for k, v := range fields {
+		payload[k] = v
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := pf.setAuth(req); err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	slog.Debug("request details", "method", req.Method, "url", req.URL.String(), "body", string(jsonData))
+
+	resp, err := do(pf.client, req, pf.config)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != 201 {
+		slog.Error("non 201 status code received", "status code", resp.StatusCode, "body", string(bodyBytes))
+		return 0, fmt.Errorf("non 201 status code received (%d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var objectResponse struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(bodyBytes, &objectResponse); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return objectResponse.ID, nil
+}
+
+// processAssignments resolves the document type and storage path names to IDs
+func (pf *PaperlessFile) processAssignments() error {
+	if pf.DocumentType != "" {
+		id, err := pf.getOrCreateObjectID(documentTypesEndpoint, pf.DocumentType, nil)
+		if err != nil {
+			return err
+		}
+		pf.DocumentTypeID = id
+	}
+
+	if pf.StoragePath != "" {
+		id, err := pf.getOrCreateObjectID(storagePathsEndpoint, pf.StoragePath, map[string]any{
+			"path": pf.StoragePath + "/" + defaultStoragePathFormat,
+		})
+		if err != nil {
+			return err
+		}
+		pf.StoragePathID = id
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package paperless
+
+import (
+	"encoding/json"
+	"enex2paperless/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// TestGetOrCreateObjectID verifies that missing objects are created once and cached afterwards
+func TestGetOrCreateObjectID(t *testing.T) {
+	ClearObjectCache()
+
+	var gets, creates atomic.Int32
+	var createdPayload map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			gets.Add(1)
+			json.NewEncoder(w).Encode(map[string]any{"count": 0, "results": []any{}})
+		case "POST":
+			creates.Add(1)
+			json.NewDecoder(r.Body).Decode(&createdPayload)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]any{"id": 42})
+		}
+	}))
+	defer server.Close()
+
+	pf := NewPaperlessFile("title", "file.pdf", "application/pdf", "", nil, nil, config.Config{
+		PaperlessAPI: server.URL,
+		Token:        "test-token",
+	})
+	pf.StoragePath = "Finance"
+
+	for i := 0; i < 3; i++ {
+		if err := pf.processAssignments(); err != nil {
+			t.Fatalf("processAssignments() error: %v", err)
+		}
+	}
+
+	if pf.StoragePathID != 42 {
+		t.Errorf("StoragePathID = %d, expected 42", pf.StoragePathID)
+	}
+	if gets.Load() != 1 || creates.Load() != 1 {
+		t.Errorf("expected 1 lookup and 1 create, got %d and %d", gets.Load(), creates.Load())
+	}
+	if createdPayload["name"] != "Finance" || createdPayload["path"] != "Finance/"+defaultStoragePathFormat {
+		t.Errorf("unexpected create payload: %v", createdPayload)
+	}
+	if pf.DocumentTypeID != 0 {
+		t.Errorf("DocumentTypeID = %d, expected 0 without document type", pf.DocumentTypeID)
+	}
+}
@@ -16,6 +16,27 @@ type PaperlessFile struct {
 	client   *http.Client
 	config   config.Config
 	TagIds   []int
+
+	// DocumentType and StoragePath are optional names that get resolved
+	// (and created if necessary) before upload
+	DocumentType   string
+	StoragePath    string
+	DocumentTypeID int
+	StoragePathID  int
+
+	// CustomFields are set on upload or after consumption, see Config.CustomFieldsOnUpload
+	CustomFields         []CustomField
+	customFieldInstances []customFieldInstance
+
+	// Note is attached as Paperless note after consumption
+	Note string
+
+	// TaskID is the consumption task returned by Paperless after upload
+	TaskID string
+
+	// PostConsume queues the note and custom fields that are applied after the
+	// consumption. Without a queue, Upload waits for the consumption.
+	PostConsume *PostConsumeQueue
 }
 
 // NewPaperlessFile creates a new PaperlessFile instance
@@ -31,3 +52,23 @@ func NewPaperlessFile(title, fileName, mimeType, created string, data []byte, ta
 		config:   cfg,
 	}
 }
+
+// WithConfig returns a copy of the file for the upload to another Paperless
+// instance, e.g. a mirror. IDs resolved for this file's instance aren't copied.
+func (pf *PaperlessFile) WithConfig(cfg config.Config) *PaperlessFile {
+	return &PaperlessFile{
+		Title:        pf.Title,
+		FileName:     pf.FileName,
+		MimeType:     pf.MimeType,
+		Data:         pf.Data,
+		Created:      pf.Created,
+		Tags:         pf.Tags,
+		client:       pf.client,
+		config:       cfg,
+		DocumentType: pf.DocumentType,
+		StoragePath:  pf.StoragePath,
+		CustomFields: pf.CustomFields,
+		Note:         pf.Note,
+		PostConsume:  pf.PostConsume,
+	}
+}
@@ -0,0 +1,61 @@
+package paperless
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// postConsumeWorkers is the number of documents that are waited for at the same time
+const postConsumeWorkers = 4
+
+// PostConsumeQueue adds the notes and custom fields to uploaded documents once
+// Paperless has consumed them. It works in the background, so that the uploads
+// don't wait for the consumption of each document.
+type PostConsumeQueue struct {
+	ctx       context.Context
+	semaphore chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewPostConsumeQueue returns a queue. Documents that are still queued when the
+// context is canceled are skipped.
+func NewPostConsumeQueue(ctx context.Context) *PostConsumeQueue {
+	return &PostConsumeQueue{
+		ctx:       ctx,
+		semaphore: make(chan struct{}, postConsumeWorkers),
+	}
+}
+
+// add queues the uploaded file. Only what's needed after the consumption is kept,
+// not the file data.
+func (q *PostConsumeQueue) add(pf *PaperlessFile) {
+	pending := &PaperlessFile{
+		FileName:             pf.FileName,
+		client:               pf.client,
+		config:               pf.config,
+		customFieldInstances: pf.customFieldInstances,
+		Note:                 pf.Note,
+		TaskID:               pf.TaskID,
+	}
+
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+
+		select {
+		case q.semaphore <- struct{}{}:
+		case <-q.ctx.Done():
+			slog.Warn("skipped update after consumption", "file", pending.FileName, "error", q.ctx.Err())
+			return
+		}
+		defer func() { <-q.semaphore }()
+
+		pending.finishUpload(q.ctx)
+	}()
+}
+
+// Wait blocks until all queued documents have been updated
+func (q *PostConsumeQueue) Wait() {
+	q.wg.Wait()
+}
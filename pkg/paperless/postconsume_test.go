@@ -0,0 +1,107 @@
+package paperless
+
+import (
+	"context"
+	"encoding/json"
+	"enex2paperless/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestPostConsumeQueue verifies that uploads with a queue don't wait for the
+// consumption and that Wait returns once the notes have been added
+func TestPostConsumeQueue(t *testing.T) {
+	consumptionPollInterval = time.Millisecond
+
+	var consumed, notes atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/documents/post_document/":
+			json.NewEncoder(w).Encode("task-789")
+		case "/api/tasks/":
+			status := "STARTED"
+			if consumed.Load() {
+				status = "SUCCESS"
+			}
+			json.NewEncoder(w).Encode([]map[string]any{{"task_id": "task-789", "status": status, "related_document": 21}})
+		case "/api/documents/21/notes/":
+			notes.Store(true)
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	queue := NewPostConsumeQueue(context.Background())
+	pf := NewPaperlessFile("title", "file.pdf", "application/pdf", "2022-01-01 12:00:00+00:00", []byte("data"), nil, config.Config{
+		PaperlessAPI: server.URL,
+		Token:        "test-token",
+	})
+	pf.Note = "paid"
+	pf.PostConsume = queue
+
+	if err := pf.Upload(); err != nil {
+		t.Fatalf("Upload() error: %v", err)
+	}
+	if notes.Load() {
+		t.Fatal("the note was added before the document was consumed")
+	}
+
+	consumed.Store(true)
+	queue.Wait()
+
+	if !notes.Load() {
+		t.Error("the note wasn't added after the consumption")
+	}
+}
+
+// TestPostConsumeQueueCanceled verifies that Wait returns when the context is
+// canceled while documents are still being consumed
+func TestPostConsumeQueueCanceled(t *testing.T) {
+	consumptionPollInterval = time.Millisecond
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/documents/post_document/":
+			json.NewEncoder(w).Encode("task-790")
+		case "/api/tasks/":
+			json.NewEncoder(w).Encode([]map[string]any{{"task_id": "task-790", "status": "STARTED"}})
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	queue := NewPostConsumeQueue(ctx)
+	for i := 0; i < postConsumeWorkers+2; i++ {
+		pf := NewPaperlessFile("title", "file.pdf", "application/pdf", "2022-01-01 12:00:00+00:00", []byte("data"), nil, config.Config{
+			PaperlessAPI: server.URL,
+			Token:        "test-token",
+		})
+		pf.Note = "paid"
+		pf.PostConsume = queue
+		if err := pf.Upload(); err != nil {
+			t.Fatalf("Upload() error: %v", err)
+		}
+	}
+
+	cancel()
+	waited := make(chan bool)
+	go func() {
+		queue.Wait()
+		waited <- true
+	}()
+
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait() didn't return after the cancellation")
+	}
+}
@@ -0,0 +1,270 @@
+package paperless
+
+import (
+	"bytes"
+	"encoding/json"
+	"enex2paperless/internal/config"
+	"enex2paperless/internal/logging"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ServerInfo describes a Paperless instance that passed the preflight checks
+type ServerInfo struct {
+	API     string
+	Version string
+	// APIVersion is the API version sent with every request, the newest one supported
+	// by both Paperless and this client. 0 if Paperless doesn't report it.
+	APIVersion int
+	Features   Features
+}
+
+// capability is a Paperless permission needed for the import
+type capability struct {
+	permission  string
+	description string
+}
+
+// ObtainToken exchanges username and password for an API token
+func ObtainToken(cfg config.Config) (string, error) {
+	url := fmt.Sprintf("%s/api/token/", cfg.PaperlessAPI)
+
+	jsonData, err := json.Marshal(map[string]string{
+		"username": cfg.Username,
+		"password": cfg.Password,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	setHeaders(req, cfg)
+	req.Header.Set("Content-Type", "application/json")
+
+	slog.Debug("request details", "method", req.Method, "url", req.URL.String())
+
+	resp, err := getSharedClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Paperless API not reachable at %s: %w", cfg.PaperlessAPI, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden:
+		return "", fmt.Errorf("authentication failed at %s: invalid username or password", cfg.PaperlessAPI)
+	default:
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(resp.Body)
+		return "", fmt.Errorf("couldn't obtain token, non 200 status code received (%d): %s", resp.StatusCode, buf.String())
+	}
+
+	var tokenResponse struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if tokenResponse.Token == "" {
+		return "", errors.New("Paperless returned an empty token")
+	}
+
+	logging.RegisterSecret(tokenResponse.Token)
+	return tokenResponse.Token, nil
+}
+
+// Preflight checks that the API is reachable, the credentials are accepted and the
+// user has the permissions needed for the import with the given configuration.
+// The error names the failed check or the missing capability.
+func Preflight(cfg config.Config) (*ServerInfo, error) {
+	// API root
+	resp, err := get(cfg, "/api/")
+	if errors.Is(err, errAuthentication) {
+		return nil, fmt.Errorf("%w at %s", err, cfg.PaperlessAPI)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Paperless API not reachable at %s: %w", cfg.PaperlessAPI, err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, fmt.Errorf("authentication failed at %s: %s", cfg.PaperlessAPI, credentialsHint(cfg))
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("no Paperless API found at %s: check PaperlessAPI, it shouldn't end with /api", cfg.PaperlessAPI)
+	default:
+		return nil, fmt.Errorf("unexpected status code from Paperless API at %s: %d", cfg.PaperlessAPI, resp.StatusCode)
+	}
+
+	// Paperless-ngx adds its version to responses to authenticated requests
+	info := detectServerInfo(cfg.PaperlessAPI, resp.Header)
+	setServerInfo(info)
+
+	// permissions
+	permissions, err := userPermissions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if permissions == nil {
+		slog.Debug("Paperless doesn't report permissions, skipping permission check", "version", info.Version)
+		return &info, nil
+	}
+
+	for _, needed := range requiredCapabilities(cfg, info.Features) {
+		if !slices.Contains(permissions, needed.permission) {
+			return nil, fmt.Errorf("missing permission at %s: the user isn't allowed to %s (%s)", cfg.PaperlessAPI, needed.description, needed.permission)
+		}
+	}
+
+	slog.Debug("preflight checks passed", "api", info.API, "version", info.Version, "api version", info.APIVersion)
+	return &info, nil
+}
+
+// credentialsHint names the settings to check when the credentials are rejected
+func credentialsHint(cfg config.Config) string {
+	switch cfg.AuthMode {
+	case config.AuthModeRemoteUser:
+		return "check the username and that Paperless accepts the remote user header for API requests (PAPERLESS_ENABLE_HTTP_REMOTE_USER_API)"
+	case config.AuthModeSession:
+		return "check the username and password"
+	}
+	return "check the token or username and password"
+}
+
+// requiredCapabilities lists the permissions needed for an import with the configuration.
+// Features that aren't supported by the instance don't need permissions.
+func requiredCapabilities(cfg config.Config, features Features) []capability {
+	capabilities := []capability{
+		{"documents.add_document", "add documents"},
+		{"documents.view_tag", "view tags"},
+		{"documents.add_tag", "add tags"},
+	}
+
+	var documentTypes, storagePaths bool
+	for _, rule := range cfg.Rules {
+		documentTypes = documentTypes || rule.DocumentType != ""
+		storagePaths = storagePaths || rule.StoragePath != ""
+	}
+	if documentTypes {
+		capabilities = append(capabilities, capability{"documents.add_documenttype", "add document types"})
+	}
+	if storagePaths {
+		capabilities = append(capabilities, capability{"documents.add_storagepath", "add storage paths"})
+	}
+	if len(cfg.CustomFields) > 0 && features.CustomFields {
+		capabilities = append(capabilities, capability{"documents.add_customfield", "add custom fields"})
+	}
+	if cfg.NoteBody && features.Notes {
+		capabilities = append(capabilities, capability{"documents.add_note", "add notes"})
+	}
+
+	return capabilities
+}
+
+// userPermissions returns the permissions of the user, as reported in the UI settings.
+// It returns nil if the Paperless version doesn't report permissions.
+func userPermissions(cfg config.Config) ([]string, error) {
+	resp, err := get(cfg, "/api/ui_settings/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve permissions: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to retrieve permissions, non 200 status code received (%d)", resp.StatusCode)
+	}
+
+	var settings struct {
+		Permissions []string `json:"permissions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&settings); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return settings.Permissions, nil
+}
+
+// get sends an authenticated GET request to a path of the API
+func get(cfg config.Config, path string) (*http.Response, error) {
+	url := strings.TrimSuffix(cfg.PaperlessAPI, "/") + path
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := authenticate(req, cfg); err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	setAPIVersion(req, cfg.PaperlessAPI)
+
+	slog.Debug("request details", "method", req.Method, "url", req.URL.String())
+
+	return do(getSharedClient(), req, cfg)
+}
+
+// Connect prepares the Paperless instances of a configuration for an import.
+// Username and password are exchanged for a token once, so that later requests
+// don't use basic auth, and the preflight checks run for the primary instance
+// and every mirror. The returned configuration holds the obtained tokens.
+func Connect(cfg config.Config) (config.Config, error) {
+	token, err := connect(cfg)
+	if err != nil {
+		return cfg, err
+	}
+
+	mirrors := make([]config.Mirror, len(cfg.Mirrors))
+	copy(mirrors, cfg.Mirrors)
+	for i, m := range mirrors {
+		mirrors[i].Token, err = connect(cfg.MirrorConfig(m))
+		if err != nil {
+			name := m.Name
+			if name == "" {
+				name = m.PaperlessAPI
+			}
+			return cfg, fmt.Errorf("mirror %s: %w", name, err)
+		}
+	}
+
+	cfg.Token = token
+	cfg.Mirrors = mirrors
+	return cfg, nil
+}
+
+// connect obtains a token if needed and runs the preflight checks for a single
+// instance. It returns the token to use for further requests.
+func connect(cfg config.Config) (string, error) {
+	if cfg.AuthMode == "" && cfg.Token == "" && cfg.Username != "" {
+		token, err := ObtainToken(cfg)
+		if err != nil {
+			return "", err
+		}
+		slog.Debug("obtained API token", "api", cfg.PaperlessAPI)
+		cfg.Token = token
+	}
+
+	info, err := Preflight(cfg)
+	if err != nil {
+		return "", err
+	}
+	slog.Info("connected to Paperless", "api", info.API, "version", info.Version)
+
+	for _, feature := range unsupportedFeatures(cfg, info.Features) {
+		slog.Warn("setting not supported by this Paperless version, ignoring it",
+			"setting", feature,
+			"api", info.API,
+			"version", info.Version)
+	}
+
+	return cfg.Token, nil
+}
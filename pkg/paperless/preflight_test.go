@@ -0,0 +1,160 @@
+package paperless
+
+import (
+	"encoding/json"
+	"enex2paperless/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakePaperless serves the endpoints used by the preflight checks
+type fakePaperless struct {
+	token       string
+	version     string
+	permissions []string
+	noSettings  bool
+}
+
+func (f fakePaperless) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/token/" {
+		var credentials map[string]string
+		json.NewDecoder(r.Body).Decode(&credentials)
+		if credentials["username"] != "user" || credentials["password"] != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"token": f.token})
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Token "+f.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if f.version != "" {
+		w.Header().Set("X-Version", f.version)
+	}
+
+	switch r.URL.Path {
+	case "/api/":
+		json.NewEncoder(w).Encode(map[string]string{"documents": "/api/documents/"})
+	case "/api/ui_settings/":
+		if f.noSettings {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"permissions": f.permissions})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestConnect(t *testing.T) {
+	allPermissions := []string{"documents.add_document", "documents.view_tag", "documents.add_tag", "documents.add_note"}
+
+	tests := []struct {
+		name          string
+		server        fakePaperless
+		config        config.Config
+		path          string
+		expectedError string
+	}{
+		{
+			name:   "token",
+			server: fakePaperless{token: "abcdef123456", version: "2.15.3", permissions: allPermissions},
+			config: config.Config{Token: "abcdef123456"},
+		},
+		{
+			name:   "username and password exchanged for token",
+			server: fakePaperless{token: "abcdef123456", version: "2.15.3", permissions: allPermissions},
+			config: config.Config{Username: "user", Password: "secret"},
+		},
+		{
+			name:          "wrong password",
+			server:        fakePaperless{token: "abcdef123456", version: "2.15.3", permissions: allPermissions},
+			config:        config.Config{Username: "user", Password: "wrong"},
+			expectedError: "invalid username or password",
+		},
+		{
+			name:          "wrong token",
+			server:        fakePaperless{token: "abcdef123456", version: "2.15.3", permissions: allPermissions},
+			config:        config.Config{Token: "other"},
+			expectedError: "authentication failed",
+		},
+		{
+			name:          "wrong API path",
+			server:        fakePaperless{token: "abcdef123456", version: "2.15.3", permissions: allPermissions},
+			config:        config.Config{Token: "abcdef123456"},
+			path:          "/api",
+			expectedError: "no Paperless API found",
+		},
+		{
+			name:   "missing version header",
+			server: fakePaperless{token: "abcdef123456", permissions: allPermissions},
+			config: config.Config{Token: "abcdef123456"},
+		},
+		{
+			name:          "missing permission to add tags",
+			server:        fakePaperless{token: "abcdef123456", version: "2.15.3", permissions: []string{"documents.add_document", "documents.view_tag"}},
+			config:        config.Config{Token: "abcdef123456"},
+			expectedError: "isn't allowed to add tags",
+		},
+		{
+			name:          "missing permission for notes",
+			server:        fakePaperless{token: "abcdef123456", version: "2.15.3", permissions: allPermissions[:3]},
+			config:        config.Config{Token: "abcdef123456", NoteBody: true},
+			expectedError: "isn't allowed to add notes",
+		},
+		{
+			name:   "permissions not reported",
+			server: fakePaperless{token: "abcdef123456", version: "1.17.4", noSettings: true},
+			config: config.Config{Token: "abcdef123456"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.server)
+			defer server.Close()
+
+			tt.config.PaperlessAPI = server.URL + tt.path
+			cfg, err := Connect(tt.config)
+
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.Token != tt.server.token {
+				t.Errorf("Token = %q, expected %q", cfg.Token, tt.server.token)
+			}
+		})
+	}
+}
+
+// TestConnectMirror verifies that mirrors are checked with their own credentials
+func TestConnectMirror(t *testing.T) {
+	permissions := []string{"documents.add_document", "documents.view_tag", "documents.add_tag"}
+
+	primary := httptest.NewServer(fakePaperless{token: "primary-token", version: "2.15.3", permissions: permissions})
+	defer primary.Close()
+	mirror := httptest.NewServer(fakePaperless{token: "mirror-token", version: "2.15.3", permissions: permissions[:2]})
+	defer mirror.Close()
+
+	_, err := Connect(config.Config{
+		PaperlessAPI: primary.URL,
+		Token:        "primary-token",
+		Mirrors: []config.Mirror{
+			{Name: "backup", PaperlessAPI: mirror.URL, Username: "user", Password: "secret"},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "mirror backup") || !strings.Contains(err.Error(), "add tags") {
+		t.Fatalf("expected missing tag permission on mirror, got %v", err)
+	}
+}
@@ -25,11 +25,18 @@ func ClearTagCache() {
 	slog.Debug("tag cache cleared")
 }
 
+// tagCacheKey builds the cache key for a tag, tags are cached per Paperless instance
+func tagCacheKey(api, tagName string) string {
+	return api + "|" + tagName
+}
+
 // getOrCreateTagID retrieves or creates a tag ID in a thread-safe manner
 func (pf *PaperlessFile) getOrCreateTagID(tagName string) (int, error) {
+	key := tagCacheKey(pf.config.PaperlessAPI, tagName)
+
 	// First check the cache with a read lock
 	tagCacheMutex.RLock()
-	if id, exists := tagCache[tagName]; exists {
+	if id, exists := tagCache[key]; exists {
 		tagCacheMutex.RUnlock()
 		slog.Debug("tag found in cache", "tag", tagName, "id", id)
 		return id, nil
@@ -41,7 +48,7 @@ func (pf *PaperlessFile) getOrCreateTagID(tagName string) (int, error) {
 	defer tagCacheMutex.Unlock()
 
 	// Double-check the cache in case another goroutine added it
-	if id, exists := tagCache[tagName]; exists {
+	if id, exists := tagCache[key]; exists {
 		slog.Debug("tag found in cache after lock", "tag", tagName, "id", id)
 		return id, nil
 	}
@@ -64,7 +71,7 @@ func (pf *PaperlessFile) getOrCreateTagID(tagName string) (int, error) {
 	}
 
 	// Cache the result
-	tagCache[tagName] = id
+	tagCache[key] = id
 	return id, nil
 }
 
@@ -77,11 +84,8 @@ func (pf *PaperlessFile) getTagID(tagName string) (int, error) {
 		return 0, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	// auth
-	if pf.config.Token != "" {
-		req.Header.Set("Authorization", "Token "+pf.config.Token)
-	} else {
-		req.SetBasicAuth(pf.config.Username, pf.config.Password)
+	if err := pf.setAuth(req); err != nil {
+		return 0, err
 	}
 
 	// Send the request
@@ -93,7 +97,7 @@ func (pf *PaperlessFile) getTagID(tagName string) (int, error) {
 		"headers", req.Header)
 
 	client := getSharedClient()
-	resp, err := client.Do(req)
+	resp, err := do(client, req, pf.config)
 	if err != nil {
 		return 0, fmt.Errorf("failed to retrieve tags: %w", err)
 	}
@@ -142,11 +146,8 @@ func (pf *PaperlessFile) createTag(tagName string) (int, error) {
 		return 0, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	// auth
-	if pf.config.Token != "" {
-		req.Header.Set("Authorization", "Token "+pf.config.Token)
-	} else {
-		req.SetBasicAuth(pf.config.Username, pf.config.Password)
+	if err := pf.setAuth(req); err != nil {
+		return 0, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
@@ -158,7 +159,7 @@ func (pf *PaperlessFile) createTag(tagName string) (int, error) {
 
 	// send request
 	client := getSharedClient()
-	resp, err := client.Do(req)
+	resp, err := do(client, req, pf.config)
 	if err != nil {
 		return 0, fmt.Errorf("failed to execute request: %w", err)
 	}
@@ -0,0 +1,121 @@
+package paperless
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// defaultConsumptionTimeout is used if no ConsumptionTimeout is configured
+const defaultConsumptionTimeout = 120 * time.Second
+
+// consumptionPollInterval is the time between two task status requests
+var consumptionPollInterval = time.Second
+
+// taskStatus is the relevant part of a Paperless task
+type taskStatus struct {
+	TaskID          string `json:"task_id"`
+	Status          string `json:"status"`
+	Result          string `json:"result"`
+	RelatedDocument any    `json:"related_document"`
+}
+
+// waitForDocument polls the consumption task of the uploaded document until
+// Paperless has consumed it and returns the ID of the created document
+func (pf *PaperlessFile) waitForDocument(ctx context.Context) (int, error) {
+	if pf.TaskID == "" {
+		return 0, fmt.Errorf("no consumption task ID available")
+	}
+
+	timeout := defaultConsumptionTimeout
+	if pf.config.ConsumptionTimeout > 0 {
+		timeout = time.Duration(pf.config.ConsumptionTimeout) * time.Second
+	}
+	deadline := time.Now().Add(timeout)
+
+	slog.Debug("waiting for document consumption", "task", pf.TaskID)
+	for {
+		task, err := pf.getTask()
+		if err != nil {
+			return 0, err
+		}
+
+		if task != nil {
+			switch task.Status {
+			case "SUCCESS":
+				id, err := relatedDocumentID(task.RelatedDocument)
+				if err != nil {
+					return 0, fmt.Errorf("task %s succeeded without document: %w", pf.TaskID, err)
+				}
+				slog.Debug("document consumed", "task", pf.TaskID, "document", id)
+				return id, nil
+			case "FAILURE", "REVOKED":
+				return 0, fmt.Errorf("consumption failed: %s", task.Result)
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return 0, fmt.Errorf("timed out after %s waiting for task %s", timeout, pf.TaskID)
+		}
+		select {
+		case <-time.After(consumptionPollInterval):
+		case <-ctx.Done():
+			return 0, fmt.Errorf("stopped waiting for task %s: %w", pf.TaskID, ctx.Err())
+		}
+	}
+}
+
+// getTask retrieves the status of the consumption task. It returns nil if
+// Paperless doesn't know the task yet.
+func (pf *PaperlessFile) getTask() (*taskStatus, error) {
+	url := fmt.Sprintf("%s/api/tasks/?task_id=%s", pf.config.PaperlessAPI, url.QueryEscape(pf.TaskID))
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := pf.setAuth(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := do(pf.client, req, pf.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve task: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(resp.Body)
+		return nil, fmt.Errorf("non 200 status code received (%d): %s", resp.StatusCode, buf.String())
+	}
+
+	var tasks []taskStatus
+	if err := json.NewDecoder(resp.Body).Decode(&tasks); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+	return &tasks[0], nil
+}
+
+// relatedDocumentID parses the document ID of a task, which older Paperless
+// versions return as string and newer ones as number
+func relatedDocumentID(value any) (int, error) {
+	switch v := value.(type) {
+	case float64:
+		return int(v), nil
+	case string:
+		return strconv.Atoi(v)
+	default:
+		return 0, fmt.Errorf("unexpected document ID %v", value)
+	}
+}
@@ -0,0 +1,475 @@
+package pdf
+
+import (
+	"enex2paperless/pkg/enml"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// indentation in millimeters
+const (
+	listIndent  = 7.0
+	quoteIndent = 6.0
+	cellPadding = 1.5
+	checkbox    = 3.2
+)
+
+// headingSizes are the font sizes of headings by level
+var headingSizes = map[int]float64{1: 16, 2: 14, 3: 13}
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+// bodyWriter writes the ENML tree as flowing text. Formatting is tracked with
+// counters so nested elements restore the surrounding formatting.
+type bodyWriter struct {
+	doc   *document
+	media func(hash string) (enml.Media, bool)
+
+	bold, italic, underline, strike, mono, gray int
+
+	size   float64
+	link   string
+	indent float64
+
+	// started is set if the current line has content and needs to be ended
+	started bool
+	// hasText is set once text was written on the current line
+	hasText bool
+}
+
+func newBodyWriter(doc *document, media func(hash string) (enml.Media, bool)) *bodyWriter {
+	return &bodyWriter{
+		doc:   doc,
+		media: media,
+		size:  fontSize,
+	}
+}
+
+func (w *bodyWriter) render(n *enml.Node) {
+	w.node(n)
+	w.endLine()
+}
+
+func (w *bodyWriter) children(n *enml.Node) {
+	for _, child := range n.Children {
+		w.node(child)
+	}
+}
+
+func (w *bodyWriter) node(n *enml.Node) {
+	switch n.Type {
+	case enml.TextNode:
+		w.text(n.Text)
+
+	case enml.LineBreakNode:
+		w.doc.pdf.Ln(w.lineHeight())
+		w.started, w.hasText = false, false
+
+	case enml.ParagraphNode, enml.ListItemNode, enml.TableRowNode, enml.TableCellNode:
+		w.endLine()
+		w.children(n)
+		w.endLine()
+
+	case enml.HeadingNode:
+		w.heading(n)
+
+	case enml.ListNode:
+		w.list(n)
+
+	case enml.TodoNode:
+		w.checkbox(n.Checked)
+
+	case enml.TableNode:
+		w.table(n)
+
+	case enml.LinkNode:
+		href := n.Attr("href")
+		if href == "" {
+			w.children(n)
+			return
+		}
+		prev := w.link
+		w.link = href
+		w.children(n)
+		w.link = prev
+
+	case enml.MediaNode:
+		w.mediaNode(n)
+
+	case enml.CryptNode:
+		text := "[encrypted content]"
+		if hint := n.Attr("hint"); hint != "" {
+			text = "[encrypted content: " + hint + "]"
+		}
+		w.italic++
+		w.gray++
+		w.write(text)
+		w.gray--
+		w.italic--
+
+	case enml.ImageNode:
+		if alt := n.Attr("alt"); alt != "" {
+			w.write("[" + alt + "]")
+		}
+
+	case enml.BoldNode:
+		w.bold++
+		w.children(n)
+		w.bold--
+	case enml.ItalicNode:
+		w.italic++
+		w.children(n)
+		w.italic--
+	case enml.UnderlineNode:
+		w.underline++
+		w.children(n)
+		w.underline--
+	case enml.StrikethroughNode:
+		w.strike++
+		w.children(n)
+		w.strike--
+	case enml.CodeNode:
+		w.mono++
+		w.children(n)
+		w.mono--
+
+	case enml.PreformattedNode:
+		w.pre(n)
+
+	case enml.QuoteNode:
+		indent := w.indent
+		w.setIndent(indent + quoteIndent)
+		w.gray++
+		w.children(n)
+		w.endLine()
+		w.gray--
+		w.setIndent(indent)
+
+	case enml.RuleNode:
+		w.endLine()
+		pdf := w.doc.pdf
+		left, _, right, _ := pdf.GetMargins()
+		width, _ := pdf.GetPageSize()
+		y := pdf.GetY() + 2
+		pdf.SetDrawColor(180, 180, 180)
+		pdf.Line(left, y, width-right, y)
+		pdf.SetDrawColor(0, 0, 0)
+		pdf.SetY(y + 2)
+
+	default:
+		w.children(n)
+	}
+}
+
+// text writes text with HTML whitespace semantics
+func (w *bodyWriter) text(s string) {
+	s = whitespace.ReplaceAllString(s, " ")
+	if !w.hasText {
+		s = strings.TrimLeft(s, " ")
+	}
+	if s == "" {
+		return
+	}
+	w.write(s)
+}
+
+func (w *bodyWriter) write(s string) {
+	w.applyFont()
+	if w.link != "" {
+		w.doc.pdf.WriteLinkString(w.lineHeight(), w.doc.tr(s), w.link)
+	} else {
+		w.doc.pdf.Write(w.lineHeight(), w.doc.tr(s))
+	}
+	w.started, w.hasText = true, true
+}
+
+// endLine moves to the next line if the current line has content
+func (w *bodyWriter) endLine() {
+	if w.started {
+		w.doc.pdf.Ln(w.lineHeight())
+	}
+	w.started, w.hasText = false, false
+}
+
+// setIndent ends the current line and moves the left margin
+func (w *bodyWriter) setIndent(indent float64) {
+	w.endLine()
+	w.indent = indent
+	w.doc.pdf.SetLeftMargin(margin + indent)
+	w.doc.pdf.SetX(margin + indent)
+}
+
+func (w *bodyWriter) lineHeight() float64 {
+	return max(lineHeight, w.size*0.45)
+}
+
+// applyFont selects the font and color for the current formatting
+func (w *bodyWriter) applyFont() {
+	style := ""
+	if w.bold > 0 {
+		style += "B"
+	}
+	if w.italic > 0 {
+		style += "I"
+	}
+	if w.underline > 0 || w.link != "" {
+		style += "U"
+	}
+	if w.strike > 0 {
+		style += "S"
+	}
+
+	family := fontFamily
+	if w.mono > 0 {
+		family = "Courier"
+	}
+	w.doc.pdf.SetFont(family, style, w.size)
+
+	switch {
+	case w.link != "":
+		w.doc.pdf.SetTextColor(30, 80, 180)
+	case w.gray > 0:
+		w.doc.pdf.SetTextColor(100, 100, 100)
+	default:
+		w.doc.pdf.SetTextColor(0, 0, 0)
+	}
+}
+
+func (w *bodyWriter) heading(n *enml.Node) {
+	w.endLine()
+	w.doc.pdf.Ln(2)
+
+	size := w.size
+	w.size = fontSize + 1
+	if s, ok := headingSizes[n.Level]; ok {
+		w.size = s
+	}
+	w.bold++
+	w.children(n)
+	w.endLine()
+	w.bold--
+	w.size = size
+
+	w.doc.pdf.Ln(1)
+}
+
+func (w *bodyWriter) list(n *enml.Node) {
+	indent := w.indent
+	w.setIndent(indent + listIndent)
+
+	number := 1
+	if start, err := strconv.Atoi(n.Attr("start")); err == nil {
+		number = start
+	}
+
+	for _, item := range n.Children {
+		if item.Type != enml.ListItemNode {
+			w.node(item)
+			continue
+		}
+
+		marker := "\u2022"
+		if n.Ordered {
+			marker = strconv.Itoa(number) + "."
+			number++
+		}
+
+		w.endLine()
+		w.marker(marker)
+		w.children(item)
+		w.endLine()
+	}
+
+	w.setIndent(indent)
+}
+
+// marker writes a list marker right-aligned in front of the left margin
+func (w *bodyWriter) marker(marker string) {
+	pdf := w.doc.pdf
+	left, _, _, _ := pdf.GetMargins()
+
+	pdf.SetFont(fontFamily, "", w.size)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetX(left - listIndent)
+	pdf.CellFormat(listIndent-1.5, w.lineHeight(), w.doc.tr(marker), "", 0, "R", false, 0, "")
+	pdf.SetX(left)
+
+	w.started = true
+}
+
+// checkbox draws an en-todo checkbox at the current position
+func (w *bodyWriter) checkbox(checked bool) {
+	pdf := w.doc.pdf
+	h := w.lineHeight()
+	if pdf.GetY()+h > w.doc.contentBottom() {
+		pdf.AddPage()
+	}
+
+	x, y := pdf.GetXY()
+	top := y + (h-checkbox)/2
+	pdf.SetLineWidth(0.3)
+	pdf.Rect(x, top, checkbox, checkbox, "D")
+	if checked {
+		pdf.Line(x+0.7, top+checkbox*0.55, x+checkbox*0.4, top+checkbox-0.7)
+		pdf.Line(x+checkbox*0.4, top+checkbox-0.7, x+checkbox-0.6, top+0.6)
+	}
+	pdf.SetLineWidth(0.2)
+	pdf.SetX(x + checkbox + 1.5)
+
+	w.started = true
+}
+
+// pre writes preformatted text line by line in a monospace font
+func (w *bodyWriter) pre(n *enml.Node) {
+	w.endLine()
+	pdf := w.doc.pdf
+
+	size := w.size
+	w.size = fontSize - 2
+	w.mono++
+	w.applyFont()
+
+	pdf.SetFillColor(245, 245, 245)
+	for _, line := range strings.Split(enml.PreText(n), "\n") {
+		line = strings.ReplaceAll(line, "\t", "    ")
+		pdf.MultiCell(0, w.lineHeight(), w.doc.tr(line), "", "L", true)
+	}
+	pdf.SetFillColor(255, 255, 255)
+
+	w.mono--
+	w.size = size
+	pdf.Ln(1)
+}
+
+// table draws a table with equally wide columns. Cell content is written as plain text.
+func (w *bodyWriter) table(n *enml.Node) {
+	w.endLine()
+	pdf := w.doc.pdf
+
+	var rows [][]*enml.Node
+	columns := 0
+	for _, row := range tableRows(n) {
+		var cells []*enml.Node
+		for _, cell := range row.Children {
+			if cell.Type == enml.TableCellNode {
+				cells = append(cells, cell)
+			}
+		}
+		columns = max(columns, len(cells))
+		rows = append(rows, cells)
+	}
+	if columns == 0 {
+		return
+	}
+
+	left, _, _, _ := pdf.GetMargins()
+	colWidth := w.doc.contentWidth() / float64(columns)
+	h := w.lineHeight()
+
+	for _, cells := range rows {
+		lines := make([][][]byte, len(cells))
+		rowHeight := h
+		for i, cell := range cells {
+			w.cellFont(cell)
+			text := strings.TrimSpace(enml.Text(cell, enml.Options{Media: w.media}))
+			lines[i] = pdf.SplitLines([]byte(w.doc.tr(text)), colWidth-2*cellPadding)
+			rowHeight = max(rowHeight, float64(len(lines[i]))*h)
+		}
+		rowHeight += 2 * cellPadding
+
+		if pdf.GetY()+rowHeight > w.doc.contentBottom() {
+			pdf.AddPage()
+		}
+
+		y := pdf.GetY()
+		for i := range columns {
+			x := left + float64(i)*colWidth
+			pdf.Rect(x, y, colWidth, rowHeight, "D")
+			if i >= len(cells) {
+				continue
+			}
+
+			w.cellFont(cells[i])
+			for j, line := range lines[i] {
+				pdf.SetXY(x+cellPadding, y+cellPadding+float64(j)*h)
+				pdf.CellFormat(colWidth-2*cellPadding, h, string(line), "", 0, "L", false, 0, "")
+			}
+		}
+		pdf.SetXY(left, y+rowHeight)
+	}
+
+	pdf.SetFont(fontFamily, "", w.size)
+	pdf.Ln(2)
+}
+
+func (w *bodyWriter) cellFont(cell *enml.Node) {
+	style := ""
+	if cell.Header {
+		style = "B"
+	}
+	w.doc.pdf.SetFont(fontFamily, style, w.size)
+	w.doc.pdf.SetTextColor(0, 0, 0)
+}
+
+// tableRows returns the rows of a table without the rows of nested tables
+func tableRows(n *enml.Node) []*enml.Node {
+	var rows []*enml.Node
+	n.Walk(func(node *enml.Node) bool {
+		if node.Type == enml.TableRowNode {
+			rows = append(rows, node)
+			return false
+		}
+		return node == n || node.Type != enml.TableNode
+	})
+	return rows
+}
+
+// mediaNode embeds images and lists other resources by file name
+func (w *bodyWriter) mediaNode(n *enml.Node) {
+	if w.media == nil {
+		return
+	}
+	m, ok := w.media(n.Attr("hash"))
+	if !ok {
+		return
+	}
+	if m.MimeType == "" {
+		m.MimeType = n.Attr("type")
+	}
+
+	if strings.HasPrefix(m.MimeType, "image/") && w.image(m) {
+		return
+	}
+
+	name := m.FileName
+	if name == "" {
+		name = "attachment"
+	}
+	w.write("[" + name + "]")
+}
+
+// image draws an image on its own line, scaled down to fit the page.
+// It returns false if the image can't be embedded.
+func (w *bodyWriter) image(m enml.Media) bool {
+	name, info, ok := w.doc.registerImage(m)
+	if !ok {
+		return false
+	}
+
+	w.endLine()
+	pdf := w.doc.pdf
+	left, top, _, _ := pdf.GetMargins()
+	width, height := fit(info.Width(), info.Height(), w.doc.contentWidth(), w.doc.contentBottom()-top)
+
+	if pdf.GetY()+height > w.doc.contentBottom() {
+		pdf.AddPage()
+	}
+
+	y := pdf.GetY()
+	pdf.ImageOptions(name, left, y, width, height, false, fpdf.ImageOptions{}, 0, "")
+	pdf.SetXY(left, y+height+1)
+	return true
+}
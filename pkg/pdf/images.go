@@ -0,0 +1,144 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/md5"
+	"enex2paperless/pkg/enml"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"log/slog"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// imageMargin is the page margin around images in millimeters
+const imageMargin = 10.0
+
+// imageTypes maps the image MIME types fpdf can embed directly to its image types
+var imageTypes = map[string]string{
+	"image/jpeg": "JPG",
+	"image/jpg":  "JPG",
+	"image/png":  "PNG",
+	"image/gif":  "GIF",
+}
+
+// RenderImages renders images as the pages of one PDF document in the given
+// order, each page oriented like its image. With cover set, the details and
+// text of the note are rendered on a cover page in front of the images.
+// Images that can't be decoded are left out and their indexes returned.
+func RenderImages(note Note, images []enml.Media, cover bool) ([]byte, []int, error) {
+	doc := newDocument(note.Title, note.Created)
+
+	if cover {
+		doc.pdf.AddPage()
+		doc.header(note)
+		if note.Body != nil {
+			// the images follow on their own pages
+			w := newBodyWriter(doc, nil)
+			w.render(note.Body)
+		}
+	}
+
+	var skipped []int
+	for i, m := range images {
+		if !doc.imagePage(m) {
+			skipped = append(skipped, i)
+		}
+	}
+	if len(skipped) == len(images) {
+		return nil, skipped, errors.New("none of the images could be embedded")
+	}
+
+	data, err := doc.output()
+	return data, skipped, err
+}
+
+// imagePage adds a page showing an image scaled to fit the page
+func (d *document) imagePage(m enml.Media) bool {
+	name, info, ok := d.registerImage(m)
+	if !ok {
+		slog.Warn("skipping image that can't be embedded in PDF", "file", m.FileName, "mimeType", m.MimeType)
+		return false
+	}
+
+	orientation := "P"
+	if info.Width() > info.Height() {
+		orientation = "L"
+	}
+	d.pdf.AddPageFormat(orientation, d.pdf.GetPageSizeStr("A4"))
+
+	pageWidth, pageHeight := d.pdf.GetPageSize()
+	width, height := fit(info.Width(), info.Height(), pageWidth-2*imageMargin, pageHeight-2*imageMargin)
+	x := (pageWidth - width) / 2
+	d.pdf.ImageOptions(name, x, imageMargin, width, height, false, fpdf.ImageOptions{}, 0, "")
+	return true
+}
+
+// registerImage adds an image to the document and returns its name. Images
+// fpdf can't embed as they are, e.g. interlaced or 16-bit PNGs, are converted
+// to PNG first. It returns false if the image can't be decoded.
+func (d *document) registerImage(m enml.Media) (string, *fpdf.ImageInfoType, bool) {
+	name := fmt.Sprintf("%x", md5.Sum(m.Data))
+	if info := d.pdf.GetImageInfo(name); info != nil {
+		return name, info, true
+	}
+
+	if imageType, ok := imageTypes[m.MimeType]; ok {
+		info := d.pdf.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: imageType}, bytes.NewReader(m.Data))
+		if d.pdf.Ok() {
+			return name, info, true
+		}
+		slog.Debug("converting image for PDF", "file", m.FileName, "error", d.pdf.Error())
+		d.pdf.ClearError()
+	}
+
+	data, err := convertImage(m.Data)
+	if err != nil {
+		slog.Debug("couldn't embed image in PDF", "file", m.FileName, "mimeType", m.MimeType, "error", err)
+		return "", nil, false
+	}
+
+	info := d.pdf.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(data))
+	if !d.pdf.Ok() {
+		slog.Debug("couldn't embed image in PDF", "file", m.FileName, "error", d.pdf.Error())
+		d.pdf.ClearError()
+		return "", nil, false
+	}
+	return name, info, true
+}
+
+// convertImage decodes an image and encodes it as 8-bit non-interlaced PNG
+func convertImage(data []byte) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	converted := image.NewNRGBA(bounds)
+	draw.Draw(converted, bounds, img, bounds.Min, draw.Src)
+
+	var buf bytes.Buffer
+	err = png.Encode(&buf, converted)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// fit scales width and height down to fit into the given box, keeping the aspect ratio
+func fit(width, height, maxWidth, maxHeight float64) (float64, float64) {
+	scale := 1.0
+	if width > maxWidth {
+		scale = maxWidth / width
+	}
+	if height*scale > maxHeight {
+		scale = maxHeight / height
+	}
+	return width * scale, height * scale
+}
@@ -0,0 +1,61 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+func init() {
+	// pdfcpu would otherwise create a configuration file in the user's config dir
+	api.DisableConfigDir()
+}
+
+// Merge concatenates PDF documents in the given order. Documents that can't be
+// read, e.g. because they are damaged or encrypted, are left out and their
+// indexes returned.
+func Merge(documents [][]byte) ([]byte, []int, error) {
+	var readers []io.ReadSeeker
+	var skipped []int
+	for i, document := range documents {
+		err := api.Validate(bytes.NewReader(document), newPdfcpuConfig())
+		if err != nil {
+			slog.Debug("leaving out PDF that can't be merged", "index", i, "error", err)
+			skipped = append(skipped, i)
+			continue
+		}
+		readers = append(readers, bytes.NewReader(document))
+	}
+
+	switch len(readers) {
+	case 0:
+		return nil, skipped, errors.New("none of the documents could be merged")
+	case 1:
+		// nothing to merge
+		for i, document := range documents {
+			if !slices.Contains(skipped, i) {
+				return document, skipped, nil
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	err := api.MergeRaw(readers, &buf, false, newPdfcpuConfig())
+	if err != nil {
+		return nil, nil, fmt.Errorf("error merging PDF documents: %w", err)
+	}
+
+	return buf.Bytes(), skipped, nil
+}
+
+func newPdfcpuConfig() *model.Configuration {
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	return conf
+}
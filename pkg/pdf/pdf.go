@@ -0,0 +1,233 @@
+// Package pdf renders Evernote notes to PDF documents using the PDF core fonts.
+// Text is encoded as cp1252, characters outside of it (e.g. CJK or emoji) are
+// replaced by dots and a warning is logged.
+package pdf
+
+import (
+	"bytes"
+	"enex2paperless/pkg/enml"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// page layout in millimeters
+const (
+	margin     = 20.0
+	lineHeight = 5.0
+	fontSize   = 11.0
+	fontFamily = "Helvetica"
+)
+
+// Note is a note to be rendered
+type Note struct {
+	Title   string
+	Created time.Time
+
+	// Updated, Tags and SourceURL are listed in the header when set
+	Updated   time.Time
+	Tags      []string
+	SourceURL string
+
+	// Body is the parsed ENML content of the note
+	Body *enml.Node
+
+	// Media resolves en-media hashes to the resources of the note.
+	// Images are embedded, other resources are listed by file name.
+	Media func(hash string) (enml.Media, bool)
+}
+
+// RenderNote renders a note to a PDF document with the title and created date
+// as header followed by the formatted note content
+func RenderNote(note Note) ([]byte, error) {
+	doc := newDocument(note.Title, note.Created)
+	doc.pdf.AddPage()
+
+	doc.header(note)
+
+	if note.Body != nil {
+		w := newBodyWriter(doc, note.Media)
+		w.render(note.Body)
+	}
+
+	return doc.output()
+}
+
+// RenderDocument renders a note with all of its PDF and image attachments as
+// one document: a cover page with the note details and text, followed by the
+// attachments in the given order. Attachments that can't be included are left
+// out and their indexes returned.
+func RenderDocument(note Note, attachments []enml.Media) ([]byte, []int, error) {
+	// attachments follow on their own pages
+	note.Media = nil
+	cover, err := RenderNote(note)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// parts holds the documents to merge, sources the attachment indexes of each part
+	parts := [][]byte{cover}
+	sources := [][]int{nil}
+	var skipped []int
+
+	// consecutive images are rendered into one part
+	var images []enml.Media
+	var imageIndexes []int
+	flushImages := func() {
+		if len(images) == 0 {
+			return
+		}
+		data, failed, err := RenderImages(Note{Title: note.Title, Created: note.Created}, images, false)
+		for _, i := range failed {
+			skipped = append(skipped, imageIndexes[i])
+		}
+		if err == nil {
+			parts = append(parts, data)
+			sources = append(sources, imageIndexes)
+		}
+		images, imageIndexes = nil, nil
+	}
+
+	for i, attachment := range attachments {
+		if attachment.MimeType == "application/pdf" {
+			flushImages()
+			parts = append(parts, attachment.Data)
+			sources = append(sources, []int{i})
+			continue
+		}
+		images = append(images, attachment)
+		imageIndexes = append(imageIndexes, i)
+	}
+	flushImages()
+
+	merged, failed, err := Merge(parts)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, part := range failed {
+		if part == 0 {
+			return nil, nil, errors.New("error merging the cover page")
+		}
+		skipped = append(skipped, sources[part]...)
+	}
+
+	slices.Sort(skipped)
+	return merged, skipped, nil
+}
+
+// document wraps the fpdf document and the cp1252 translation of text
+type document struct {
+	pdf   *fpdf.Fpdf
+	title string
+
+	// encode translates text to cp1252, see tr
+	encode func(string) string
+
+	// replaced counts the characters that couldn't be encoded
+	replaced int
+}
+
+func newDocument(title string, created time.Time) *document {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(true, margin)
+	pdf.SetTitle(title, true)
+	pdf.SetCreator("enex2paperless", true)
+	pdf.SetCatalogSort(true)
+	if !created.IsZero() {
+		pdf.SetCreationDate(created)
+	}
+	pdf.SetFont(fontFamily, "", fontSize)
+
+	return &document{
+		pdf:    pdf,
+		title:  title,
+		encode: pdf.UnicodeTranslatorFromDescriptor(""),
+	}
+}
+
+// tr encodes text as cp1252 for the core fonts. Characters outside of cp1252 are
+// replaced by a dot and counted.
+func (d *document) tr(s string) string {
+	encoded := d.encode(s)
+
+	// the translation writes one byte per rune
+	i := 0
+	for _, r := range s {
+		if encoded[i] == '.' && r != '.' {
+			d.replaced++
+		}
+		i++
+	}
+	return encoded
+}
+
+// header writes the title of the note followed by its dates, tags and source URL
+func (d *document) header(note Note) {
+	d.pdf.SetFont(fontFamily, "B", 18)
+	d.pdf.MultiCell(0, 8, d.tr(note.Title), "", "L", false)
+
+	var details []string
+	if !note.Created.IsZero() {
+		details = append(details, "Created: "+note.Created.Format("2006-01-02 15:04"))
+	}
+	if !note.Updated.IsZero() && !note.Updated.Equal(note.Created) {
+		details = append(details, "Updated: "+note.Updated.Format("2006-01-02 15:04"))
+	}
+	if len(note.Tags) > 0 {
+		details = append(details, "Tags: "+strings.Join(note.Tags, ", "))
+	}
+	if note.SourceURL != "" {
+		details = append(details, "Source: "+note.SourceURL)
+	}
+
+	d.pdf.SetFont(fontFamily, "", 9)
+	d.pdf.SetTextColor(100, 100, 100)
+	for _, detail := range details {
+		d.pdf.MultiCell(0, lineHeight, d.tr(detail), "", "L", false)
+	}
+	d.pdf.SetTextColor(0, 0, 0)
+
+	// separator line
+	left, _, right, _ := d.pdf.GetMargins()
+	width, _ := d.pdf.GetPageSize()
+	y := d.pdf.GetY() + 2
+	d.pdf.SetDrawColor(180, 180, 180)
+	d.pdf.Line(left, y, width-right, y)
+	d.pdf.SetDrawColor(0, 0, 0)
+	d.pdf.SetY(y + 4)
+
+	d.pdf.SetFont(fontFamily, "", fontSize)
+}
+
+// contentWidth returns the width between the margins
+func (d *document) contentWidth() float64 {
+	left, _, right, _ := d.pdf.GetMargins()
+	width, _ := d.pdf.GetPageSize()
+	return width - left - right
+}
+
+// contentBottom returns the y position where the bottom margin starts
+func (d *document) contentBottom() float64 {
+	_, height := d.pdf.GetPageSize()
+	_, bottom := d.pdf.GetAutoPageBreak()
+	return height - bottom
+}
+
+func (d *document) output() ([]byte, error) {
+	if d.replaced > 0 {
+		slog.Warn("replaced characters the PDF fonts can't display", "title", d.title, "characters", d.replaced)
+	}
+
+	var buf bytes.Buffer
+	err := d.pdf.Output(&buf)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,296 @@
+package pdf
+
+import (
+	"bytes"
+	"enex2paperless/pkg/enml"
+	"image"
+	"image/color"
+	"image/png"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+const testNote = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<!DOCTYPE en-note SYSTEM "http://xml.evernote.com/pub/enml2.dtd">
+<en-note>
+<h1>Receipt</h1>
+<div>paid on 3.4., <b>ref 12345</b> &amp; <i>more</i></div>
+<div><br/></div>
+<ul><li>first</li><li>second<ul><li>nested</li></ul></li></ul>
+<ol><li>one</li><li>two</li></ol>
+<div><en-todo checked="true"/>done</div>
+<div><en-todo/>open</div>
+<table><tr><th>Item</th><th>Price</th></tr><tr><td>Coffee</td><td>3.50</td></tr></table>
+<div>see <a href="https://example.com">the site</a></div>
+<en-media hash="image" type="image/png"/>
+<en-media hash="document" type="application/pdf"/>
+<en-media hash="broken" type="image/png"/>
+<div style="--en-codeblock:true"><div>func main() {</div><div>  x := 1</div><div>}</div></div>
+</en-note>`
+
+// testPNG returns a small PNG image
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for x := range 40 {
+		img.Set(x, 10, color.RGBA{R: 255, A: 255})
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("error encoding test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func testMedia(t *testing.T) func(hash string) (enml.Media, bool) {
+	resources := map[string]enml.Media{
+		"image":    {FileName: "scan.png", MimeType: "image/png", Data: testPNG(t)},
+		"document": {FileName: "contract.pdf", MimeType: "application/pdf", Data: []byte("%PDF-1.4")},
+		"broken":   {FileName: "broken.png", MimeType: "image/png", Data: []byte("not an image")},
+	}
+	return func(hash string) (enml.Media, bool) {
+		m, ok := resources[hash]
+		return m, ok
+	}
+}
+
+// renderUncompressed renders a note without stream compression so the text can be inspected
+func renderUncompressed(t *testing.T, note Note) string {
+	t.Helper()
+
+	doc := newDocument(note.Title, note.Created)
+	doc.pdf.SetCompression(false)
+	doc.pdf.AddPage()
+	doc.header(note)
+	newBodyWriter(doc, note.Media).render(note.Body)
+
+	data, err := doc.output()
+	if err != nil {
+		t.Fatalf("error rendering PDF: %v", err)
+	}
+	return string(data)
+}
+
+// TestRenderNote tests that a note is rendered to a valid PDF
+func TestRenderNote(t *testing.T) {
+	body, err := enml.Parse(testNote)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	note := Note{
+		Title:   "Receipt",
+		Created: time.Date(2023, 4, 3, 12, 30, 0, 0, time.UTC),
+		Body:    body,
+		Media:   testMedia(t),
+	}
+
+	data, err := RenderNote(note)
+	if err != nil {
+		t.Fatalf("RenderNote() error: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		t.Errorf("RenderNote() didn't return a PDF document")
+	}
+
+	result := renderUncompressed(t, note)
+	expectedParts := []string{
+		"(Receipt)",
+		"(Created: 2023-04-03 12:30)",
+		"(paid on 3.4., )",
+		"(ref 12345)",
+		"(nested)",
+		"(Coffee)",
+		"(the site)",
+		"/URI (https://example.com)",
+		"/Subtype /Image",
+		"([contract.pdf])",
+		"([broken.png])",
+		"(  x := 1)",
+	}
+	for _, part := range expectedParts {
+		if !strings.Contains(result, part) {
+			t.Errorf("rendered PDF is missing %q", part)
+		}
+	}
+}
+
+// TestRenderNoteEncoding verifies non-ASCII text is encoded for the core fonts
+func TestRenderNoteEncoding(t *testing.T) {
+	body, err := enml.Parse("<en-note><div>Grüße – 10 €</div></en-note>")
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	result := renderUncompressed(t, Note{Title: "Übersicht", Body: body})
+
+	// cp1252 encoded
+	for _, part := range []string{"(\xdcbersicht)", "(Gr\xfc\xdfe \x96 10 \x80)"} {
+		if !strings.Contains(result, part) {
+			t.Errorf("rendered PDF is missing %q", part)
+		}
+	}
+}
+
+// TestRenderNoteReplacedCharacters verifies that characters outside of cp1252 are
+// replaced and counted
+func TestRenderNoteReplacedCharacters(t *testing.T) {
+	doc := newDocument("Notes", time.Time{})
+
+	if encoded := doc.tr("Grüße 你好. 🙂"); encoded != "Gr\xfc\xdfe ... ." {
+		t.Errorf("tr() = %q, expected %q", encoded, "Gr\xfc\xdfe ... .")
+	}
+	if doc.replaced != 3 {
+		t.Errorf("replaced = %d, expected 3", doc.replaced)
+	}
+}
+
+// TestFit tests scaling images into the content area
+func TestFit(t *testing.T) {
+	tests := []struct {
+		name                          string
+		width, height                 float64
+		expectedWidth, expectedHeight float64
+	}{
+		{"small image", 50, 20, 50, 20},
+		{"wide image", 340, 100, 170, 50},
+		{"tall image", 100, 500, 50, 250},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			width, height := fit(tt.width, tt.height, 170, 250)
+			if width != tt.expectedWidth || height != tt.expectedHeight {
+				t.Errorf("fit() = %v, %v, expected %v, %v", width, height, tt.expectedWidth, tt.expectedHeight)
+			}
+		})
+	}
+}
+
+// TestRenderImages tests combining images into one document
+func TestRenderImages(t *testing.T) {
+	body, err := enml.Parse("<en-note><div>Letter from the insurance</div></en-note>")
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	note := Note{Title: "Letter", Body: body}
+
+	images := []enml.Media{
+		{FileName: "page1.png", MimeType: "image/png", Data: testPNG(t)},
+		{FileName: "broken.jpg", MimeType: "image/jpeg", Data: []byte("not an image")},
+		{FileName: "page2.png", MimeType: "image/png", Data: testPNG(t)},
+	}
+
+	tests := []struct {
+		name          string
+		images        []enml.Media
+		cover         bool
+		expectedPages int
+		expectError   bool
+	}{
+		{"images only", images, false, 2, false},
+		{"with cover page", images, true, 3, false},
+		{"no usable images", images[1:2], false, 0, true},
+	}
+
+	pageObject := regexp.MustCompile(`/Type /Page\n`)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, skipped, err := RenderImages(note, tt.images, tt.cover)
+			if tt.expectError {
+				if err == nil {
+					t.Error("RenderImages() expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RenderImages() error: %v", err)
+			}
+
+			pages := len(pageObject.FindAll(data, -1))
+			if pages != tt.expectedPages {
+				t.Errorf("RenderImages() rendered %d pages, expected %d", pages, tt.expectedPages)
+			}
+			if !slices.Equal(skipped, []int{1}) {
+				t.Errorf("RenderImages() skipped %v, expected the broken image", skipped)
+			}
+		})
+	}
+}
+
+// TestRenderDocument tests merging a cover page with PDF and image attachments
+func TestRenderDocument(t *testing.T) {
+	body, err := enml.Parse("<en-note><div>Policy documents</div></en-note>")
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	note := Note{
+		Title:     "Insurance",
+		Tags:      []string{"insurance", "2023"},
+		SourceURL: "https://example.com/policy",
+		Body:      body,
+	}
+
+	letter, err := RenderNote(Note{Title: "Letter"})
+	if err != nil {
+		t.Fatalf("RenderNote() error: %v", err)
+	}
+
+	attachments := []enml.Media{
+		{FileName: "letter.pdf", MimeType: "application/pdf", Data: letter},
+		{FileName: "page1.png", MimeType: "image/png", Data: testPNG(t)},
+		{FileName: "damaged.pdf", MimeType: "application/pdf", Data: []byte("%PDF-1.4 damaged")},
+		{FileName: "page2.png", MimeType: "image/png", Data: testPNG(t)},
+		{FileName: "broken.png", MimeType: "image/png", Data: []byte("not an image")},
+	}
+
+	data, skipped, err := RenderDocument(note, attachments)
+	if err != nil {
+		t.Fatalf("RenderDocument() error: %v", err)
+	}
+
+	if !slices.Equal(skipped, []int{2, 4}) {
+		t.Errorf("RenderDocument() skipped %v, expected [2 4]", skipped)
+	}
+
+	// cover page, letter, two images
+	count, err := api.PageCount(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("error reading merged document: %v", err)
+	}
+	if count != 4 {
+		t.Errorf("RenderDocument() rendered %d pages, expected 4", count)
+	}
+}
+
+// TestCoverPageDetails verifies the note details are listed below the title
+func TestCoverPageDetails(t *testing.T) {
+	note := Note{
+		Title:     "Insurance",
+		Created:   time.Date(2023, 4, 3, 12, 30, 0, 0, time.UTC),
+		Updated:   time.Date(2023, 5, 1, 8, 0, 0, 0, time.UTC),
+		Tags:      []string{"insurance", "2023"},
+		SourceURL: "https://example.com/policy",
+		Body:      &enml.Node{Type: enml.DocumentNode},
+	}
+
+	result := renderUncompressed(t, note)
+	for _, part := range []string{
+		"(Created: 2023-04-03 12:30)",
+		"(Updated: 2023-05-01 08:00)",
+		"(Tags: insurance, 2023)",
+		"(Source: https://example.com/policy)",
+	} {
+		if !strings.Contains(result, part) {
+			t.Errorf("rendered PDF is missing %q", part)
+		}
+	}
+}
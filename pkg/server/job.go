@@ -0,0 +1,412 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"enex2paperless/internal/config"
+	"enex2paperless/pkg/enex"
+)
+
+// Job states
+const (
+	StatusDraft     = "draft"
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	StatusCanceled  = "canceled"
+)
+
+// JobOptions are the per-job overrides of the configuration
+type JobOptions struct {
+	// Tags replace the configured additional tags, like the -t flag
+	Tags []string `json:"tags,omitempty"`
+
+	// Notebook is added as tag, like the ENEX file name with the -T flag
+	Notebook string `json:"notebook,omitempty"`
+
+	// Output is "paperless" to upload or "folder" to save to the configured output folder
+	Output string `json:"output"`
+}
+
+// Output modes of a job
+const (
+	OutputPaperless = "paperless"
+	OutputFolder    = "folder"
+)
+
+// Job is the import of an uploaded ENEX file
+type Job struct {
+	ID       string
+	FileName string
+	Options  JobOptions
+
+	// filePath is where the uploaded file is stored
+	filePath string
+	cancel   context.CancelFunc
+	ctx      context.Context
+
+	mu       sync.Mutex
+	status   string
+	created  time.Time
+	started  time.Time
+	finished time.Time
+	file     *enex.EnexFile
+	result   *enex.ProcessResult
+	err      error
+
+	// notes are the notes of the file, read for drafts
+	notes []NoteSummary
+
+	// selection holds the notes of a draft that are imported, by index
+	selection map[int]NoteEdit
+}
+
+// NoteSummary describes a note of an uploaded file
+type NoteSummary struct {
+	Index       int          `json:"index"`
+	Title       string       `json:"title"`
+	Created     string       `json:"created"`
+	Updated     string       `json:"updated"`
+	Tags        []string     `json:"tags"`
+	SourceURL   string       `json:"sourceUrl,omitempty"`
+	Attachments []Attachment `json:"attachments"`
+}
+
+// Attachment describes a resource of a note
+type Attachment struct {
+	FileName string `json:"fileName"`
+	Mime     string `json:"mime"`
+	Size     int    `json:"size"`
+}
+
+// NoteEdit selects a note of a draft for the import and changes its title and tags
+type NoteEdit struct {
+	Index int    `json:"index"`
+	Title string `json:"title,omitempty"`
+
+	// Tags replace the tags of the note if not nil
+	Tags []string `json:"tags"`
+}
+
+// JobStatus is the status of a job returned by the API
+type JobStatus struct {
+	ID       string     `json:"id"`
+	FileName string     `json:"fileName"`
+	Options  JobOptions `json:"options"`
+	Status   string     `json:"status"`
+	Created  time.Time  `json:"created"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Progress Progress   `json:"progress"`
+	Notes    int        `json:"notes,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// Progress counts the notes and files processed so far. Selected is the number of
+// notes selected in a draft, for other jobs it isn't known in advance.
+type Progress struct {
+	Selected       int `json:"selected,omitempty"`
+	NotesProcessed int `json:"notesProcessed"`
+	FilesUploaded  int `json:"filesUploaded"`
+}
+
+// Report is the result of a finished job
+type Report struct {
+	JobStatus
+	NotesProcessed int            `json:"notesProcessed"`
+	NotesFiltered  int            `json:"notesFiltered,omitempty"`
+	FilesUploaded  int            `json:"filesUploaded"`
+	FailedNotes    []FailedNote   `json:"failedNotes"`
+	Mirrors        []MirrorReport `json:"mirrors,omitempty"`
+}
+
+// FailedNote identifies a note that couldn't be imported
+type FailedNote struct {
+	Title   string `json:"title"`
+	Created string `json:"created"`
+}
+
+// MirrorReport summarizes the uploads to a mirror
+type MirrorReport struct {
+	Name     string `json:"name"`
+	Uploaded int    `json:"uploaded"`
+	Failed   int    `json:"failed"`
+}
+
+func newJob(id, fileName, filePath string, opts JobOptions) *Job {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Job{
+		ID:       id,
+		FileName: fileName,
+		Options:  opts,
+		filePath: filePath,
+		ctx:      ctx,
+		cancel:   cancel,
+		status:   StatusQueued,
+		created:  time.Now(),
+	}
+}
+
+// run processes the uploaded file with the configuration for the job options,
+// unless the job has been canceled while queued
+func (j *Job) run(settings func(JobOptions) (config.Config, error), workers int) {
+	j.mu.Lock()
+	if j.status != StatusQueued {
+		j.mu.Unlock()
+		return
+	}
+	j.status = StatusRunning
+	j.started = time.Now()
+	j.mu.Unlock()
+
+	cfg, err := settings(j.Options)
+	if err != nil {
+		j.finish(nil, err)
+		return
+	}
+
+	file := enex.NewEnexFile(j.filePath, cfg)
+	j.mu.Lock()
+	if j.selection != nil {
+		selection := j.selection
+		file.Select = func(index int, note *enex.Note) bool {
+			edit, selected := selection[index]
+			if !selected {
+				return false
+			}
+			if edit.Title != "" {
+				note.Title = edit.Title
+			}
+			if edit.Tags != nil {
+				note.Tags = edit.Tags
+			}
+			return true
+		}
+	}
+	j.file = file
+	j.mu.Unlock()
+
+	result, err := file.Process(enex.ProcessOptions{
+		ConcurrentWorkers: workers,
+		OutputFolder:      cfg.OutputFolder,
+		RetryPromptFunc:   enex.RetryOnce(),
+		Context:           j.ctx,
+	})
+
+	j.finish(result, err)
+}
+
+// finish records the result of the job
+func (j *Job) finish(result *enex.ProcessResult, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.result = result
+	j.err = err
+	j.finished = time.Now()
+	switch {
+	case j.ctx.Err() != nil:
+		j.status = StatusCanceled
+	case err != nil:
+		j.status = StatusFailed
+	default:
+		j.status = StatusCompleted
+	}
+}
+
+// Cancel stops a queued or running job and removes the uploaded file of a draft. It
+// returns false if the job has already finished.
+func (j *Job) Cancel() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	switch j.status {
+	case StatusDraft:
+		j.status = StatusCanceled
+		j.finished = time.Now()
+		// drafts aren't queued, so the file isn't removed after processing
+		if err := os.Remove(j.filePath); err != nil {
+			slog.Warn("failed to remove uploaded file", "file", j.filePath, "error", err)
+		}
+	case StatusQueued:
+		j.status = StatusCanceled
+		j.finished = time.Now()
+	case StatusRunning:
+		// the status is set when the processing has stopped
+	default:
+		return false
+	}
+
+	j.cancel()
+	return true
+}
+
+// expired reports whether the job finished before the cutoff, or is a draft created
+// before it
+func (j *Job) expired(cutoff time.Time) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	switch {
+	case j.status == StatusDraft:
+		return j.created.Before(cutoff)
+	case j.done():
+		return j.finished.Before(cutoff)
+	}
+	return false
+}
+
+// Done reports whether the job has finished
+func (j *Job) Done() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.done()
+}
+
+// done reports whether the job has finished, the caller holds j.mu
+func (j *Job) done() bool {
+	return j.status == StatusCompleted || j.status == StatusFailed || j.status == StatusCanceled
+}
+
+// Status returns the status and the progress of the job
+func (j *Job) Status() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.snapshot()
+}
+
+// snapshot returns the status of the job, the caller holds j.mu
+func (j *Job) snapshot() JobStatus {
+	status := JobStatus{
+		ID:       j.ID,
+		FileName: j.FileName,
+		Options:  j.Options,
+		Status:   j.status,
+		Created:  j.created,
+		Notes:    len(j.notes),
+	}
+	if !j.started.IsZero() {
+		started := j.started
+		status.Started = &started
+	}
+	if !j.finished.IsZero() {
+		finished := j.finished
+		status.Finished = &finished
+	}
+
+	switch {
+	case j.result != nil:
+		status.Progress = Progress{NotesProcessed: j.result.NotesProcessed, FilesUploaded: j.result.FilesUploaded}
+	case j.file != nil:
+		status.Progress = Progress{NotesProcessed: int(j.file.NumNotes.Load()), FilesUploaded: int(j.file.Uploads.Load())}
+	}
+
+	status.Progress.Selected = len(j.selection)
+
+	if j.err != nil {
+		status.Error = j.err.Error()
+	}
+	return status
+}
+
+// Notes returns the notes of a draft
+func (j *Job) Notes() []NoteSummary {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.notes
+}
+
+// Start queues a draft with the selected notes and the job options. It returns
+// false if the job isn't a draft.
+func (j *Job) Start(edits []NoteEdit, opts JobOptions) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.status != StatusDraft {
+		return false
+	}
+
+	j.selection = make(map[int]NoteEdit, len(edits))
+	for _, edit := range edits {
+		j.selection[edit.Index] = edit
+	}
+	j.Options = opts
+	j.status = StatusQueued
+	return true
+}
+
+// Report returns the report of a finished job, and false if the job hasn't finished yet
+func (j *Job) Report() (Report, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if !j.done() {
+		return Report{}, false
+	}
+
+	report := Report{JobStatus: j.snapshot(), FailedNotes: []FailedNote{}}
+	if j.result == nil {
+		return report, true
+	}
+
+	report.NotesProcessed = j.result.NotesProcessed
+	report.NotesFiltered = j.result.NotesFiltered
+	report.FilesUploaded = j.result.FilesUploaded
+	for _, note := range j.result.FailedNotes {
+		report.FailedNotes = append(report.FailedNotes, FailedNote{Title: note.Title, Created: note.Created})
+	}
+	for _, mirror := range j.result.Mirrors {
+		report.Mirrors = append(report.Mirrors, MirrorReport{Name: mirror.Name, Uploaded: mirror.Uploaded, Failed: mirror.Failed})
+	}
+	return report, true
+}
+
+// readNotes reads the summaries of the notes of an ENEX file that match the filter
+func readNotes(filePath string, filter config.Filter) ([]NoteSummary, error) {
+	notes := []NoteSummary{}
+	file := enex.NewEnexFile(filePath, config.Config{Filter: filter})
+	file.Select = func(index int, note *enex.Note) bool {
+		summary := NoteSummary{
+			Index:       index,
+			Title:       note.Title,
+			Created:     formatDate(note.Created),
+			Updated:     formatDate(note.Updated),
+			Tags:        note.Tags,
+			SourceURL:   note.NoteAttributes.SourceURL,
+			Attachments: []Attachment{},
+		}
+		if summary.Tags == nil {
+			summary.Tags = []string{}
+		}
+		for _, resource := range note.Resources {
+			summary.Attachments = append(summary.Attachments, Attachment{
+				FileName: resource.ResourceAttributes.FileName,
+				Mime:     resource.Mime,
+				Size:     resource.Size(),
+			})
+		}
+		notes = append(notes, summary)
+
+		// the notes are only listed
+		return false
+	}
+
+	if err := file.ReadFromFile(); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// formatDate converts an ENEX date to RFC 3339, dates that can't be parsed are kept
+func formatDate(date string) string {
+	parsed, err := time.Parse("20060102T150405Z", date)
+	if err != nil {
+		return date
+	}
+	return parsed.Format(time.RFC3339)
+}
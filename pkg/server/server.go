@@ -0,0 +1,547 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"enex2paperless/internal/config"
+	"enex2paperless/pkg/paperless"
+)
+
+// maxQueuedJobs limits the jobs waiting to be processed
+const maxQueuedJobs = 100
+
+// Defaults of the Options
+const (
+	DefaultMaxUploadSize = 1 << 30
+	DefaultJobRetention  = 24 * time.Hour
+)
+
+// Options configures the server
+type Options struct {
+	// Workers is the number of concurrent upload workers of a job
+	Workers int
+
+	// Token is required as bearer token by the API if set
+	Token string
+
+	// MaxUploadSize limits the size of uploaded files in bytes
+	MaxUploadSize int64
+
+	// JobRetention is how long finished jobs and drafts that haven't been started
+	// are kept. Expired jobs are removed together with their uploaded files.
+	JobRetention time.Duration
+}
+
+// Server runs import jobs for ENEX files posted to its HTTP API. Jobs are
+// processed one after another, so that Paperless isn't flooded with uploads.
+type Server struct {
+	config  config.Config
+	options Options
+
+	// uploadDir holds the uploaded files until their job has finished
+	uploadDir string
+
+	mu        sync.Mutex
+	jobs      map[string]*Job
+	order     []*Job
+	connected *config.Config
+
+	queue chan *Job
+	done  chan struct{}
+}
+
+// New returns a server for the configuration and starts processing jobs
+func New(cfg config.Config, opts Options) (*Server, error) {
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.MaxUploadSize <= 0 {
+		opts.MaxUploadSize = DefaultMaxUploadSize
+	}
+	if opts.JobRetention <= 0 {
+		opts.JobRetention = DefaultJobRetention
+	}
+
+	uploadDir, err := os.MkdirTemp("", "enex2paperless-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload dir: %w", err)
+	}
+
+	s := &Server{
+		config:    cfg,
+		options:   opts,
+		uploadDir: uploadDir,
+		jobs:      make(map[string]*Job),
+		queue:     make(chan *Job, maxQueuedJobs),
+		done:      make(chan struct{}),
+	}
+	go s.runJobs()
+	return s, nil
+}
+
+// Close cancels all jobs, waits for the running job to stop and removes the uploaded
+// files. The HTTP server has to be shut down before.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	for _, job := range s.order {
+		job.Cancel()
+	}
+	s.mu.Unlock()
+
+	close(s.queue)
+	<-s.done
+	return os.RemoveAll(s.uploadDir)
+}
+
+// Connect runs the preflight check against Paperless and keeps the credentials for
+// the jobs. It is called for the first job that uploads to Paperless if not before.
+func (s *Server) Connect() (config.Config, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.connected != nil {
+		return *s.connected, nil
+	}
+
+	cfg, err := paperless.Connect(s.config)
+	if err != nil {
+		return cfg, fmt.Errorf("preflight check failed: %w", err)
+	}
+	s.connected = &cfg
+	return cfg, nil
+}
+
+// runJobs processes the queued jobs one after another
+func (s *Server) runJobs() {
+	defer close(s.done)
+	for job := range s.queue {
+		job.run(s.jobSettings, s.options.Workers)
+		if err := os.Remove(job.filePath); err != nil {
+			slog.Warn("failed to remove uploaded file", "file", job.filePath, "error", err)
+		}
+		slog.Info("job finished", "job", job.ID, "status", job.Status().Status)
+	}
+}
+
+// jobSettings returns the configuration with the overrides of a job
+func (s *Server) jobSettings(opts JobOptions) (config.Config, error) {
+	cfg := s.config
+	if opts.Output == OutputPaperless {
+		var err error
+		if cfg, err = s.Connect(); err != nil {
+			return cfg, err
+		}
+		cfg.OutputFolder = ""
+	}
+
+	if len(opts.Tags) > 0 {
+		cfg.AdditionalTags = append([]string{}, opts.Tags...)
+	} else {
+		cfg.AdditionalTags = append([]string{}, cfg.AdditionalTags...)
+	}
+	if opts.Notebook != "" {
+		cfg.AdditionalTags = append(cfg.AdditionalTags, opts.Notebook)
+	}
+	return cfg, nil
+}
+
+// Handler returns the HTTP handler of the API and the web interface. The web
+// interface asks for the API token itself, so only the API requires it.
+func (s *Server) Handler() http.Handler {
+	api := http.NewServeMux()
+	api.HandleFunc("POST /api/jobs", s.createJob)
+	api.HandleFunc("GET /api/jobs", s.listJobs)
+	api.HandleFunc("GET /api/jobs/{id}", s.getJob)
+	api.HandleFunc("GET /api/jobs/{id}/report", s.getReport)
+	api.HandleFunc("DELETE /api/jobs/{id}", s.cancelJob)
+	api.HandleFunc("GET /api/jobs/{id}/notes", s.getNotes)
+	api.HandleFunc("POST /api/jobs/{id}/start", s.startJob)
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", checkOrigin(s.authenticate(api)))
+	mux.Handle("/", webHandler())
+	return mux
+}
+
+// authenticate requires the bearer token for all requests if one is configured
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if s.options.Token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.options.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "invalid or missing API token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkOrigin rejects requests that change jobs when they come from another site.
+// Browsers send cross-origin multipart form posts without asking first, so any web
+// page could otherwise start imports with the credentials of the server.
+func checkOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// requests of scripts and other clients don't send these headers
+		switch r.Header.Get("Sec-Fetch-Site") {
+		case "", "same-origin", "none":
+		default:
+			writeError(w, http.StatusForbidden, "cross-origin requests aren't allowed")
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || !strings.EqualFold(u.Host, r.Host) {
+				writeError(w, http.StatusForbidden, "cross-origin requests aren't allowed")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// createJob stores the posted ENEX file and queues a job for it. The file is sent
+// as multipart form field "file" or as the request body with the file name in the
+// "filename" query parameter. The job options are form fields or query parameters.
+// With draft=true, the notes of the file are read and the job waits to be started
+// with a selection of notes.
+func (s *Server) createJob(w http.ResponseWriter, r *http.Request) {
+	id, err := newID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, s.options.MaxUploadSize)
+	var body io.Reader = r.Body
+	fileName := r.URL.Query().Get("filename")
+	values := r.URL.Query()
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := multipartFile(r)
+		if err != nil {
+			writeError(w, uploadErrorStatus(err, http.StatusBadRequest), err.Error())
+			return
+		}
+		defer file.Close()
+		body, fileName, values = file, header.Filename, r.Form
+	}
+
+	opts, err := s.jobOptions(values)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	fileName = filepath.Base(fileName)
+	if fileName == "." || fileName == string(filepath.Separator) {
+		fileName = "upload.enex"
+	}
+
+	filePath := filepath.Join(s.uploadDir, id+"-"+fileName)
+	if err := saveUpload(filePath, body); err != nil {
+		writeError(w, uploadErrorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	job := newJob(id, fileName, filePath, opts)
+	if values.Get("draft") == "true" {
+		job.notes, err = readNotes(filePath, s.config.Filter)
+		if err != nil {
+			os.Remove(filePath)
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid ENEX file: %v", err))
+			return
+		}
+		job.status = StatusDraft
+	} else if !s.enqueue(job) {
+		os.Remove(filePath)
+		writeError(w, http.StatusServiceUnavailable, "too many queued jobs")
+		return
+	}
+
+	s.mu.Lock()
+	s.expireJobs(time.Now().Add(-s.options.JobRetention))
+	s.jobs[id] = job
+	s.order = append(s.order, job)
+	s.mu.Unlock()
+
+	slog.Info("job created", "job", id, "file", fileName, "status", job.Status().Status)
+	w.Header().Set("Location", "/api/jobs/"+id)
+	writeJSON(w, http.StatusCreated, job.Status())
+}
+
+// enqueue adds a job to the queue, it returns false if the queue is full
+func (s *Server) enqueue(job *Job) bool {
+	select {
+	case s.queue <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// expireJobs removes the jobs that finished before the cutoff and the drafts created
+// before it, the caller holds s.mu. Jobs are only added by createJob, which calls it,
+// so the jobs are bounded by the jobs created within the retention.
+func (s *Server) expireJobs(cutoff time.Time) {
+	s.order = slices.DeleteFunc(s.order, func(job *Job) bool {
+		if !job.expired(cutoff) {
+			return false
+		}
+		// removes the uploaded file of a draft
+		job.Cancel()
+		delete(s.jobs, job.ID)
+		slog.Debug("job expired", "job", job.ID)
+		return true
+	})
+}
+
+// uploadErrorStatus returns 413 if the upload exceeded the size limit, and the
+// fallback status otherwise
+func uploadErrorStatus(err error, fallback int) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return fallback
+}
+
+// multipartFile parses a multipart request and returns its file
+func multipartFile(r *http.Request) (multipart.File, *multipart.FileHeader, error) {
+	// larger files are buffered on disk by the multipart reader
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		return nil, nil, fmt.Errorf("invalid multipart form: %w", err)
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return nil, nil, fmt.Errorf("missing file: %w", err)
+	}
+	return file, header, nil
+}
+
+// jobOptions reads the job options from form values or query parameters
+func (s *Server) jobOptions(values url.Values) (JobOptions, error) {
+	var opts JobOptions
+	for _, value := range values["tags"] {
+		opts.Tags = append(opts.Tags, strings.Split(value, ",")...)
+	}
+	opts.Notebook = values.Get("notebook")
+	opts.Output = values.Get("output")
+
+	return opts, s.checkOptions(&opts)
+}
+
+// checkOptions validates the job options and sets the default output
+func (s *Server) checkOptions(opts *JobOptions) error {
+	var tags []string
+	for _, tag := range opts.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	opts.Tags = tags
+	opts.Notebook = strings.TrimSpace(opts.Notebook)
+
+	switch opts.Output {
+	case "":
+		opts.Output = OutputPaperless
+		if s.config.OutputFolder != "" {
+			opts.Output = OutputFolder
+		}
+	case OutputPaperless:
+	case OutputFolder:
+		if s.config.OutputFolder == "" {
+			return errors.New("no output folder configured")
+		}
+	default:
+		return fmt.Errorf("invalid output %q, expected %s or %s", opts.Output, OutputPaperless, OutputFolder)
+	}
+
+	return nil
+}
+
+// saveUpload writes the uploaded file to the upload dir
+func saveUpload(filePath string, body io.Reader) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to store upload: %w", err)
+	}
+
+	if _, err := io.Copy(file, body); err != nil {
+		file.Close()
+		os.Remove(filePath)
+		return fmt.Errorf("failed to store upload: %w", err)
+	}
+	return file.Close()
+}
+
+func (s *Server) listJobs(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	jobs := append([]*Job{}, s.order...)
+	s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(jobs))
+	for _, job := range jobs {
+		statuses = append(statuses, job.Status())
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (s *Server) getJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.job(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, job.Status())
+}
+
+func (s *Server) getReport(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.job(w, r)
+	if !ok {
+		return
+	}
+
+	report, finished := job.Report()
+	if !finished {
+		writeError(w, http.StatusConflict, "job hasn't finished yet")
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (s *Server) cancelJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.job(w, r)
+	if !ok {
+		return
+	}
+
+	if !job.Cancel() {
+		writeError(w, http.StatusConflict, "job has already finished")
+		return
+	}
+
+	slog.Info("job canceled", "job", job.ID)
+	writeJSON(w, http.StatusAccepted, job.Status())
+}
+
+func (s *Server) getNotes(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.job(w, r)
+	if !ok {
+		return
+	}
+
+	notes := job.Notes()
+	if notes == nil {
+		notes = []NoteSummary{}
+	}
+	writeJSON(w, http.StatusOK, notes)
+}
+
+// startRequest selects the notes of a draft and sets the job options
+type startRequest struct {
+	JobOptions
+	Notes []NoteEdit `json:"notes"`
+}
+
+func (s *Server) startJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.job(w, r)
+	if !ok {
+		return
+	}
+
+	var req startRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	if err := s.checkOptions(&req.JobOptions); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(req.Notes) == 0 {
+		writeError(w, http.StatusBadRequest, "no notes selected")
+		return
+	}
+
+	// with a filter, the listed indexes are the positions of the notes in the file
+	listed := make(map[int]bool)
+	for _, note := range job.Notes() {
+		listed[note.Index] = true
+	}
+	for _, note := range req.Notes {
+		if !listed[note.Index] {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid note index %d", note.Index))
+			return
+		}
+	}
+
+	if !job.Start(req.Notes, req.JobOptions) {
+		writeError(w, http.StatusConflict, "job isn't a draft")
+		return
+	}
+	if !s.enqueue(job) {
+		job.Cancel()
+		writeError(w, http.StatusServiceUnavailable, "too many queued jobs")
+		return
+	}
+
+	slog.Info("job queued", "job", job.ID, "notes", len(req.Notes))
+	writeJSON(w, http.StatusAccepted, job.Status())
+}
+
+// job returns the job of the request, or writes a not found error
+func (s *Server) job(w http.ResponseWriter, r *http.Request) (*Job, bool) {
+	s.mu.Lock()
+	job, exists := s.jobs[r.PathValue("id")]
+	s.mu.Unlock()
+
+	if !exists {
+		writeError(w, http.StatusNotFound, "job not found")
+	}
+	return job, exists
+}
+
+// newID returns a random job ID
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to create job ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("failed to write response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,487 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"enex2paperless/internal/config"
+)
+
+const testExport = `<en-export><note><title>Insurance</title><created>20230403T123000Z</created>
+<resource><data>dGVzdCBkYXRh</data><mime>application/pdf</mime>
+<resource-attributes><file-name>policy.pdf</file-name></resource-attributes></resource></note></en-export>`
+
+func newTestServer(t *testing.T, token string) (*httptest.Server, string) {
+	t.Helper()
+	outputFolder := t.TempDir()
+	srv, err := New(config.Config{
+		PaperlessAPI: "http://paperless.invalid",
+		FileTypes:    []string{"pdf"},
+		OutputFolder: outputFolder,
+	}, Options{Token: token})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	httpServer := httptest.NewServer(srv.Handler())
+	t.Cleanup(func() {
+		httpServer.Close()
+		srv.Close()
+	})
+	return httpServer, outputFolder
+}
+
+func request(t *testing.T, method, url, contentType string, body []byte, v any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// waitForJob polls the job until it has finished
+func waitForJob(t *testing.T, baseURL, id string) JobStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var status JobStatus
+		request(t, "GET", baseURL+"/api/jobs/"+id, "", nil, &status)
+		if status.Status != StatusQueued && status.Status != StatusRunning {
+			return status
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("job %s didn't finish", id)
+	return JobStatus{}
+}
+
+func TestJobs(t *testing.T) {
+	httpServer, outputFolder := newTestServer(t, "")
+
+	t.Run("raw body", func(t *testing.T) {
+		var created JobStatus
+		status := request(t, "POST", httpServer.URL+"/api/jobs?filename=Work.enex&tags=a,b&notebook=Work", "application/xml", []byte(testExport), &created)
+		if status != http.StatusCreated {
+			t.Fatalf("status = %d, expected %d", status, http.StatusCreated)
+		}
+		if created.FileName != "Work.enex" || created.Options.Output != OutputFolder || strings.Join(created.Options.Tags, ",") != "a,b" {
+			t.Errorf("unexpected job: %+v", created)
+		}
+
+		finished := waitForJob(t, httpServer.URL, created.ID)
+		if finished.Status != StatusCompleted || finished.Progress.FilesUploaded != 1 {
+			t.Errorf("unexpected job status: %+v", finished)
+		}
+
+		var report Report
+		if status := request(t, "GET", httpServer.URL+"/api/jobs/"+created.ID+"/report", "", nil, &report); status != http.StatusOK {
+			t.Fatalf("report status = %d", status)
+		}
+		if report.NotesProcessed != 1 || report.FilesUploaded != 1 || len(report.FailedNotes) != 0 {
+			t.Errorf("unexpected report: %+v", report)
+		}
+		if _, err := os.Stat(filepath.Join(outputFolder, "policy.pdf")); err != nil {
+			t.Errorf("file not saved: %v", err)
+		}
+
+		if status := request(t, "DELETE", httpServer.URL+"/api/jobs/"+created.ID, "", nil, nil); status != http.StatusConflict {
+			t.Errorf("cancel of finished job: status = %d, expected %d", status, http.StatusConflict)
+		}
+	})
+
+	t.Run("multipart", func(t *testing.T) {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("tags", "Travel")
+		file, _ := form.CreateFormFile("file", "../Travel.enex")
+		file.Write([]byte(testExport))
+		form.Close()
+
+		var created JobStatus
+		if status := request(t, "POST", httpServer.URL+"/api/jobs", form.FormDataContentType(), body.Bytes(), &created); status != http.StatusCreated {
+			t.Fatalf("status = %d, expected %d", status, http.StatusCreated)
+		}
+		if created.FileName != "Travel.enex" || strings.Join(created.Options.Tags, ",") != "Travel" {
+			t.Errorf("unexpected job: %+v", created)
+		}
+		waitForJob(t, httpServer.URL, created.ID)
+
+		var jobs []JobStatus
+		request(t, "GET", httpServer.URL+"/api/jobs", "", nil, &jobs)
+		if len(jobs) != 2 {
+			t.Errorf("got %d jobs, expected 2", len(jobs))
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			method, path   string
+			expectedStatus int
+		}{
+			{"POST", "/api/jobs?output=cloud", http.StatusBadRequest},
+			{"GET", "/api/jobs/unknown", http.StatusNotFound},
+			{"GET", "/api/jobs/unknown/report", http.StatusNotFound},
+			{"DELETE", "/api/jobs/unknown", http.StatusNotFound},
+		}
+		for _, tt := range tests {
+			var response map[string]string
+			if status := request(t, tt.method, httpServer.URL+tt.path, "", nil, &response); status != tt.expectedStatus {
+				t.Errorf("%s %s: status = %d, expected %d", tt.method, tt.path, status, tt.expectedStatus)
+			}
+			if response["error"] == "" {
+				t.Errorf("%s %s: missing error message", tt.method, tt.path)
+			}
+		}
+	})
+}
+
+func TestToken(t *testing.T) {
+	httpServer, _ := newTestServer(t, "secret")
+
+	for token, expectedStatus := range map[string]int{"": http.StatusUnauthorized, "wrong": http.StatusUnauthorized, "secret": http.StatusOK} {
+		req, _ := http.NewRequest("GET", httpServer.URL+"/api/jobs", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != expectedStatus {
+			t.Errorf("token %q: status = %d, expected %d", token, resp.StatusCode, expectedStatus)
+		}
+	}
+}
+
+// TestCrossOrigin verifies that jobs can't be created by pages of other sites
+func TestCrossOrigin(t *testing.T) {
+	httpServer, _ := newTestServer(t, "")
+	host := strings.TrimPrefix(httpServer.URL, "http://")
+
+	tests := []struct {
+		name           string
+		headers        map[string]string
+		expectedStatus int
+	}{
+		{"script", nil, http.StatusCreated},
+		{"web interface", map[string]string{"Origin": httpServer.URL, "Sec-Fetch-Site": "same-origin"}, http.StatusCreated},
+		{"other origin", map[string]string{"Origin": "https://evil.example.com"}, http.StatusForbidden},
+		{"other port", map[string]string{"Origin": "http://" + strings.Split(host, ":")[0] + ":1"}, http.StatusForbidden},
+		{"cross-site fetch", map[string]string{"Sec-Fetch-Site": "cross-site"}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			writer := multipart.NewWriter(&body)
+			part, _ := writer.CreateFormFile("file", "Work.enex")
+			part.Write([]byte(testExport))
+			writer.Close()
+
+			req, _ := http.NewRequest("POST", httpServer.URL+"/api/jobs?draft=true", &body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("status = %d, expected %d", resp.StatusCode, tt.expectedStatus)
+			}
+		})
+	}
+
+	// reading the jobs isn't restricted
+	if status := request(t, "GET", httpServer.URL+"/api/jobs", "", nil, nil); status != http.StatusOK {
+		t.Errorf("list status = %d, expected %d", status, http.StatusOK)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	job := newJob("1", "Work.enex", "/tmp/missing.enex", JobOptions{})
+	if !job.Cancel() {
+		t.Fatal("expected queued job to be canceled")
+	}
+	if job.Cancel() {
+		t.Error("expected canceled job not to be canceled again")
+	}
+
+	// a canceled job isn't started
+	job.run(func(JobOptions) (config.Config, error) {
+		t.Error("canceled job was started")
+		return config.Config{}, nil
+	}, 1)
+
+	report, finished := job.Report()
+	if !finished || report.Status != StatusCanceled {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
+
+// TestCancelDraft verifies that the uploaded file of a canceled draft is removed
+func TestCancelDraft(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "Work.enex")
+	if err := os.WriteFile(filePath, []byte(testExport), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	job := newJob("1", "Work.enex", filePath, JobOptions{})
+	job.status = StatusDraft
+	if !job.Cancel() {
+		t.Fatal("expected draft to be canceled")
+	}
+	if job.Status().Status != StatusCanceled {
+		t.Errorf("status = %s, expected %s", job.Status().Status, StatusCanceled)
+	}
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Errorf("uploaded file wasn't removed: %v", err)
+	}
+}
+
+// TestExpireJobs verifies that old finished jobs and drafts are removed and that
+// running and recent jobs are kept
+func TestExpireJobs(t *testing.T) {
+	now := time.Now()
+	draftPath := filepath.Join(t.TempDir(), "Draft.enex")
+	if err := os.WriteFile(draftPath, []byte(testExport), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	newTestJob := func(id, status string, created, finished time.Time) *Job {
+		job := newJob(id, id+".enex", filepath.Join(t.TempDir(), id+".enex"), JobOptions{})
+		job.status, job.created, job.finished = status, created, finished
+		return job
+	}
+	oldDraft := newTestJob("old-draft", StatusDraft, now.Add(-2*time.Hour), time.Time{})
+	oldDraft.filePath = draftPath
+	jobs := []*Job{
+		newTestJob("old", StatusCompleted, now.Add(-3*time.Hour), now.Add(-2*time.Hour)),
+		oldDraft,
+		newTestJob("running", StatusRunning, now.Add(-3*time.Hour), time.Time{}),
+		newTestJob("recent", StatusFailed, now.Add(-3*time.Hour), now.Add(-time.Minute)),
+		newTestJob("draft", StatusDraft, now.Add(-time.Minute), time.Time{}),
+	}
+
+	s := &Server{jobs: make(map[string]*Job)}
+	for _, job := range jobs {
+		s.jobs[job.ID] = job
+		s.order = append(s.order, job)
+	}
+	s.expireJobs(now.Add(-time.Hour))
+
+	var ids []string
+	for _, job := range s.order {
+		ids = append(ids, job.ID)
+	}
+	if strings.Join(ids, ",") != "running,recent,draft" || len(s.jobs) != 3 {
+		t.Errorf("got jobs %v, expected [running recent draft]", ids)
+	}
+	if _, err := os.Stat(draftPath); !os.IsNotExist(err) {
+		t.Errorf("file of the expired draft wasn't removed: %v", err)
+	}
+}
+
+// TestUploadLimit verifies that uploads larger than the limit are rejected
+func TestUploadLimit(t *testing.T) {
+	srv, err := New(config.Config{OutputFolder: t.TempDir()}, Options{MaxUploadSize: 64})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	httpServer := httptest.NewServer(srv.Handler())
+	t.Cleanup(func() {
+		httpServer.Close()
+		srv.Close()
+	})
+
+	if status := request(t, "POST", httpServer.URL+"/api/jobs?filename=Work.enex", "", []byte(testExport), nil); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("raw upload: status = %d, expected %d", status, http.StatusRequestEntityTooLarge)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", "Work.enex")
+	part.Write([]byte(testExport))
+	writer.Close()
+	if status := request(t, "POST", httpServer.URL+"/api/jobs", writer.FormDataContentType(), body.Bytes(), nil); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("multipart upload: status = %d, expected %d", status, http.StatusRequestEntityTooLarge)
+	}
+
+	entries, _ := os.ReadDir(srv.uploadDir)
+	if len(entries) != 0 {
+		t.Errorf("rejected uploads were stored: %v", entries)
+	}
+}
+
+// TestDraftJob verifies that the notes of a draft are listed and that only the
+// selected notes are imported with their changed titles
+func TestDraftJob(t *testing.T) {
+	httpServer, outputFolder := newTestServer(t, "")
+
+	export := `<en-export>
+	<note><title>Insurance</title><created>20230403T123000Z</created><tag>Car</tag>
+	<resource><data>aW5zdXJhbmNl</data><mime>application/pdf</mime>
+	<resource-attributes><file-name>policy.pdf</file-name></resource-attributes></resource></note>
+	<note><title>Receipt</title><created>20220101T090000Z</created>
+	<resource><data>cmVjZWlwdA==</data><mime>application/pdf</mime>
+	<resource-attributes><file-name>receipt.pdf</file-name></resource-attributes></resource></note>
+	</en-export>`
+
+	var draft JobStatus
+	if status := request(t, "POST", httpServer.URL+"/api/jobs?draft=true&filename=Work.enex", "", []byte(export), &draft); status != http.StatusCreated {
+		t.Fatalf("status = %d, expected %d", status, http.StatusCreated)
+	}
+	if draft.Status != StatusDraft || draft.Notes != 2 {
+		t.Fatalf("unexpected draft: %+v", draft)
+	}
+
+	var notes []NoteSummary
+	request(t, "GET", httpServer.URL+"/api/jobs/"+draft.ID+"/notes", "", nil, &notes)
+	expected := NoteSummary{
+		Index:       0,
+		Title:       "Insurance",
+		Created:     "2023-04-03T12:30:00Z",
+		Tags:        []string{"Car"},
+		Attachments: []Attachment{{FileName: "policy.pdf", Mime: "application/pdf", Size: 9}},
+	}
+	if len(notes) != 2 || !reflect.DeepEqual(notes[0], expected) {
+		t.Fatalf("unexpected notes: %+v", notes)
+	}
+
+	// the report isn't available before the job has finished
+	if status := request(t, "GET", httpServer.URL+"/api/jobs/"+draft.ID+"/report", "", nil, nil); status != http.StatusConflict {
+		t.Errorf("report status = %d, expected %d", status, http.StatusConflict)
+	}
+
+	for body, expectedStatus := range map[string]int{
+		`{"notes": []}`:                                http.StatusBadRequest,
+		`{"notes": [{"index": 5}]}`:                    http.StatusBadRequest,
+		`{"notes": [{"index": 1}], "output": "cloud"}`: http.StatusBadRequest,
+	} {
+		if status := request(t, "POST", httpServer.URL+"/api/jobs/"+draft.ID+"/start", "application/json", []byte(body), nil); status != expectedStatus {
+			t.Errorf("start with %s: status = %d, expected %d", body, status, expectedStatus)
+		}
+	}
+
+	var started JobStatus
+	body := `{"notes": [{"index": 1, "title": "Grocery receipt", "tags": ["Food"]}], "tags": ["Evernote"]}`
+	if status := request(t, "POST", httpServer.URL+"/api/jobs/"+draft.ID+"/start", "application/json", []byte(body), &started); status != http.StatusAccepted {
+		t.Fatalf("start status = %d, expected %d", status, http.StatusAccepted)
+	}
+	if started.Progress.Selected != 1 || strings.Join(started.Options.Tags, ",") != "Evernote" {
+		t.Errorf("unexpected job: %+v", started)
+	}
+
+	finished := waitForJob(t, httpServer.URL, draft.ID)
+	if finished.Status != StatusCompleted || finished.Progress.FilesUploaded != 1 {
+		t.Errorf("unexpected job status: %+v", finished)
+	}
+	if _, err := os.Stat(filepath.Join(outputFolder, "receipt.pdf")); err != nil {
+		t.Errorf("selected note not imported: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputFolder, "policy.pdf")); err == nil {
+		t.Error("note that wasn't selected was imported")
+	}
+
+	if status := request(t, "POST", httpServer.URL+"/api/jobs/"+draft.ID+"/start", "application/json", []byte(body), nil); status != http.StatusConflict {
+		t.Errorf("second start: status = %d, expected %d", status, http.StatusConflict)
+	}
+}
+
+// TestFilteredDraftJob verifies that a draft of a filtered file is started with the
+// indexes it lists
+func TestFilteredDraftJob(t *testing.T) {
+	outputFolder := t.TempDir()
+	srv, err := New(config.Config{
+		FileTypes:    []string{"pdf"},
+		OutputFolder: outputFolder,
+		Filter:       config.Filter{IncludeTags: []string{"tax"}},
+	}, Options{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	httpServer := httptest.NewServer(srv.Handler())
+	t.Cleanup(func() {
+		httpServer.Close()
+		srv.Close()
+	})
+
+	export := `<en-export>
+	<note><title>Holiday</title><created>20230403T123000Z</created><tag>travel</tag>
+	<resource><data>aG9saWRheQ==</data><mime>application/pdf</mime>
+	<resource-attributes><file-name>holiday.pdf</file-name></resource-attributes></resource></note>
+	<note><title>Tax return</title><created>20230405T090000Z</created><tag>tax</tag>
+	<resource><data>dGF4</data><mime>application/pdf</mime>
+	<resource-attributes><file-name>tax.pdf</file-name></resource-attributes></resource></note>
+	</en-export>`
+
+	var draft JobStatus
+	if status := request(t, "POST", httpServer.URL+"/api/jobs?draft=true&filename=Work.enex", "", []byte(export), &draft); status != http.StatusCreated {
+		t.Fatalf("status = %d, expected %d", status, http.StatusCreated)
+	}
+
+	var notes []NoteSummary
+	request(t, "GET", httpServer.URL+"/api/jobs/"+draft.ID+"/notes", "", nil, &notes)
+	if len(notes) != 1 || notes[0].Index != 1 {
+		t.Fatalf("unexpected notes: %+v", notes)
+	}
+
+	if status := request(t, "POST", httpServer.URL+"/api/jobs/"+draft.ID+"/start", "application/json", []byte(`{"notes": [{"index": 0}]}`), nil); status != http.StatusBadRequest {
+		t.Errorf("start with a filtered note: status = %d, expected %d", status, http.StatusBadRequest)
+	}
+	if status := request(t, "POST", httpServer.URL+"/api/jobs/"+draft.ID+"/start", "application/json", []byte(`{"notes": [{"index": 1}]}`), nil); status != http.StatusAccepted {
+		t.Fatalf("start status = %d, expected %d", status, http.StatusAccepted)
+	}
+
+	finished := waitForJob(t, httpServer.URL, draft.ID)
+	if finished.Status != StatusCompleted || finished.Progress.FilesUploaded != 1 {
+		t.Errorf("unexpected job status: %+v", finished)
+	}
+	if _, err := os.Stat(filepath.Join(outputFolder, "tax.pdf")); err != nil {
+		t.Errorf("selected note not imported: %v", err)
+	}
+}
+
+// TestWebInterface verifies that the web interface is served without the API token
+func TestWebInterface(t *testing.T) {
+	httpServer, _ := newTestServer(t, "secret")
+
+	resp, err := http.Get(httpServer.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	page, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(page), "<title>enex2paperless</title>") {
+		t.Errorf("status = %d, unexpected page:\n%s", resp.StatusCode, page)
+	}
+}
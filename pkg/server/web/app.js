@@ -0,0 +1,311 @@
+"use strict";
+
+const $ = (id) => document.getElementById(id);
+
+const state = {
+  job: null, // the open job
+  notes: [], // notes of the open draft
+  selected: new Set(), // indexes of the selected notes
+  edits: new Map(), // index -> {title, tags} changed by the user
+  poll: null, // timer of the progress view
+};
+
+// api sends a request to the API with the saved token and returns the decoded response
+async function api(method, path, body, headers = {}) {
+  const token = localStorage.getItem("token");
+  if (token) {
+    headers.Authorization = "Bearer " + token;
+  }
+
+  const resp = await fetch(path, { method, body, headers });
+  if (resp.status === 401) {
+    $("token-form").hidden = false;
+    throw new Error("The API requires a token, enter it at the top right.");
+  }
+
+  const data = await resp.json();
+  if (!resp.ok) {
+    throw new Error(data.error || resp.statusText);
+  }
+  return data;
+}
+
+function showError(err) {
+  $("error").textContent = err.message;
+  $("error").hidden = false;
+}
+
+function clearError() {
+  $("error").hidden = true;
+}
+
+function el(tag, props = {}, ...children) {
+  const node = document.createElement(tag);
+  Object.assign(node, props);
+  node.append(...children);
+  return node;
+}
+
+function formatSize(bytes) {
+  if (bytes < 1024) return bytes + " B";
+  if (bytes < 1024 * 1024) return (bytes / 1024).toFixed(1) + " KB";
+  return (bytes / 1024 / 1024).toFixed(1) + " MB";
+}
+
+function formatDate(date) {
+  const parsed = new Date(date);
+  return isNaN(parsed) ? date : parsed.toLocaleDateString();
+}
+
+function splitTags(value) {
+  return value.split(",").map((tag) => tag.trim()).filter((tag) => tag !== "");
+}
+
+function show(section) {
+  for (const id of ["draft", "progress"]) {
+    $(id).hidden = id !== section;
+  }
+  if (section !== "progress") {
+    clearTimeout(state.poll);
+  }
+}
+
+// Upload
+
+$("upload-form").addEventListener("submit", async (event) => {
+  event.preventDefault();
+  clearError();
+
+  const file = $("file").files[0];
+  const params = new URLSearchParams({ draft: "true", filename: file.name });
+  try {
+    const job = await api("POST", "/api/jobs?" + params, file);
+    await openDraft(job);
+    refreshJobs();
+  } catch (err) {
+    showError(err);
+  }
+});
+
+// Draft
+
+async function openDraft(job) {
+  state.job = job;
+  state.notes = await api("GET", `/api/jobs/${job.id}/notes`);
+  state.selected = new Set(state.notes.map((note) => note.index));
+  state.edits = new Map();
+
+  $("draft-title").textContent = `${job.fileName}: ${state.notes.length} notes`;
+  $("job-notebook").value = job.fileName.replace(/(\.enex)?(\.gz|\.zst|\.zip)?$/i, "");
+
+  const tags = new Set(state.notes.flatMap((note) => note.tags));
+  $("tag-filter").replaceChildren(
+    el("option", { value: "", textContent: "All tags" }),
+    ...[...tags].sort().map((tag) => el("option", { value: tag, textContent: tag })),
+  );
+
+  show("draft");
+  renderNotes();
+}
+
+// shownNotes returns the notes that match the search and the filters
+function shownNotes() {
+  const search = $("search").value.toLowerCase();
+  const tag = $("tag-filter").value;
+  const from = $("created-from").value;
+  const to = $("created-to").value;
+  const withAttachments = $("with-attachments").checked;
+
+  return state.notes.filter((note) => {
+    const created = note.created.slice(0, 10);
+    if (tag && !note.tags.includes(tag)) return false;
+    if (from && created < from) return false;
+    if (to && created > to) return false;
+    if (withAttachments && note.attachments.length === 0) return false;
+    if (!search) return true;
+
+    const text = [note.title, ...note.tags, ...note.attachments.map((a) => a.fileName)].join(" ");
+    return text.toLowerCase().includes(search);
+  });
+}
+
+function renderNotes() {
+  const rows = shownNotes().map((note) => {
+    const edit = state.edits.get(note.index) || {};
+
+    const checkbox = el("input", { type: "checkbox", checked: state.selected.has(note.index) });
+    checkbox.addEventListener("change", () => {
+      checkbox.checked ? state.selected.add(note.index) : state.selected.delete(note.index);
+      renderCount();
+    });
+
+    const title = el("input", { type: "text", value: edit.title ?? note.title });
+    title.addEventListener("change", () => setEdit(note, { title: title.value }));
+
+    const tags = el("input", { type: "text", value: (edit.tags ?? note.tags).join(", ") });
+    tags.addEventListener("change", () => setEdit(note, { tags: splitTags(tags.value) }));
+
+    const attachments = note.attachments.map((a) =>
+      el("div", { className: "attachment", textContent: `${a.fileName || "unnamed"} · ${a.mime} · ${formatSize(a.size)}` }),
+    );
+
+    return el(
+      "tr",
+      {},
+      el("td", {}, checkbox),
+      el("td", {}, title),
+      el("td", {}, tags),
+      el("td", { textContent: formatDate(note.created) }),
+      el("td", {}, ...(attachments.length ? attachments : [el("span", { className: "skipped", textContent: "none" })])),
+    );
+  });
+
+  $("notes").replaceChildren(...rows);
+  renderCount();
+}
+
+function setEdit(note, change) {
+  state.edits.set(note.index, { ...state.edits.get(note.index), ...change });
+}
+
+function renderCount() {
+  $("selection-count").textContent = `${state.selected.size} of ${state.notes.length} notes selected`;
+}
+
+for (const id of ["search", "tag-filter", "created-from", "created-to", "with-attachments"]) {
+  $(id).addEventListener("input", renderNotes);
+}
+
+$("select-shown").addEventListener("click", () => {
+  shownNotes().forEach((note) => state.selected.add(note.index));
+  renderNotes();
+});
+
+$("deselect-shown").addEventListener("click", () => {
+  shownNotes().forEach((note) => state.selected.delete(note.index));
+  renderNotes();
+});
+
+$("start-form").addEventListener("submit", async (event) => {
+  event.preventDefault();
+  clearError();
+
+  const notes = state.notes
+    .filter((note) => state.selected.has(note.index))
+    .map((note) => {
+      const edit = state.edits.get(note.index) || {};
+      const selection = { index: note.index };
+      if (edit.title !== undefined && edit.title !== note.title) selection.title = edit.title;
+      if (edit.tags !== undefined) selection.tags = edit.tags;
+      return selection;
+    });
+
+  const body = JSON.stringify({
+    notes,
+    tags: splitTags($("job-tags").value),
+    notebook: $("job-notebook").value,
+    output: $("job-output").value,
+  });
+
+  try {
+    const job = await api("POST", `/api/jobs/${state.job.id}/start`, body, { "Content-Type": "application/json" });
+    openProgress(job);
+    refreshJobs();
+  } catch (err) {
+    showError(err);
+  }
+});
+
+// Progress
+
+const finished = ["completed", "failed", "canceled"];
+
+function openProgress(job) {
+  clearTimeout(state.poll);
+  state.job = job;
+  $("progress-title").textContent = job.fileName;
+  $("failed").hidden = true;
+  show("progress");
+  renderProgress(job);
+}
+
+async function renderProgress(job) {
+  const progress = job.progress;
+  $("progress-status").textContent = job.status + (job.error ? `: ${job.error}` : "");
+  $("progress-counts").textContent = `${progress.notesProcessed} notes processed, ${progress.filesUploaded} files imported`;
+  $("cancel").hidden = finished.includes(job.status);
+
+  const bar = $("progress-bar");
+  if (progress.selected) {
+    bar.max = progress.selected;
+    bar.value = finished.includes(job.status) ? progress.selected : Math.min(progress.notesProcessed, progress.selected);
+  } else if (finished.includes(job.status)) {
+    bar.max = bar.value = 1;
+  } else {
+    bar.removeAttribute("value");
+  }
+
+  if (finished.includes(job.status)) {
+    const report = await api("GET", `/api/jobs/${job.id}/report`);
+    $("failed-notes").replaceChildren(
+      ...report.failedNotes.map((note) => el("li", { textContent: `${note.title} (${formatDate(note.created)})` })),
+    );
+    $("failed").hidden = report.failedNotes.length === 0;
+    refreshJobs();
+    return;
+  }
+
+  state.poll = setTimeout(async () => {
+    try {
+      const current = await api("GET", `/api/jobs/${job.id}`);
+      // another job may have been opened meanwhile
+      if (state.job.id === current.id && !$("progress").hidden) {
+        renderProgress(current);
+      }
+    } catch (err) {
+      showError(err);
+    }
+  }, 1000);
+}
+
+$("cancel").addEventListener("click", async () => {
+  clearError();
+  try {
+    await api("DELETE", `/api/jobs/${state.job.id}`);
+  } catch (err) {
+    showError(err);
+  }
+});
+
+// Jobs
+
+async function refreshJobs() {
+  const jobs = await api("GET", "/api/jobs");
+  const rows = jobs.reverse().map((job) => {
+    const row = el(
+      "tr",
+      {},
+      el("td", { textContent: job.fileName }),
+      el("td", { textContent: job.status }),
+      el("td", { textContent: new Date(job.created).toLocaleString() }),
+      el("td", { textContent: job.progress.notesProcessed }),
+      el("td", { textContent: job.progress.filesUploaded }),
+    );
+    row.addEventListener("click", () => {
+      clearError();
+      (job.status === "draft" ? openDraft(job) : Promise.resolve(openProgress(job))).catch(showError);
+    });
+    return row;
+  });
+  $("job-list").replaceChildren(...rows);
+}
+
+$("token-form").addEventListener("submit", (event) => {
+  event.preventDefault();
+  localStorage.setItem("token", $("token").value);
+  $("token-form").hidden = true;
+  clearError();
+  refreshJobs().catch(showError);
+});
+
+refreshJobs().catch(showError);
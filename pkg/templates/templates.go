@@ -0,0 +1,134 @@
+// Package templates renders the user defined text/template templates for the
+// titles and file names of imported documents.
+package templates
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Data is available in templates, e.g. {{.Note.Title}} ({{.Index}}/{{.Count}})
+type Data struct {
+	Note Note
+
+	// Title is the note title
+	Title string
+	// FileName is the file name of the attachment, BaseName the same without extension
+	FileName string
+	BaseName string
+	// Ext is the file extension including the dot, e.g. .pdf
+	Ext string
+
+	// Index numbers the documents imported from a note, starting at 1, Count is their number
+	Index int
+	Count int
+
+	// Year, Month and Day are the zero-padded parts of the created date
+	Year  string
+	Month string
+	Day   string
+
+	Tags     []string
+	FirstTag string
+
+	// ZipFile is the name of the zip archive the file was extracted from, without extension
+	ZipFile string
+	// EnexFile is the name of the ENEX file, without extension
+	EnexFile string
+}
+
+// Note holds the details of the note a document is imported from
+type Note struct {
+	Title     string
+	Created   time.Time
+	Updated   time.Time
+	Tags      []string
+	Author    string
+	SourceURL string
+}
+
+// funcs are available in addition to the text/template builtins
+var funcs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+	"date": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	"default": func(fallback, value string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	},
+}
+
+// example is used to check templates for unknown fields when they are parsed
+var example = NewData(Note{
+	Title:   "Example",
+	Created: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	Tags:    []string{"example"},
+}, "example.pdf", 1, 1)
+
+// Template is a parsed title, filename or path template
+type Template struct {
+	tmpl *template.Template
+}
+
+// Parse parses a template and executes it with example data, so that unknown
+// fields and functions are reported before any document is processed
+func Parse(name, text string) (*Template, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s template: %w", name, err)
+	}
+
+	t := &Template{tmpl: tmpl}
+	if _, err := t.Execute(example); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Execute renders the template, surrounding whitespace is removed
+func (t *Template) Execute(data Data) (string, error) {
+	var b strings.Builder
+	err := t.tmpl.Execute(&b, data)
+	if err != nil {
+		return "", fmt.Errorf("error executing %s template: %w", t.tmpl.Name(), err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// NewData returns the template data for a file of a note
+func NewData(note Note, fileName string, index, count int) Data {
+	ext := ""
+	if i := strings.LastIndex(fileName, "."); i > 0 {
+		ext = fileName[i:]
+	}
+
+	data := Data{
+		Note:     note,
+		Title:    note.Title,
+		FileName: fileName,
+		BaseName: strings.TrimSuffix(fileName, ext),
+		Ext:      ext,
+		Index:    index,
+		Count:    count,
+		Tags:     note.Tags,
+	}
+
+	if !note.Created.IsZero() {
+		data.Year = note.Created.Format("2006")
+		data.Month = note.Created.Format("01")
+		data.Day = note.Created.Format("02")
+	}
+	if len(note.Tags) > 0 {
+		data.FirstTag = note.Tags[0]
+	}
+
+	return data
+}
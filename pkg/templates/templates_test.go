@@ -0,0 +1,56 @@
+package templates
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTemplates tests parsing and rendering templates
+func TestTemplates(t *testing.T) {
+	data := NewData(Note{
+		Title:   "Insurance",
+		Created: time.Date(2023, 4, 3, 12, 30, 0, 0, time.UTC),
+		Tags:    []string{"Finance", "2023"},
+	}, "policy.scan.pdf", 2, 3)
+	data.EnexFile = "Archive"
+
+	tests := []struct {
+		name        string
+		template    string
+		expected    string
+		expectError bool
+	}{
+		{"index and count", "{{.Note.Title}} ({{.Index}}/{{.Count}})", "Insurance (2/3)", false},
+		{"date parts", "{{.Year}}-{{.Month}}-{{.Day}} {{.BaseName}}{{.Ext}}", "2023-04-03 policy.scan.pdf", false},
+		{"tags", "{{.FirstTag}}: {{join .Tags \", \"}}", "Finance: Finance, 2023", false},
+		{"functions", "{{upper .EnexFile}} {{date \"02.01.2006\" .Note.Created}}", "ARCHIVE 03.04.2023", false},
+		{"default", "{{default \"no zip\" .ZipFile}}", "no zip", false},
+		{"whitespace is trimmed", "  {{.Title}}\n", "Insurance", false},
+		{"unknown field", "{{.Note.Name}}", "", true},
+		{"unknown function", "{{shout .Title}}", "", true},
+		{"syntax error", "{{.Title", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Parse("title", tt.template)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Parse() expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+
+			result, err := tmpl.Execute(data)
+			if err != nil {
+				t.Fatalf("Execute() error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Execute() = %q, expected %q", result, tt.expected)
+			}
+		})
+	}
+}
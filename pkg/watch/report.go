@@ -0,0 +1,47 @@
+package watch
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"enex2paperless/pkg/enex"
+)
+
+// newReport returns the report that is saved next to an imported file
+func newReport(fileName string, started, finished time.Time, result *enex.ProcessResult, err error) []byte {
+	var buf bytes.Buffer
+
+	status := "imported"
+	if err != nil {
+		status = "failed"
+	}
+
+	fmt.Fprintf(&buf, "File:     %s\n", fileName)
+	fmt.Fprintf(&buf, "Status:   %s\n", status)
+	fmt.Fprintf(&buf, "Started:  %s\n", started.Format(time.RFC3339))
+	fmt.Fprintf(&buf, "Finished: %s\n", finished.Format(time.RFC3339))
+	if err != nil {
+		fmt.Fprintf(&buf, "Error:    %v\n", err)
+	}
+
+	if result == nil {
+		return buf.Bytes()
+	}
+
+	fmt.Fprintf(&buf, "\nNotes processed: %d\n", result.NotesProcessed)
+	if result.NotesFiltered > 0 {
+		fmt.Fprintf(&buf, "Notes filtered:  %d\n", result.NotesFiltered)
+	}
+	fmt.Fprintf(&buf, "Files uploaded:  %d\n", result.FilesUploaded)
+	fmt.Fprintf(&buf, "Failed notes:    %d\n", len(result.FailedNotes))
+	for _, note := range result.FailedNotes {
+		fmt.Fprintf(&buf, "  - %s (%s)\n", note.Title, note.Created)
+	}
+
+	for _, mirror := range result.Mirrors {
+		fmt.Fprintf(&buf, "\nMirror %s: %d uploaded, %d failed\n", mirror.Name, mirror.Uploaded, mirror.Failed)
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,272 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"enex2paperless/pkg/enex"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// DoneFolder receives the files that were imported without errors
+	DoneFolder = "done"
+
+	// FailedFolder receives the files that couldn't be read or had failed notes
+	FailedFolder = "failed"
+
+	// DefaultSettleTime is how long a file has to stay unchanged before it is imported
+	DefaultSettleTime = 5 * time.Second
+)
+
+// ProcessFunc imports an ENEX file. The import stops when the context is canceled.
+type ProcessFunc func(ctx context.Context, filePath string) (*enex.ProcessResult, error)
+
+// Watcher imports the ENEX files that are dropped into a folder. A file is imported
+// once it has been fully written and is then moved to the done or failed subfolder,
+// together with a report.
+type Watcher struct {
+	Dir     string
+	Process ProcessFunc
+
+	// SettleTime is how long the size and modification time of a new file have to
+	// stay the same before it is considered fully written
+	SettleTime time.Duration
+
+	// pending holds the files that are still being written
+	pending map[string]fileState
+
+	// queued holds the files that are waiting for or being imported
+	queued map[string]bool
+}
+
+// fileState is the last seen state of a pending file
+type fileState struct {
+	size    int64
+	modTime time.Time
+	since   time.Time
+}
+
+// NewWatcher returns a watcher for the folder
+func NewWatcher(dir string, process ProcessFunc) *Watcher {
+	return &Watcher{
+		Dir:        dir,
+		Process:    process,
+		SettleTime: DefaultSettleTime,
+		pending:    make(map[string]fileState),
+		queued:     make(map[string]bool),
+	}
+}
+
+// Run watches the folder until the context is canceled. Files that are already in
+// the folder are imported first. Files are imported one after another in the
+// background, so that the events of the folder are handled meanwhile.
+func (w *Watcher) Run(ctx context.Context) error {
+	for _, folder := range []string{DoneFolder, FailedFolder} {
+		if err := os.MkdirAll(filepath.Join(w.Dir, folder), 0755); err != nil {
+			return fmt.Errorf("failed to create %s folder: %w", folder, err)
+		}
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(w.Dir); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", w.Dir, err)
+	}
+
+	if err := w.scan(); err != nil {
+		return err
+	}
+
+	slog.Info("watching for ENEX files", "dir", w.Dir)
+
+	// very short settle times are checked every millisecond
+	ticker := time.NewTicker(max(w.SettleTime/2, time.Millisecond))
+	defer ticker.Stop()
+
+	// queue holds the written files in the order they are imported
+	var queue []string
+	importing := false
+	imported := make(chan string)
+
+	startImport := func() {
+		if importing || len(queue) == 0 || ctx.Err() != nil {
+			return
+		}
+		filePath := queue[0]
+		queue = queue[1:]
+		importing = true
+		go func() {
+			w.importFile(ctx, filePath)
+			imported <- filePath
+		}()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			if importing {
+				slog.Info("waiting for the running import to stop")
+				<-imported
+			}
+			slog.Info("stopped watching", "dir", w.Dir)
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) {
+				w.track(event.Name)
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				// events were dropped, look for the files that were missed
+				slog.Warn("file watcher events overflowed, rescanning folder", "dir", w.Dir)
+				if err := w.scan(); err != nil {
+					slog.Error("failed to rescan folder", "error", err)
+				}
+				continue
+			}
+			slog.Error("file watcher error", "error", err)
+
+		case filePath := <-imported:
+			delete(w.queued, filePath)
+			importing = false
+			startImport()
+
+		case <-ticker.C:
+			for _, filePath := range w.written(time.Now()) {
+				w.queued[filePath] = true
+				queue = append(queue, filePath)
+			}
+			startImport()
+		}
+	}
+}
+
+// scan tracks the ENEX files in the folder
+func (w *Watcher) scan() error {
+	entries, err := os.ReadDir(w.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", w.Dir, err)
+	}
+	for _, entry := range entries {
+		w.track(filepath.Join(w.Dir, entry.Name()))
+	}
+	return nil
+}
+
+// track adds a new or changed ENEX file to the pending files, or records its new state
+func (w *Watcher) track(filePath string) {
+	if !enex.IsEnexFile(filePath) || w.queued[filePath] {
+		return
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil || info.IsDir() {
+		// the file was moved away again
+		delete(w.pending, filePath)
+		return
+	}
+
+	state, exists := w.pending[filePath]
+	if !exists || state.size != info.Size() || !state.modTime.Equal(info.ModTime()) {
+		if !exists {
+			slog.Debug("new ENEX file", "file", filePath)
+		}
+		w.pending[filePath] = fileState{size: info.Size(), modTime: info.ModTime(), since: time.Now()}
+	}
+}
+
+// written returns the pending files that haven't changed for the settle time,
+// and removes them from the pending files
+func (w *Watcher) written(now time.Time) []string {
+	var ready []string
+	for filePath := range w.pending {
+		// writes don't always cause an event, e.g. on network shares
+		w.track(filePath)
+
+		state, exists := w.pending[filePath]
+		if exists && now.Sub(state.since) >= w.SettleTime {
+			ready = append(ready, filePath)
+			delete(w.pending, filePath)
+		}
+	}
+	return ready
+}
+
+// importFile processes a file and moves it with its report to the done or failed
+// folder. Files whose import is canceled are left in place.
+func (w *Watcher) importFile(ctx context.Context, filePath string) {
+	slog.Info("importing ENEX file", "file", filePath)
+
+	started := time.Now()
+	result, processErr := w.Process(ctx, filePath)
+	finished := time.Now()
+
+	if ctx.Err() != nil {
+		// the file stays in the folder and is imported again on the next start
+		slog.Warn("import canceled", "file", filePath)
+		return
+	}
+
+	folder := DoneFolder
+	if processErr != nil {
+		folder = FailedFolder
+		slog.Error("import failed", "file", filePath, "error", processErr)
+	} else {
+		slog.Info("import complete", "file", filePath)
+	}
+
+	target, err := moveFile(filePath, filepath.Join(w.Dir, folder))
+	if err != nil {
+		slog.Error("failed to move file", "file", filePath, "error", err)
+		return
+	}
+
+	report := newReport(filepath.Base(filePath), started, finished, result, processErr)
+	if err := os.WriteFile(target+".report.txt", report, 0644); err != nil {
+		slog.Error("failed to write report", "file", target, "error", err)
+	}
+}
+
+// moveFile moves a file to the folder and returns its new path. A counter is added
+// to the file name if the folder already has a file with the same name.
+func moveFile(filePath, folder string) (string, error) {
+	name := filepath.Base(filePath)
+	ext := filepath.Ext(name)
+	baseName := strings.TrimSuffix(name, ext)
+
+	target := filepath.Join(folder, name)
+	for counter := 1; ; counter++ {
+		_, err := os.Stat(target)
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to check if file exists: %w", err)
+		}
+		target = filepath.Join(folder, fmt.Sprintf("%s-%d%s", baseName, counter, ext))
+	}
+
+	if err := os.Rename(filePath, target); err != nil {
+		return "", fmt.Errorf("failed to move file: %w", err)
+	}
+	return target, nil
+}
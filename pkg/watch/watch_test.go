@@ -0,0 +1,210 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"enex2paperless/pkg/enex"
+)
+
+// TestWatcher verifies that existing and new ENEX files are imported once they are
+// written and moved to the done or failed folder with a report
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Existing.enex"), []byte("<en-export/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	processed := make(chan string, 10)
+	watcher := NewWatcher(dir, func(ctx context.Context, filePath string) (*enex.ProcessResult, error) {
+		processed <- filepath.Base(filePath)
+		if strings.HasPrefix(filepath.Base(filePath), "Broken") {
+			return &enex.ProcessResult{FailedNotes: []enex.Note{{Title: "Scan"}}}, errors.New("1 notes failed to process")
+		}
+		return &enex.ProcessResult{NotesProcessed: 2, FilesUploaded: 3}, nil
+	})
+	watcher.SettleTime = 100 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- watcher.Run(ctx)
+	}()
+
+	waitFor(t, processed, "Existing.enex")
+
+	// a file that is written in two steps is only imported once it is complete
+	file, err := os.Create(filepath.Join(dir, "Broken.enex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("<en-export>")
+	time.Sleep(60 * time.Millisecond)
+	file.WriteString("</en-export>")
+	file.Close()
+
+	waitFor(t, processed, "Broken.enex")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	select {
+	case name := <-processed:
+		t.Errorf("unexpected import of %s", name)
+	default:
+	}
+
+	report, err := os.ReadFile(filepath.Join(dir, DoneFolder, "Existing.enex.report.txt"))
+	if err != nil {
+		t.Fatalf("report not written: %v", err)
+	}
+	if !strings.Contains(string(report), "Status:   imported") || !strings.Contains(string(report), "Files uploaded:  3") {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+
+	report, err = os.ReadFile(filepath.Join(dir, FailedFolder, "Broken.enex.report.txt"))
+	if err != nil {
+		t.Fatalf("report not written: %v", err)
+	}
+	if !strings.Contains(string(report), "Error:    1 notes failed to process") || !strings.Contains(string(report), "  - Scan") {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Error("files that aren't ENEX files should be left alone")
+	}
+}
+
+// TestWatcherCancel verifies that canceling the context stops a running import
+// and that new files are picked up while an import is running
+func TestWatcherCancel(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Large.enex"), []byte("<en-export/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	processed := make(chan string, 10)
+	release := make(chan bool)
+	watcher := NewWatcher(dir, func(ctx context.Context, filePath string) (*enex.ProcessResult, error) {
+		processed <- filepath.Base(filePath)
+		if filepath.Base(filePath) != "Large.enex" {
+			return &enex.ProcessResult{}, nil
+		}
+		select {
+		case <-release:
+			return &enex.ProcessResult{}, nil
+		case <-ctx.Done():
+			return &enex.ProcessResult{}, ctx.Err()
+		}
+	})
+	watcher.SettleTime = 100 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- watcher.Run(ctx)
+	}()
+
+	waitFor(t, processed, "Large.enex")
+
+	// the new file waits for the running import
+	if err := os.WriteFile(filepath.Join(dir, "Small.enex"), []byte("<en-export/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	release <- true
+	waitFor(t, processed, "Small.enex")
+
+	// a running import is stopped by the cancellation
+	if err := os.WriteFile(filepath.Join(dir, "Large.enex"), []byte("<en-export/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, processed, "Large.enex")
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run() error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() didn't return after the cancellation")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "Large.enex")); err != nil {
+		t.Error("the file of the canceled import should be left in the folder")
+	}
+}
+
+// waitFor waits until the watcher imported the file and the file has been moved
+func waitFor(t *testing.T, processed chan string, name string) {
+	t.Helper()
+	select {
+	case got := <-processed:
+		if got != name {
+			t.Fatalf("imported %s, expected %s", got, name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s wasn't imported", name)
+	}
+}
+
+func TestMoveFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"Work.enex", "done/Work.enex", "done/Work-1.enex"} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	target, err := moveFile(filepath.Join(dir, "Work.enex"), filepath.Join(dir, "done"))
+	if err != nil {
+		t.Fatalf("moveFile() error: %v", err)
+	}
+	if target != filepath.Join(dir, "done", "Work-2.enex") {
+		t.Errorf("moved to %s, expected done/Work-2.enex", target)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "Work.enex" {
+		t.Errorf("moved file has content %q", data)
+	}
+}
+
+// TestWatcherShortSettleTime verifies that a settle time below the ticker
+// resolution doesn't stop the watcher
+func TestWatcherShortSettleTime(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Work.enex"), []byte("<en-export/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	processed := make(chan string, 1)
+	watcher := NewWatcher(dir, func(ctx context.Context, filePath string) (*enex.ProcessResult, error) {
+		processed <- filepath.Base(filePath)
+		return &enex.ProcessResult{}, nil
+	})
+	watcher.SettleTime = time.Nanosecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- watcher.Run(ctx)
+	}()
+
+	waitFor(t, processed, "Work.enex")
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+}